@@ -0,0 +1,194 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/models"
+)
+
+type AddressInput struct {
+	Type    string `json:"type" binding:"required,oneof=shipping billing"`
+	Street  string `json:"street" binding:"required"`
+	City    string `json:"city" binding:"required"`
+	State   string `json:"state" binding:"required"`
+	Country string `json:"country" binding:"required"`
+	ZipCode string `json:"zip_code" binding:"required"`
+}
+
+func (a *AddressInput) toModel() *models.Address {
+	return &models.Address{
+		Type:    a.Type,
+		Street:  a.Street,
+		City:    a.City,
+		State:   a.State,
+		Country: a.Country,
+		ZipCode: a.ZipCode,
+	}
+}
+
+// ListAddresses godoc
+// @Summary List addresses
+// @Description Get all addresses of the current user
+// @Tags addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /addresses [get]
+func (h *AddressHandler) ListAddresses(c *gin.Context) {
+	addresses, err := h.service.GetUserAddresses(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, addresses, "Addresses retrieved successfully")
+}
+
+// CreateAddress godoc
+// @Summary Create an address
+// @Description Add a shipping or billing address. The first address of each type becomes the default.
+// @Tags addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param address body AddressInput true "Address details"
+// @Success 201 {object} Response
+// @Router /addresses [post]
+func (h *AddressHandler) CreateAddress(c *gin.Context) {
+	var input AddressInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	address := input.toModel()
+	address.UserID = &userID
+
+	if err := h.service.CreateAddress(c.Request.Context(), address); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.createdResponse(c, address)
+}
+
+// GetAddress godoc
+// @Summary Get an address
+// @Description Get one of the current user's addresses
+// @Tags addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} Response
+// @Router /addresses/{id} [get]
+func (h *AddressHandler) GetAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid address ID")
+		return
+	}
+
+	address, err := h.service.GetAddress(c.Request.Context(), c.GetUint("user_id"), uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, address, "Address retrieved successfully")
+}
+
+// UpdateAddress godoc
+// @Summary Update an address
+// @Description Update one of the current user's addresses
+// @Tags addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Param address body AddressInput true "Updated address details"
+// @Success 200 {object} Response
+// @Router /addresses/{id} [put]
+func (h *AddressHandler) UpdateAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid address ID")
+		return
+	}
+
+	var input AddressInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	address := input.toModel()
+	address.ID = uint(id)
+	if err := h.service.UpdateAddress(c.Request.Context(), c.GetUint("user_id"), address); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, address, "Address updated successfully")
+}
+
+// DeleteAddress godoc
+// @Summary Delete an address
+// @Description Delete one of the current user's addresses
+// @Tags addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 204 "No Content"
+// @Router /addresses/{id} [delete]
+func (h *AddressHandler) DeleteAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid address ID")
+		return
+	}
+
+	if err := h.service.DeleteAddress(c.Request.Context(), c.GetUint("user_id"), uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.noContentResponse(c)
+}
+
+// SetDefaultAddress godoc
+// @Summary Set default address
+// @Description Make an address the default for its type
+// @Tags addresses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} Response
+// @Router /addresses/{id}/default [post]
+func (h *AddressHandler) SetDefaultAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid address ID")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if err := h.service.SetDefaultAddress(c.Request.Context(), userID, uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	address, err := h.service.GetAddress(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, address, "Default address updated successfully")
+}
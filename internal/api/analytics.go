@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+const (
+	// reportDateLayout is the format of the from and to report parameters
+	reportDateLayout = "2006-01-02"
+	// defaultReportDays is the length of the report range when from is omitted
+	defaultReportDays = 30
+	// defaultReportLimit is the number of rows of the top products and
+	// categories reports when limit is omitted
+	defaultReportLimit = 10
+)
+
+// reportRange reads the from and to query parameters as UTC dates. Both days
+// are included; the range defaults to the last 30 days up to today.
+func reportRange(c *gin.Context) (repository.DateRange, error) {
+	var rng repository.DateRange
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			return rng, fmt.Errorf("invalid to date %q", v)
+		}
+		to = t
+	}
+	rng.To = to.AddDate(0, 0, 1)
+
+	rng.From = to.AddDate(0, 0, 1-defaultReportDays)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			return rng, fmt.Errorf("invalid from date %q", v)
+		}
+		rng.From = t
+	}
+	return rng, nil
+}
+
+// reportLimit reads the limit query parameter
+func reportLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultReportLimit)))
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// wantsCSV reports whether the report was requested with format=csv
+func wantsCSV(c *gin.Context) bool {
+	return c.Query("format") == "csv"
+}
+
+// sendCSV responds with a CSV download of the given rows
+func sendCSV(c *gin.Context, filename string, header []string, rows [][]string) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	w.WriteAll(rows)
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+// reportFilename names a report download after its range, e.g.
+// sales-2024-01-01-2024-01-31.csv
+func reportFilename(name string, rng repository.DateRange) string {
+	return fmt.Sprintf("%s-%s-%s.csv", name, rng.From.Format(reportDateLayout), rng.To.AddDate(0, 0, -1).Format(reportDateLayout))
+}
+
+func formatAmount(m money.Money) string {
+	return m.Decimal()
+}
+
+func formatCount(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// GetSalesSummary godoc
+// @Summary Sales summary
+// @Description Revenue, order count, average order value, refund rate and new versus returning customers for a date range (admin only)
+// @Tags analytics
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} Response
+// @Router /admin/analytics/summary [get]
+func (h *AnalyticsHandler) GetSalesSummary(c *gin.Context) {
+	rng, err := reportRange(c)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summary, err := h.service.Summary(rng)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if wantsCSV(c) {
+		sendCSV(c, reportFilename("summary", rng), []string{"metric", "value"}, [][]string{
+			{"orders", formatCount(summary.Sales.Orders)},
+			{"revenue", formatAmount(summary.Sales.Revenue)},
+			{"average_order_value", formatAmount(summary.Sales.AverageOrderValue)},
+			{"refunded_orders", formatCount(summary.Refunds.Refunded)},
+			{"refunded_amount", formatAmount(summary.Refunds.RefundedAmount)},
+			{"refund_rate", strconv.FormatFloat(summary.Refunds.Rate, 'f', 4, 64)},
+			{"new_customers", formatCount(summary.Customers.NewCustomers)},
+			{"returning_customers", formatCount(summary.Customers.ReturningCustomers)},
+			{"guest_customers", formatCount(summary.Customers.GuestCustomers)},
+			{"new_customer_revenue", formatAmount(summary.Customers.NewRevenue)},
+			{"returning_customer_revenue", formatAmount(summary.Customers.ReturningRevenue)},
+			{"guest_revenue", formatAmount(summary.Customers.GuestRevenue)},
+		})
+		return
+	}
+
+	h.successResponse(c, summary, "Sales summary retrieved successfully")
+}
+
+// GetSalesOverTime godoc
+// @Summary Sales over time
+// @Description Revenue, order count and average order value per day, week or month, oldest first. Periods without sales are left out (admin only).
+// @Tags analytics
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param interval query string false "day (default), week or month"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} Response
+// @Router /admin/analytics/sales [get]
+func (h *AnalyticsHandler) GetSalesOverTime(c *gin.Context) {
+	rng, err := reportRange(c)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	buckets, err := h.service.SalesOverTime(rng, c.DefaultQuery("interval", repository.IntervalDay))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, len(buckets))
+		for i, b := range buckets {
+			rows[i] = []string{b.Period.Format(reportDateLayout), formatCount(b.Orders), formatAmount(b.Revenue), formatAmount(b.AverageOrderValue)}
+		}
+		sendCSV(c, reportFilename("sales", rng), []string{"period", "orders", "revenue", "average_order_value"}, rows)
+		return
+	}
+
+	h.successResponse(c, buckets, "Sales retrieved successfully")
+}
+
+// GetTopProducts godoc
+// @Summary Top products
+// @Description The products with the highest revenue in a date range (admin only)
+// @Tags analytics
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param limit query int false "Number of products, at most 100 (default 10)"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} Response
+// @Router /admin/analytics/top-products [get]
+func (h *AnalyticsHandler) GetTopProducts(c *gin.Context) {
+	rng, err := reportRange(c)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	products, err := h.service.TopProducts(rng, reportLimit(c))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, len(products))
+		for i, p := range products {
+			rows[i] = []string{strconv.FormatUint(uint64(p.ProductID), 10), p.SKU, p.Name, formatCount(p.Quantity), formatCount(p.Orders), formatAmount(p.Revenue)}
+		}
+		sendCSV(c, reportFilename("top-products", rng), []string{"product_id", "sku", "name", "quantity", "orders", "revenue"}, rows)
+		return
+	}
+
+	h.successResponse(c, products, "Top products retrieved successfully")
+}
+
+// GetTopCategories godoc
+// @Summary Top categories
+// @Description The categories with the highest revenue in a date range (admin only)
+// @Tags analytics
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param limit query int false "Number of categories, at most 100 (default 10)"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} Response
+// @Router /admin/analytics/top-categories [get]
+func (h *AnalyticsHandler) GetTopCategories(c *gin.Context) {
+	rng, err := reportRange(c)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categories, err := h.service.TopCategories(rng, reportLimit(c))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, len(categories))
+		for i, cat := range categories {
+			rows[i] = []string{strconv.FormatUint(uint64(cat.CategoryID), 10), cat.Name, formatCount(cat.Quantity), formatCount(cat.Orders), formatAmount(cat.Revenue)}
+		}
+		sendCSV(c, reportFilename("top-categories", rng), []string{"category_id", "name", "quantity", "orders", "revenue"}, rows)
+		return
+	}
+
+	h.successResponse(c, categories, "Top categories retrieved successfully")
+}
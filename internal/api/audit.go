@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/middleware"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/service"
+)
+
+// auditRecordedKey marks requests whose handler wrote its own audit entries
+const auditRecordedKey = "audit_recorded"
+
+// auditEntry starts an audit entry for the request and its actor
+func auditEntry(c *gin.Context, action string) *models.AuditLog {
+	entry := &models.AuditLog{
+		Action:    action,
+		ActorRole: c.GetString("user_role"),
+		IP:        c.ClientIP(),
+		RequestID: c.GetString(middleware.RequestIDKey),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+	}
+	if id := c.GetUint("user_id"); id != 0 {
+		entry.ActorID = &id
+	}
+	if id := c.GetUint("impersonator_id"); id != 0 {
+		entry.ImpersonatorID = &id
+	}
+	return entry
+}
+
+// recordAudit writes an entry. The action has already taken effect, so a
+// failure is logged rather than failing the request.
+func (h *Handler) recordAudit(c *gin.Context, entry *models.AuditLog) {
+	c.Set(auditRecordedKey, true)
+	if err := h.auditHandler.service.Record(entry); err != nil {
+		log.Printf("Failed to record audit entry %s for request %s: %v", entry.Action, entry.RequestID, err)
+	}
+}
+
+// audit records an action on an entity with the fields that changed between
+// its two versions; before is nil for creations and after for deletions
+func (h *Handler) audit(c *gin.Context, action string, entityType string, entityID interface{}, before, after interface{}) {
+	entry := auditEntry(c, action)
+	entry.EntityType = entityType
+	entry.EntityID = fmt.Sprint(entityID)
+	entry.Changes = service.AuditDiff(before, after)
+	h.recordAudit(c, entry)
+}
+
+// routeEntity names the resource an admin route acts on, e.g. "products"
+// and the :id parameter for /admin/products/:id
+func routeEntity(c *gin.Context) (string, string) {
+	route := c.FullPath()
+	if i := strings.Index(route, "/admin/"); i >= 0 {
+		route = route[i+len("/admin/"):]
+	}
+	entityType, _, _ := strings.Cut(route, "/")
+	return entityType, c.Param("id")
+}
+
+// AuditMiddleware records every request on the routes it guards that
+// changes something or is refused access, unless the handler recorded a
+// more specific entry. It must run before the role check so refused
+// requests are recorded too.
+func (h *Handler) AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		if c.GetBool(auditRecordedKey) {
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if status != http.StatusForbidden {
+				return
+			}
+		}
+
+		action := "admin.request"
+		if status == http.StatusForbidden {
+			action = "admin.access_denied"
+		}
+		entry := auditEntry(c, action)
+		entry.EntityType, entry.EntityID = routeEntity(c)
+		entry.Metadata = map[string]interface{}{"route": c.FullPath()}
+		entry.Status = status
+		h.recordAudit(c, entry)
+	}
+}
+
+// auditImpersonation records a request made with an impersonation token
+// that changes something, once it has been handled, unless an entry was
+// already recorded for it. Support agents act as the customer on ordinary
+// routes, so these are not covered by AuditMiddleware.
+func (h *Handler) auditImpersonation(c *gin.Context) {
+	sessionID := c.GetUint("impersonation_id")
+	if sessionID == 0 || c.GetBool(auditRecordedKey) {
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+
+	entry := auditEntry(c, "impersonation.request")
+	entry.EntityType = "user"
+	entry.EntityID = fmt.Sprint(c.GetUint("user_id"))
+	entry.Metadata = map[string]interface{}{
+		"session_id": sessionID,
+		"route":      c.FullPath(),
+	}
+	entry.Status = c.Writer.Status()
+	h.recordAudit(c, entry)
+}
+
+// auditFilter reads the audit log filters. Dates are UTC days and both from
+// and to are included.
+func auditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	filter := repository.AuditFilter{
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		RequestID:  c.Query("request_id"),
+	}
+
+	if v := c.Query("actor_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid actor_id %q", v)
+		}
+		filter.ActorID = uint(id)
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date %q", v)
+		}
+		filter.From = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date %q", v)
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// ListAuditLogs godoc
+// @Summary List audit log entries
+// @Description Get a page of audit log entries, newest first (admin only)
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query int false "User who acted"
+// @Param action query string false "Action, or a prefix ending in a dot such as auth."
+// @Param entity_type query string false "Entity type, e.g. product"
+// @Param entity_id query string false "Entity ID"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/audit-logs [get]
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, pageSize := h.pagination(c)
+	entries, total, err := h.service.ListEntries(filter, page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: entries, Total: total, Page: page, PageSize: pageSize}, "Audit log retrieved successfully")
+}
+
+// ExportAuditLogs godoc
+// @Summary Export audit log entries
+// @Description Download the entries matching the filters as JSON lines, oldest first (admin only)
+// @Tags audit
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param actor_id query int false "User who acted"
+// @Param action query string false "Action, or a prefix ending in a dot such as auth."
+// @Param entity_type query string false "Entity type, e.g. product"
+// @Param entity_id query string false "Entity ID"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Success 200 {file} file
+// @Router /admin/audit-logs/export [get]
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Buffer so failures can still be reported as JSON errors
+	var buf bytes.Buffer
+	if err := h.service.ExportEntries(filter, &buf); err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=audit-log.jsonl")
+	c.Data(http.StatusOK, "application/x-ndjson", buf.Bytes())
+}
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/sajal/go-ecommerce/internal/mail"
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/service"
 )
 
 type LoginInput struct {
@@ -14,18 +18,33 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
 type RegisterInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Name     string `json:"name" binding:"required"`
 }
 
+// UpdateUserInput holds the profile fields users may change themselves.
+// Omitted fields keep their value.
+type UpdateUserInput struct {
+	Name    *string `json:"name" binding:"omitempty,min=1"`
+	Address *string `json:"address"`
+	Phone   *string `json:"phone"`
+	Locale  *string `json:"locale"` // A language code with an optional region, e.g. en or de-at
+}
+
 // Register godoc
 // @Summary Register a new user
 // @Description Create a new user account
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param X-Cart-Token header string false "Guest cart token to merge into the new account"
 // @Param user body RegisterInput true "User registration details"
 // @Success 201 {object} Response
 // @Router /auth/register [post]
@@ -36,13 +55,6 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
-	var existingUser models.User
-	if err := h.db.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
-		h.errorResponse(c, http.StatusConflict, "Email already registered")
-		return
-	}
-
 	// Create user
 	user := models.User{
 		Email:    input.Email,
@@ -51,20 +63,34 @@ func (h *Handler) Register(c *gin.Context) {
 		Role:     "user",
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := h.userHandler.service.Register(&user); err != nil {
+		if err.Error() == "email already registered" {
+			h.errorResponse(c, http.StatusConflict, "Email already registered")
+			return
+		}
 		h.errorResponse(c, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	h.createdResponse(c, user)
+	h.auditLogin(c, "auth.register", &user, user.Email, "")
+
+	// Carry over the guest cart, if any
+	adjustments, err := h.cartHandler.service.MergeGuestCart(c.Request.Context(), c.GetHeader(cartTokenHeader), user.ID)
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, "Failed to merge cart")
+		return
+	}
+
+	h.createdResponse(c, gin.H{"user": user, "cart_adjustments": adjustments})
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return JWT token. A guest cart named by X-Cart-Token is merged into the user's cart.
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param X-Cart-Token header string false "Guest cart token to merge into the user's cart"
 // @Param credentials body LoginInput true "Login credentials"
 // @Success 200 {object} Response
 // @Router /auth/login [post]
@@ -78,31 +104,158 @@ func (h *Handler) Login(c *gin.Context) {
 	// Find user
 	var user models.User
 	if err := h.db.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		h.auditLogin(c, "auth.login_failed", nil, input.Email, "unknown email")
 		h.errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	// Check password
 	if !user.CheckPassword(input.Password) {
+		h.auditLogin(c, "auth.login_failed", &user, input.Email, "wrong password")
 		h.errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
+	// Refuse locked accounts
+	if _, err := h.userHandler.service.Authorize(c.Request.Context(), user.ID); err != nil {
+		h.auditLogin(c, "auth.login_failed", &user, input.Email, err.Error())
+		h.errorResponse(c, http.StatusForbidden, err.Error())
+		return
+	}
+
 	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	tokenString, err := h.signToken(jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
 		"role":    user.Role,
 		"exp":     time.Now().Add(24 * time.Hour).Unix(),
 	})
-
-	tokenString, err := token.SignedString([]byte("your-secret-key")) // Use config.JWTSecret in production
 	if err != nil {
 		h.errorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	h.successResponse(c, gin.H{"token": tokenString}, "Login successful")
+	// Carry over the guest cart, if any
+	adjustments, err := h.cartHandler.service.MergeGuestCart(c.Request.Context(), c.GetHeader(cartTokenHeader), user.ID)
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, "Failed to merge cart")
+		return
+	}
+
+	h.auditLogin(c, "auth.login", &user, input.Email, "")
+	h.successResponse(c, gin.H{"token": tokenString, "cart_adjustments": adjustments}, "Login successful")
+}
+
+// auditLogin records a sign-in attempt. The request is unauthenticated, so
+// the account, when one matched the email, is recorded as the actor.
+func (h *Handler) auditLogin(c *gin.Context, action string, user *models.User, email string, reason string) {
+	entry := auditEntry(c, action)
+	entry.EntityType = "user"
+	entry.Metadata = map[string]interface{}{"email": email}
+	if user != nil {
+		entry.ActorID = &user.ID
+		entry.ActorRole = user.Role
+		entry.EntityID = fmt.Sprint(user.ID)
+	}
+	if reason != "" {
+		entry.Metadata["reason"] = reason
+	}
+	h.recordAudit(c, entry)
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Choose a new password with the token from a password reset email. The token works once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param reset body ResetPasswordInput true "Reset token and new password"
+// @Success 200 {object} Response
+// @Router /auth/password-reset [post]
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	userID, err := h.userHandler.service.ResetPassword(input.Token, input.Password)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry := auditEntry(c, "auth.password_change")
+	entry.ActorID = &userID
+	entry.EntityType = "user"
+	entry.EntityID = fmt.Sprint(userID)
+	entry.Changes = map[string]models.AuditChange{"password": {From: service.AuditRedacted, To: service.AuditRedacted}}
+	entry.Metadata = map[string]interface{}{"via": "reset_link"}
+	h.recordAudit(c, entry)
+
+	h.successResponse(c, nil, "Password reset successfully")
+}
+
+// signToken issues a JWT with the given claims
+func (h *Handler) signToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte("your-secret-key")) // Use config.JWTSecret in production
+}
+
+// parseToken validates a JWT and returns its claims
+func (h *Handler) parseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte("your-secret-key"), nil // Use config.JWTSecret in production
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// authenticate checks the JWT of a request and stores the user in the
+// context. The account is looked up on every request so role changes, bans
+// and forced password resets apply to tokens issued earlier. On failure it
+// returns the response status and message.
+func (h *Handler) authenticate(c *gin.Context, tokenString string) (int, string) {
+	claims, err := h.parseToken(tokenString)
+	if err != nil {
+		return http.StatusUnauthorized, "Invalid token"
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return http.StatusUnauthorized, "Invalid token"
+	}
+
+	user, err := h.userHandler.service.Authorize(c.Request.Context(), uint(userID))
+	if err != nil {
+		if errors.Is(err, service.ErrAccountDisabled) || errors.Is(err, service.ErrAccountBanned) || errors.Is(err, service.ErrPasswordResetRequired) {
+			return http.StatusForbidden, err.Error()
+		}
+		return http.StatusUnauthorized, "Invalid token"
+	}
+
+	// Impersonation tokens work only while their session is open
+	if sessionID, ok := claims["impersonation_id"].(float64); ok {
+		session, err := h.customerHandler.service.ActiveImpersonation(uint(sessionID))
+		if err != nil || session.UserID != user.ID {
+			return http.StatusUnauthorized, "Impersonation session has ended"
+		}
+		c.Set("impersonator_id", session.ImpersonatorID)
+		c.Set("impersonation_id", session.ID)
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+	return 0, ""
 }
 
 // AuthMiddleware is a middleware to check JWT token
@@ -115,20 +268,33 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte("your-secret-key"), nil // Use config.JWTSecret in production
-		})
-
-		if err != nil || !token.Valid {
-			h.errorResponse(c, http.StatusUnauthorized, "Invalid token")
+		if status, message := h.authenticate(c, tokenString); status != 0 {
+			h.errorResponse(c, status, message)
 			c.Abort()
 			return
 		}
+		c.Next()
+		h.auditImpersonation(c)
+	}
+}
 
-		claims := token.Claims.(jwt.MapClaims)
-		c.Set("user_id", uint(claims["user_id"].(float64)))
-		c.Set("user_role", claims["role"].(string))
+// OptionalAuthMiddleware sets the user context when a valid JWT token is
+// present but lets anonymous requests through, for routes guests may use
+func (h *Handler) OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.GetHeader("Authorization")
+		if tokenString == "" {
+			c.Next()
+			return
+		}
+
+		if status, message := h.authenticate(c, tokenString); status != 0 {
+			h.errorResponse(c, status, message)
+			c.Abort()
+			return
+		}
 		c.Next()
+		h.auditImpersonation(c)
 	}
 }
 
@@ -145,6 +311,19 @@ func (h *Handler) AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
+// StaffMiddleware lets admins and support agents through
+func (h *Handler) StaffMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("user_role")
+		if role != models.RoleAdmin && role != models.RoleSupport {
+			h.errorResponse(c, http.StatusForbidden, "Staff access required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // GetCurrentUser godoc
 // @Summary Get current user
 // @Description Get details of the currently authenticated user
@@ -173,7 +352,7 @@ func (h *Handler) GetCurrentUser(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param user body models.User true "Updated user details"
+// @Param user body UpdateUserInput true "Updated profile fields"
 // @Success 200 {object} Response
 // @Router /users/me [put]
 func (h *Handler) UpdateUser(c *gin.Context) {
@@ -185,18 +364,50 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&user); err != nil {
+	// Staff acting as the customer must not take over the account
+	if c.GetUint("impersonator_id") != 0 {
+		h.errorResponse(c, http.StatusForbidden, "Profile changes are not allowed while impersonating")
+		return
+	}
+
+	var input UpdateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
 		return
 	}
+	if input.Locale != nil && *input.Locale != "" && !mail.ValidLocale(*input.Locale) {
+		h.errorResponse(c, http.StatusBadRequest, "Locale must be a language code such as en or de-at")
+		return
+	}
 
-	// Prevent role update
-	user.Role = "user"
+	// Only the profile columns of the signed-in user are written, whatever
+	// else the body contains
+	fields := map[string]interface{}{}
+	if input.Name != nil {
+		fields["name"] = *input.Name
+	}
+	if input.Address != nil {
+		fields["address"] = *input.Address
+	}
+	if input.Phone != nil {
+		fields["phone"] = *input.Phone
+	}
+	if input.Locale != nil {
+		fields["locale"] = *input.Locale
+	}
 
-	if err := h.db.Save(&user).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to update user")
-		return
+	existing := user
+	if len(fields) > 0 {
+		if err := h.db.Model(&models.User{}).Where("id = ?", userID).Updates(fields).Error; err != nil {
+			h.errorResponse(c, http.StatusInternalServerError, "Failed to update user")
+			return
+		}
+		if err := h.db.First(&user, userID).Error; err != nil {
+			h.errorResponse(c, http.StatusInternalServerError, "Failed to update user")
+			return
+		}
 	}
+	h.audit(c, "user.update", "user", user.ID, existing, user)
 
 	h.successResponse(c, user, "User updated successfully")
 }
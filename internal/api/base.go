@@ -2,10 +2,16 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
@@ -13,6 +19,31 @@ type Response struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// PagedData wraps one page of a listing
+type PagedData struct {
+	Items    interface{} `json:"items"`
+	Total    int64       `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+// pagination reads the page and page_size query parameters, falling back to
+// the first page of defaultPageSize items
+func (h *Handler) pagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
 func (h *Handler) successResponse(c *gin.Context, data interface{}, message string) {
 	c.JSON(http.StatusOK, Response{
 		Success: true,
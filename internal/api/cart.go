@@ -2,90 +2,122 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/service"
 )
 
+// cartTokenHeader carries the opaque token of a guest cart
+const cartTokenHeader = "X-Cart-Token"
+
+type AddToCartInput struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+type UpdateCartItemInput struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// cartKey identifies the cart of the caller: the user's cart when
+// authenticated, otherwise the guest cart named by the cart token header
+func cartKey(c *gin.Context) service.CartKey {
+	return service.CartKey{
+		UserID: c.GetUint("user_id"),
+		Token:  c.GetHeader(cartTokenHeader),
+	}
+}
+
 // GetCart godoc
-// @Summary Get user's cart
-// @Description Get the current user's shopping cart with all items
+// @Summary Get cart
+// @Description Get the current user's or guest's shopping cart repriced against the catalog. Lines whose price, availability or stock changed carry warnings.
 // @Tags cart
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param X-Cart-Token header string false "Guest cart token"
+// @Param X-Currency header string false "Currency to show prices in"
 // @Success 200 {object} Response
 // @Router /cart [get]
-func (h *Handler) GetCart(c *gin.Context) {
-	userID := c.GetUint("user_id") // Set by auth middleware
-	var cart models.Cart
+func (h *CartHandler) GetCart(c *gin.Context) {
+	cart, err := h.service.GetCart(c.Request.Context(), cartKey(c))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
 
-	if err := h.db.Preload("Items.Product").First(&cart, "user_id = ?", userID).Error; err != nil {
-		h.errorResponse(c, http.StatusNotFound, "Cart not found")
+	if !h.localizeCart(c, cart) {
 		return
 	}
 
 	h.successResponse(c, cart, "Cart retrieved successfully")
 }
 
+// AcknowledgeCartChanges godoc
+// @Summary Acknowledge cart changes
+// @Description Accept price changes and drop or reduce lines that are unavailable or out of stock, so the cart can be checked out
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Cart-Token header string false "Guest cart token"
+// @Param X-Currency header string false "Currency to show prices in"
+// @Success 200 {object} Response
+// @Router /cart/acknowledge [post]
+func (h *CartHandler) AcknowledgeCartChanges(c *gin.Context) {
+	cart, err := h.service.AcknowledgeChanges(c.Request.Context(), cartKey(c))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !h.localizeCart(c, cart) {
+		return
+	}
+
+	h.successResponse(c, cart, "Cart changes acknowledged")
+}
+
 // AddToCart godoc
 // @Summary Add item to cart
-// @Description Add a product to the user's shopping cart
+// @Description Add a product to the cart. Guests without a cart token get a new guest cart whose token is returned in the X-Cart-Token header.
 // @Tags cart
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param item body models.CartItem true "Cart item details"
+// @Param X-Cart-Token header string false "Guest cart token"
+// @Param X-Currency header string false "Currency to show prices in"
+// @Param item body AddToCartInput true "Cart item details"
 // @Success 200 {object} Response
 // @Router /cart/items [post]
-func (h *Handler) AddToCart(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	var cartItem models.CartItem
-	if err := c.ShouldBindJSON(&cartItem); err != nil {
+func (h *CartHandler) AddToCart(c *gin.Context) {
+	var input AddToCartInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	// Get or create cart
-	var cart models.Cart
-	if err := h.db.FirstOrCreate(&cart, models.Cart{UserID: userID}).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to get/create cart")
+	cart, err := h.service.AddToCart(c.Request.Context(), cartKey(c), input.ProductID, input.Quantity)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	// Check if product exists
-	var product models.Product
-	if err := h.db.First(&product, cartItem.ProductID).Error; err != nil {
-		h.errorResponse(c, http.StatusNotFound, "Product not found")
-		return
+	if cart.Token != "" {
+		c.Header(cartTokenHeader, cart.Token)
 	}
 
-	// Check if item already exists in cart
-	var existingItem models.CartItem
-	if err := h.db.Where("cart_id = ? AND product_id = ?", cart.ID, cartItem.ProductID).First(&existingItem).Error; err == nil {
-		// Update quantity if item exists
-		existingItem.Quantity += cartItem.Quantity
-		existingItem.Price = product.Price
-		existingItem.Subtotal = float64(existingItem.Quantity) * existingItem.Price
-		if err := h.db.Save(&existingItem).Error; err != nil {
-			h.errorResponse(c, http.StatusInternalServerError, "Failed to update cart item")
-			return
-		}
-		h.successResponse(c, existingItem, "Cart item updated successfully")
+	cart, err = h.service.GetCart(c.Request.Context(), service.CartKey{UserID: c.GetUint("user_id"), Token: cart.Token})
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Add new item
-	cartItem.CartID = cart.ID
-	cartItem.Price = product.Price
-	cartItem.Subtotal = float64(cartItem.Quantity) * cartItem.Price
-
-	if err := h.db.Create(&cartItem).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to add item to cart")
+	if !h.localizeCart(c, cart) {
 		return
 	}
 
-	h.successResponse(c, cartItem, "Item added to cart successfully")
+	h.successResponse(c, cart, "Item added to cart successfully")
 }
 
 // UpdateCartItem godoc
@@ -95,32 +127,41 @@ func (h *Handler) AddToCart(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param X-Cart-Token header string false "Guest cart token"
+// @Param X-Currency header string false "Currency to show prices in"
 // @Param id path int true "Cart Item ID"
-// @Param item body models.CartItem true "Updated cart item details"
+// @Param item body UpdateCartItemInput true "Updated cart item details"
 // @Success 200 {object} Response
 // @Router /cart/items/{id} [put]
-func (h *Handler) UpdateCartItem(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	itemID := c.Param("id")
-
-	var cartItem models.CartItem
-	if err := h.db.Joins("Cart").Where("cart_items.id = ? AND cart.user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
-		h.errorResponse(c, http.StatusNotFound, "Cart item not found")
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid cart item ID")
 		return
 	}
 
-	if err := c.ShouldBindJSON(&cartItem); err != nil {
+	var input UpdateCartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	cartItem.Subtotal = float64(cartItem.Quantity) * cartItem.Price
-	if err := h.db.Save(&cartItem).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to update cart item")
+	if err := h.service.UpdateCartItem(c.Request.Context(), cartKey(c), uint(itemID), input.Quantity); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	h.successResponse(c, cartItem, "Cart item updated successfully")
+	cart, err := h.service.GetCart(c.Request.Context(), cartKey(c))
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !h.localizeCart(c, cart) {
+		return
+	}
+
+	h.successResponse(c, cart, "Cart item updated successfully")
 }
 
 // RemoveFromCart godoc
@@ -130,15 +171,19 @@ func (h *Handler) UpdateCartItem(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param X-Cart-Token header string false "Guest cart token"
 // @Param id path int true "Cart Item ID"
 // @Success 204 "No Content"
 // @Router /cart/items/{id} [delete]
-func (h *Handler) RemoveFromCart(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	itemID := c.Param("id")
+func (h *CartHandler) RemoveFromCart(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid cart item ID")
+		return
+	}
 
-	if err := h.db.Joins("Cart").Where("cart_items.id = ? AND cart.user_id = ?", itemID, userID).Delete(&models.CartItem{}).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to remove item from cart")
+	if err := h.service.RemoveFromCart(c.Request.Context(), cartKey(c), uint(itemID)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
 		return
 	}
 
@@ -152,13 +197,12 @@ func (h *Handler) RemoveFromCart(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param X-Cart-Token header string false "Guest cart token"
 // @Success 204 "No Content"
 // @Router /cart [delete]
-func (h *Handler) ClearCart(c *gin.Context) {
-	userID := c.GetUint("user_id")
-
-	if err := h.db.Exec("DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id = ?)", userID).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to clear cart")
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	if err := h.service.ClearCart(c.Request.Context(), cartKey(c)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
 		return
 	}
 
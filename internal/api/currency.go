@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/currency"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+)
+
+// currencyHeader selects the currency prices are shown and charged in, as
+// does the currency query parameter
+const currencyHeader = "X-Currency"
+
+// currencyKey holds the currency of the request in the gin context
+const currencyKey = "currency"
+
+// rateRefreshTimeout bounds a refresh of the exchange rates from a request
+const rateRefreshTimeout = 30 * time.Second
+
+type ProductPriceInput struct {
+	Price money.Money `json:"price"`
+}
+
+// CurrencyMiddleware resolves the currency the customer chose, defaulting to
+// the base currency, and refuses currencies the store does not support
+func (h *Handler) CurrencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := c.Query("currency")
+		if code == "" {
+			code = c.GetHeader(currencyHeader)
+		}
+
+		code, err := h.currencies.Resolve(code)
+		if err != nil {
+			h.errorResponse(c, http.StatusBadRequest, err.Error())
+			c.Abort()
+			return
+		}
+		c.Set(currencyKey, code)
+		c.Next()
+	}
+}
+
+// currency returns the currency of the request
+func (h *Handler) currency(c *gin.Context) string {
+	if code := c.GetString(currencyKey); code != "" {
+		return code
+	}
+	return h.currencies.Base()
+}
+
+// localizeProducts sets the display prices of products in the request's
+// currency. It reports false after responding with an error.
+func (h *Handler) localizeProducts(c *gin.Context, products []models.Product) bool {
+	if err := h.currencies.LocalizeProducts(c.Request.Context(), products, h.currency(c)); err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// localizeCart sets the display prices of a cart in the request's currency.
+// It reports false after responding with an error.
+func (h *Handler) localizeCart(c *gin.Context, cart *models.Cart) bool {
+	if err := h.currencies.LocalizeCart(c.Request.Context(), cart, h.currency(c)); err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// productPrice returns a product's override in a currency, or nil
+func (h *CurrencyHandler) productPrice(productID uint, code string) *models.ProductPrice {
+	prices, err := h.service.ProductPrices(productID)
+	if err != nil {
+		return nil
+	}
+	code = currency.Normalize(code)
+	for i := range prices {
+		if prices[i].Currency == code {
+			return &prices[i]
+		}
+	}
+	return nil
+}
+
+// ListCurrencies godoc
+// @Summary List currencies
+// @Description Get the store's base currency, the currencies prices can be shown and charged in, and their exchange rates. Pick one with the currency query parameter or the X-Currency header.
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response
+// @Router /currencies [get]
+func (h *CurrencyHandler) ListCurrencies(c *gin.Context) {
+	rates := map[string]float64{}
+	for _, code := range h.service.Supported() {
+		if rate, err := h.service.Rate(code); err == nil {
+			rates[code] = rate
+		}
+	}
+
+	h.successResponse(c, gin.H{
+		"base":       h.service.Base(),
+		"currencies": h.service.Supported(),
+		"rates":      rates,
+	}, "Currencies retrieved successfully")
+}
+
+// ListExchangeRates godoc
+// @Summary List exchange rates
+// @Description Get the stored exchange rates with their source and the time they were fetched (admin only)
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /admin/exchange-rates [get]
+func (h *CurrencyHandler) ListExchangeRates(c *gin.Context) {
+	rates, err := h.service.Rates()
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, rates, "Exchange rates retrieved successfully")
+}
+
+// RefreshExchangeRates godoc
+// @Summary Refresh exchange rates
+// @Description Fetch the exchange rates of the supported currencies from the configured rate source now (admin only)
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /admin/exchange-rates/refresh [post]
+func (h *CurrencyHandler) RefreshExchangeRates(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), rateRefreshTimeout)
+	defer cancel()
+
+	if err := h.service.Refresh(ctx); err != nil {
+		h.errorResponse(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	rates, err := h.service.Rates()
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, rates, "Exchange rates refreshed")
+}
+
+// ListProductPrices godoc
+// @Summary List a product's currency prices
+// @Description Get the prices that replace the converted price of a product in other currencies (admin only)
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} Response
+// @Router /admin/products/{id}/prices [get]
+func (h *CurrencyHandler) ListProductPrices(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	prices, err := h.service.ProductPrices(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, prices, "Product prices retrieved successfully")
+}
+
+// SetProductPrice godoc
+// @Summary Set a product's price in a currency
+// @Description Replace the converted price of a product in a currency other than the base. The override is not used while the product is on sale (admin only).
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param currency path string true "Currency code"
+// @Param price body ProductPriceInput true "Price in the currency"
+// @Success 200 {object} Response
+// @Router /admin/products/{id}/prices/{currency} [put]
+func (h *CurrencyHandler) SetProductPrice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var input ProductPriceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	if _, err := h.productHandler.service.GetProduct(c.Request.Context(), uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	before := h.productPrice(uint(id), c.Param("currency"))
+	price, err := h.service.SetProductPrice(uint(id), c.Param("currency"), input.Price)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "product_price.set", "product", uint(id), before, price)
+
+	h.successResponse(c, price, "Product price saved")
+}
+
+// DeleteProductPrice godoc
+// @Summary Remove a product's price in a currency
+// @Description Go back to converting the product's base price into the currency (admin only)
+// @Tags currencies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param currency path string true "Currency code"
+// @Success 204 "No Content"
+// @Router /admin/products/{id}/prices/{currency} [delete]
+func (h *CurrencyHandler) DeleteProductPrice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	before := h.productPrice(uint(id), c.Param("currency"))
+	if err := h.service.DeleteProductPrice(uint(id), c.Param("currency")); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	h.audit(c, "product_price.delete", "product", uint(id), before, nil)
+
+	h.noContentResponse(c)
+}
@@ -0,0 +1,419 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+type RoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type AccountStatusInput struct {
+	Status models.UserStatus `json:"status" binding:"required"`
+	Reason string            `json:"reason"` // Required to disable or ban
+}
+
+type ImpersonateInput struct {
+	Reason  string `json:"reason" binding:"required"`
+	Minutes int    `json:"minutes" binding:"min=0,max=60"` // Defaults to 15
+}
+
+// customerID parses the user ID path parameter
+func customerID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	return uint(id), err == nil
+}
+
+// ListCustomers godoc
+// @Summary List users
+// @Description Get a page of accounts, newest first (staff only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Part of the name or email"
+// @Param role query string false "user, support or admin"
+// @Param status query string false "active, disabled or banned"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/users [get]
+func (h *CustomerHandler) ListCustomers(c *gin.Context) {
+	page, pageSize := h.pagination(c)
+	filter := repository.UserFilter{
+		Search: c.Query("q"),
+		Role:   c.Query("role"),
+		Status: models.UserStatus(c.Query("status")),
+	}
+
+	users, total, err := h.service.ListCustomers(filter, page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: users, Total: total, Page: page, PageSize: pageSize}, "Users retrieved successfully")
+}
+
+// GetCustomer godoc
+// @Summary Get a user
+// @Description Get an account (staff only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} Response
+// @Router /admin/users/{id} [get]
+func (h *CustomerHandler) GetCustomer(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.service.GetCustomer(id)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, user, "User retrieved successfully")
+}
+
+// ListCustomerOrders godoc
+// @Summary List a user's orders
+// @Description Get a page of a customer's orders, newest first (staff only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/users/{id}/orders [get]
+func (h *CustomerHandler) ListCustomerOrders(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	page, pageSize := h.pagination(c)
+	orders, total, err := h.service.CustomerOrders(id, page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: orders, Total: total, Page: page, PageSize: pageSize}, "Orders retrieved successfully")
+}
+
+// ListCustomerAddresses godoc
+// @Summary List a user's addresses
+// @Description Get a customer's saved addresses (staff only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} Response
+// @Router /admin/users/{id}/addresses [get]
+func (h *CustomerHandler) ListCustomerAddresses(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	addresses, err := h.service.CustomerAddresses(id)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, addresses, "Addresses retrieved successfully")
+}
+
+// ListCustomerReviews godoc
+// @Summary List a user's reviews
+// @Description Get a customer's reviews in any moderation state (staff only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} Response
+// @Router /admin/users/{id}/reviews [get]
+func (h *CustomerHandler) ListCustomerReviews(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	reviews, err := h.service.CustomerReviews(id)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, reviews, "Reviews retrieved successfully")
+}
+
+// ChangeCustomerRole godoc
+// @Summary Change a user's role
+// @Description Make an account a customer, support agent or admin. Takes effect on the account's next request (admin only).
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param role body RoleInput true "user, support or admin"
+// @Success 200 {object} Response
+// @Router /admin/users/{id}/role [put]
+func (h *CustomerHandler) ChangeCustomerRole(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var input RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	before, _ := h.service.GetCustomer(id)
+	user, err := h.service.ChangeRole(c.GetUint("user_id"), id, input.Role)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "user.role_change", "user", id, before, user)
+
+	h.successResponse(c, user, "Role updated successfully")
+}
+
+// SetCustomerStatus godoc
+// @Summary Activate, disable or ban a user
+// @Description Disabled and banned accounts cannot sign in, and tokens issued earlier stop working (admin only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param status body AccountStatusInput true "New status and reason"
+// @Success 200 {object} Response
+// @Router /admin/users/{id}/status [put]
+func (h *CustomerHandler) SetCustomerStatus(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var input AccountStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	before, _ := h.service.GetCustomer(id)
+	user, err := h.service.SetStatus(c.GetUint("user_id"), id, input.Status, input.Reason)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "user.status_change", "user", id, before, user)
+
+	h.successResponse(c, user, "Account status updated successfully")
+}
+
+// ForceCustomerPasswordReset godoc
+// @Summary Force a password reset
+// @Description Lock the account until the customer sets a new password through the link emailed to them (admin only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 202 {object} Response
+// @Router /admin/users/{id}/password-reset [post]
+func (h *CustomerHandler) ForceCustomerPasswordReset(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.service.ForcePasswordReset(c.GetUint("user_id"), id); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "user.password_reset_forced", "user", id, nil, nil)
+
+	c.JSON(http.StatusAccepted, Response{Success: true, Message: "Password reset email queued"})
+}
+
+// DeleteCustomer godoc
+// @Summary Delete a user
+// @Description Delete an account; its orders are kept (admin only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Router /admin/users/{id} [delete]
+func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	before, _ := h.service.GetCustomer(id)
+	if err := h.service.DeleteCustomer(c.GetUint("user_id"), id); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "user.delete", "user", id, before, nil)
+
+	h.noContentResponse(c)
+}
+
+// ImpersonateCustomer godoc
+// @Summary Impersonate a customer
+// @Description Get a token that acts as the customer until the session expires or is ended. The session and the reason are recorded (staff only).
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param impersonation body ImpersonateInput true "Reason and session length in minutes"
+// @Success 201 {object} Response
+// @Router /admin/users/{id}/impersonate [post]
+func (h *CustomerHandler) ImpersonateCustomer(c *gin.Context) {
+	id, ok := customerID(c)
+	if !ok {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var input ImpersonateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	// Staff cannot chain impersonations
+	if c.GetUint("impersonator_id") != 0 {
+		h.errorResponse(c, http.StatusForbidden, "Already impersonating")
+		return
+	}
+
+	session, err := h.service.StartImpersonation(c.GetUint("user_id"), id, input.Reason, time.Duration(input.Minutes)*time.Minute)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry := auditEntry(c, "impersonation.start")
+	entry.EntityType = "user"
+	entry.EntityID = fmt.Sprint(id)
+	entry.Metadata = map[string]interface{}{
+		"session_id": session.ID,
+		"reason":     session.Reason,
+		"expires_at": session.ExpiresAt,
+	}
+	h.recordAudit(c, entry)
+
+	token, err := h.signToken(jwt.MapClaims{
+		"user_id":          session.UserID,
+		"email":            session.User.Email,
+		"role":             session.User.Role,
+		"impersonation_id": session.ID,
+		"exp":              session.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	h.createdResponse(c, gin.H{"token": token, "session": session})
+}
+
+// EndImpersonation godoc
+// @Summary End an impersonation session
+// @Description Stop an impersonation token from working before it expires (staff only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Success 200 {object} Response
+// @Router /admin/impersonations/{id}/end [post]
+func (h *CustomerHandler) EndImpersonation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := h.service.EndImpersonation(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	entry := auditEntry(c, "impersonation.end")
+	entry.EntityType = "user"
+	entry.EntityID = fmt.Sprint(session.UserID)
+	entry.Metadata = map[string]interface{}{"session_id": session.ID}
+	h.recordAudit(c, entry)
+
+	h.successResponse(c, session, "Impersonation ended")
+}
+
+// ListImpersonations godoc
+// @Summary List impersonation sessions
+// @Description Get a page of impersonation sessions with who acted as whom and why, newest first (admin only)
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query int false "Only sessions impersonating this user"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/impersonations [get]
+func (h *CustomerHandler) ListImpersonations(c *gin.Context) {
+	var userID uint
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			h.errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		userID = uint(id)
+	}
+
+	page, pageSize := h.pagination(c)
+	sessions, total, err := h.service.ListImpersonations(userID, page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: sessions, Total: total, Page: page, PageSize: pageSize}, "Impersonation sessions retrieved successfully")
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEmailTemplates godoc
+// @Summary List email templates
+// @Description Get the names of the transactional email templates (admin only)
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /admin/emails/templates [get]
+func (h *EmailHandler) ListEmailTemplates(c *gin.Context) {
+	h.successResponse(c, h.service.TemplateNames(), "Email templates retrieved successfully")
+}
+
+// PreviewEmailTemplate godoc
+// @Summary Preview an email template
+// @Description Render a template with sample data. format=html or text returns the body alone (admin only).
+// @Tags emails
+// @Produce json
+// @Produce html
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param locale query string false "Locale, e.g. en or de"
+// @Param format query string false "json (default), html or text"
+// @Success 200 {object} Response
+// @Router /admin/emails/templates/{name}/preview [get]
+func (h *EmailHandler) PreviewEmailTemplate(c *gin.Context) {
+	msg, err := h.service.PreviewTemplate(c.Param("name"), c.Query("locale"))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.String(http.StatusOK, msg.Text)
+	default:
+		h.successResponse(c, msg, "Email preview rendered successfully")
+	}
+}
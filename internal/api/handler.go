@@ -1,19 +1,44 @@
 package api
 
 import (
+	"context"
+	"strings"
+
+	"github.com/sajal/go-ecommerce/internal/config"
+	"github.com/sajal/go-ecommerce/internal/documents"
+	"github.com/sajal/go-ecommerce/internal/events"
+	"github.com/sajal/go-ecommerce/internal/health"
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/mail"
 	"github.com/sajal/go-ecommerce/internal/repository"
 	"github.com/sajal/go-ecommerce/internal/service"
+	"github.com/sajal/go-ecommerce/internal/storage"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db             *gorm.DB
-	productHandler *ProductHandler
-	userHandler    *UserHandler
-	cartHandler    *CartHandler
-	orderHandler   *OrderHandler
-	reviewHandler  *ReviewHandler
-	addressHandler *AddressHandler
+	db               *gorm.DB
+	config           *config.Config
+	currencies       *service.CurrencyService
+	health           *health.Checker
+	productHandler   *ProductHandler
+	userHandler      *UserHandler
+	cartHandler      *CartHandler
+	orderHandler     *OrderHandler
+	reviewHandler    *ReviewHandler
+	addressHandler   *AddressHandler
+	wishlistHandler  *WishlistHandler
+	searchHandler    *SearchHandler
+	importHandler    *ProductImportHandler
+	jobHandler       *JobHandler
+	webhookHandler   *WebhookHandler
+	emailHandler     *EmailHandler
+	invoiceHandler   *InvoiceHandler
+	analyticsHandler *AnalyticsHandler
+	customerHandler  *CustomerHandler
+	auditHandler     *AuditHandler
+	priceHandler     *PriceHandler
+	currencyHandler  *CurrencyHandler
 }
 
 type UserHandler struct {
@@ -41,6 +66,66 @@ type AddressHandler struct {
 	service *service.AddressService
 }
 
+type WishlistHandler struct {
+	*Handler
+	service *service.WishlistService
+}
+
+type SearchHandler struct {
+	*Handler
+	service *service.SearchService
+}
+
+type ProductImportHandler struct {
+	*Handler
+	service *service.ProductImportService
+}
+
+type JobHandler struct {
+	*Handler
+	service *service.JobService
+}
+
+type WebhookHandler struct {
+	*Handler
+	service *service.WebhookService
+}
+
+type EmailHandler struct {
+	*Handler
+	service *service.EmailService
+}
+
+type InvoiceHandler struct {
+	*Handler
+	service *service.InvoiceService
+}
+
+type AnalyticsHandler struct {
+	*Handler
+	service *service.AnalyticsService
+}
+
+type CustomerHandler struct {
+	*Handler
+	service *service.CustomerService
+}
+
+type AuditHandler struct {
+	*Handler
+	service *service.AuditService
+}
+
+type PriceHandler struct {
+	*Handler
+	service *service.PriceService
+}
+
+type CurrencyHandler struct {
+	*Handler
+	service *service.CurrencyService
+}
+
 func NewUserHandler(handler *Handler, service *service.UserService) *UserHandler {
 	return &UserHandler{
 		Handler: handler,
@@ -76,7 +161,106 @@ func NewAddressHandler(handler *Handler, service *service.AddressService) *Addre
 	}
 }
 
-func NewHandler(db *gorm.DB) *Handler {
+func NewWishlistHandler(handler *Handler, service *service.WishlistService) *WishlistHandler {
+	return &WishlistHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewSearchHandler(handler *Handler, service *service.SearchService) *SearchHandler {
+	return &SearchHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewProductImportHandler(handler *Handler, service *service.ProductImportService) *ProductImportHandler {
+	return &ProductImportHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewJobHandler(handler *Handler, service *service.JobService) *JobHandler {
+	return &JobHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewWebhookHandler(handler *Handler, service *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewEmailHandler(handler *Handler, service *service.EmailService) *EmailHandler {
+	return &EmailHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewInvoiceHandler(handler *Handler, service *service.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewAnalyticsHandler(handler *Handler, service *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewCustomerHandler(handler *Handler, service *service.CustomerService) *CustomerHandler {
+	return &CustomerHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewAuditHandler(handler *Handler, service *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewPriceHandler(handler *Handler, service *service.PriceService) *PriceHandler {
+	return &PriceHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+func NewCurrencyHandler(handler *Handler, service *service.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{
+		Handler: handler,
+		service: service,
+	}
+}
+
+// newMailer builds the mail backend selected in the configuration
+func newMailer(cfg *config.Config) mail.Mailer {
+	if cfg.MailBackend == "smtp" {
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	return mail.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+}
+
+// NewHandler wires the services together, registers their background jobs
+// on queue and subscribes them to domain events. The caller starts both, as
+// well as the audit log pruning, the exchange rate refresh and the handler
+// itself. Readiness probes report the checks registered on checker.
+func NewHandler(db *gorm.DB, cfg *config.Config, queue *jobs.Queue, dispatcher *events.Dispatcher, auditService *service.AuditService, currencyService *service.CurrencyService, checker *health.Checker) *Handler {
+	// Initialize file storage
+	privateStore := storage.NewLocalStore(cfg.DataDir, "")
+
 	// Initialize repositories
 	productRepo := repository.NewProductRepository(db)
 	userRepo := repository.NewUserRepository(db)
@@ -84,18 +268,55 @@ func NewHandler(db *gorm.DB) *Handler {
 	orderRepo := repository.NewOrderRepository(db)
 	reviewRepo := repository.NewReviewRepository(db)
 	addressRepo := repository.NewAddressRepository(db)
+	wishlistRepo := repository.NewWishlistRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	importRepo := repository.NewProductImportRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(db)
+	analyticsRepo := repository.NewAnalyticsRepository(db, currencyService.Base())
+	impersonationRepo := repository.NewImpersonationRepository(db)
+	priceRepo := repository.NewPriceRepository(db)
 
 	// Initialize services
-	productService := service.NewProductService(productRepo)
+	productService := service.NewProductService(productRepo, currencyService.Base())
 	userService := service.NewUserService(userRepo)
-	cartService := service.NewCartService(cartRepo, productRepo)
-	orderService := service.NewOrderService(orderRepo, cartRepo)
-	reviewService := service.NewReviewService(reviewRepo, productRepo, orderRepo)
-	addressService := service.NewAddressService(addressRepo)
+	cartService := service.NewCartService(cartRepo, productRepo, currencyService.Base())
+	reviewService := service.NewReviewService(reviewRepo, productRepo, orderRepo, privateStore)
+	addressService := service.NewAddressService(addressRepo, service.NewPostalCodeValidator())
+	orderService := service.NewOrderService(orderRepo, cartService, addressService, currencyService)
+
+	searchService := service.NewSearchService(searchRepo, productRepo, categoryRepo)
+	importService := service.NewProductImportService(importRepo, productService, productRepo, categoryRepo, privateStore, queue)
+	jobService := service.NewJobService(jobRepo)
+	webhookService := service.NewWebhookService(webhookRepo, queue)
+	seller := documents.Seller{Name: cfg.StoreName, Address: strings.ReplaceAll(cfg.StoreAddress, ";", "\n")}
+	invoiceService := service.NewInvoiceService(invoiceRepo, orderRepo, privateStore, seller)
+	analyticsService := service.NewAnalyticsService(analyticsRepo)
+	emailService := service.NewEmailService(mail.NewTemplates(cfg.EmailTemplateDir), newMailer(cfg), queue, orderRepo, cfg.StoreName, cfg.StoreURL)
+	wishlistService := service.NewWishlistService(wishlistRepo, productRepo, cartService, service.NewEmailNotifier(emailService), queue)
+	priceService := service.NewPriceService(priceRepo, productService, queue)
+	customerService := service.NewCustomerService(userRepo, userService, orderRepo, addressRepo, reviewRepo, impersonationRepo, emailService, cfg.StoreURL)
+
+	// Alert wishlist owners about price drops and restocks
+	productService.AddObserver(wishlistService)
+
+	// Keep search suggestions in step with the catalog
+	productService.AddObserver(searchService)
+
+	// Deliver domain events to merchant webhooks
+	dispatcher.Subscribe("webhooks", webhookService.HandleEvent)
+
+	// Send transactional emails
+	dispatcher.Subscribe("emails", emailService.HandleEvent, service.EmailEventTypes...)
 
 	// Create base handler
 	handler := &Handler{
-		db: db,
+		db:         db,
+		config:     cfg,
+		currencies: currencyService,
+		health:     checker,
 	}
 
 	// Initialize specific handlers
@@ -105,6 +326,29 @@ func NewHandler(db *gorm.DB) *Handler {
 	handler.orderHandler = NewOrderHandler(handler, orderService)
 	handler.reviewHandler = NewReviewHandler(handler, reviewService)
 	handler.addressHandler = NewAddressHandler(handler, addressService)
+	handler.wishlistHandler = NewWishlistHandler(handler, wishlistService)
+	handler.searchHandler = NewSearchHandler(handler, searchService)
+	handler.importHandler = NewProductImportHandler(handler, importService)
+	handler.jobHandler = NewJobHandler(handler, jobService)
+	handler.webhookHandler = NewWebhookHandler(handler, webhookService)
+	handler.emailHandler = NewEmailHandler(handler, emailService)
+	handler.invoiceHandler = NewInvoiceHandler(handler, invoiceService)
+	handler.analyticsHandler = NewAnalyticsHandler(handler, analyticsService)
+	handler.customerHandler = NewCustomerHandler(handler, customerService)
+	handler.auditHandler = NewAuditHandler(handler, auditService)
+	handler.priceHandler = NewPriceHandler(handler, priceService)
+	handler.currencyHandler = NewCurrencyHandler(handler, currencyService)
 
 	return handler
 }
+
+// Start rebuilds the search suggestion index now and then periodically until
+// ctx is cancelled
+func (h *Handler) Start(ctx context.Context) {
+	h.searchHandler.service.Start(ctx)
+}
+
+// Wait blocks until the work started by Start has stopped
+func (h *Handler) Wait() {
+	h.searchHandler.service.Wait()
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/health"
+)
+
+// Healthz godoc
+// @Summary Liveness probe
+// @Description Answers as long as the process serves requests
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Checks the database, the schema migrations and the background subsystems and reports the status of each. Fails while the server shuts down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func (h *Handler) Readyz(c *gin.Context) {
+	report := h.health.Run(c.Request.Context())
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
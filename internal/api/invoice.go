@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendPDF responds with a PDF download
+func sendPDF(c *gin.Context, filename string, data []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// GetInvoice godoc
+// @Summary Download an order invoice
+// @Description Download the PDF invoice of one of the current user's orders. The invoice is issued with the next number on first download and never changes afterwards.
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Router /orders/{id}/invoice [get]
+func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	invoice, data, err := h.service.GetInvoice(uint(id), c.GetUint("user_id"))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendPDF(c, invoice.Number+".pdf", data)
+}
+
+// GetPackingSlip godoc
+// @Summary Download an order packing slip
+// @Description Download the packing slip of one of the current user's orders
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Router /orders/{id}/packing-slip [get]
+func (h *InvoiceHandler) GetPackingSlip(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	data, err := h.service.GetPackingSlip(uint(id), c.GetUint("user_id"))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendPDF(c, fmt.Sprintf("packing-slip-%d.pdf", id), data)
+}
+
+// GetOrderInvoice godoc
+// @Summary Download any order invoice
+// @Description Download the PDF invoice of an order, issuing it on first download (admin only)
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Router /admin/orders/{id}/invoice [get]
+func (h *InvoiceHandler) GetOrderInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	invoice, data, err := h.service.GetInvoiceForAdmin(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendPDF(c, invoice.Number+".pdf", data)
+}
+
+// GetOrderPackingSlip godoc
+// @Summary Download any order packing slip
+// @Description Download the packing slip of an order (admin only)
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Router /admin/orders/{id}/packing-slip [get]
+func (h *InvoiceHandler) GetOrderPackingSlip(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	data, err := h.service.GetPackingSlips([]uint{uint(id)})
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendPDF(c, fmt.Sprintf("packing-slip-%d.pdf", id), data)
+}
+
+// GetBulkPackingSlips godoc
+// @Summary Print packing slips for several orders
+// @Description Download one PDF with a packing slip page per selected order, in the order given (admin only)
+// @Tags orders
+// @Accept json
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param orders body BulkOrdersInput true "Orders to print"
+// @Success 200 {file} file
+// @Router /admin/orders/bulk/packing-slips [post]
+func (h *InvoiceHandler) GetBulkPackingSlips(c *gin.Context) {
+	var input BulkOrdersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid input: select 1 to %d orders", maxBulkOrders))
+		return
+	}
+
+	data, err := h.service.GetPackingSlips(input.OrderIDs)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendPDF(c, "packing-slips.pdf", data)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+// ListJobs godoc
+// @Summary List background jobs
+// @Description Get a page of background jobs, newest first (admin only)
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, running, completed or dead"
+// @Param queue query string false "Queue name"
+// @Param type query string false "Job type"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	page, pageSize := h.pagination(c)
+	filter := repository.JobFilter{
+		Queue:  c.Query("queue"),
+		Type:   c.Query("type"),
+		Status: models.JobStatus(c.Query("status")),
+	}
+
+	jobs, total, err := h.service.ListJobs(filter, page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: jobs, Total: total, Page: page, PageSize: pageSize}, "Jobs retrieved successfully")
+}
+
+// GetJobStats godoc
+// @Summary Count background jobs
+// @Description Get the number of jobs per queue and status (admin only)
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /admin/jobs/stats [get]
+func (h *JobHandler) GetJobStats(c *gin.Context) {
+	stats, err := h.service.JobStats()
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, stats, "Job stats retrieved successfully")
+}
+
+// GetJob godoc
+// @Summary Get a background job
+// @Description Get a job with its payload and last error (admin only)
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} Response
+// @Router /admin/jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	job, err := h.service.GetJob(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, job, "Job retrieved successfully")
+}
+
+// RetryJob godoc
+// @Summary Retry a background job
+// @Description Run a dead job again with fresh attempts, or a scheduled job right away (admin only)
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} Response
+// @Router /admin/jobs/{id}/retry [post]
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	job, err := h.service.RetryJob(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, job, "Job queued for retry")
+}
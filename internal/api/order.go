@@ -1,61 +1,138 @@
 package api
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/service"
 )
 
+// maxBulkOrders caps the orders a single bulk action may touch
+const maxBulkOrders = 500
+
+// BulkOrdersInput selects the orders of a bulk action
+type BulkOrdersInput struct {
+	OrderIDs []uint `json:"order_ids" binding:"required,min=1,max=500"`
+}
+
+// BulkStatusInput moves the selected orders to a status
+type BulkStatusInput struct {
+	OrderIDs []uint             `json:"order_ids" binding:"required,min=1,max=500"`
+	Status   models.OrderStatus `json:"status" binding:"required"`
+}
+
+type CreateOrderInput struct {
+	ShippingAddressID uint   `json:"shipping_address_id"` // Defaults to the user's default shipping address
+	BillingAddressID  uint   `json:"billing_address_id"`  // Defaults to the default billing address, then the shipping address
+	Notes             string `json:"notes"`
+}
+
 // CreateOrder godoc
 // @Summary Create a new order
-// @Description Create a new order from the user's cart
+// @Description Create a new order from the user's cart, shipped to and billed at the user's own addresses, and charged in the chosen currency at the current exchange rate. Fails with 409 while the cart has unacknowledged changes.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param order body models.Order true "Order details"
+// @Param X-Currency header string false "Currency to charge in, defaults to the base currency"
+// @Param order body CreateOrderInput true "Order details"
 // @Success 201 {object} Response
+// @Failure 409 {object} Response
 // @Router /orders [post]
-func (h *Handler) CreateOrder(c *gin.Context) {
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	userID := c.GetUint("user_id")
-	var order models.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
+	var input CreateOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	// Get user's cart
-	var cart models.Cart
-	if err := h.db.Preload("Items.Product").First(&cart, "user_id = ?", userID).Error; err != nil {
-		h.errorResponse(c, http.StatusNotFound, "Cart not found")
+	order, err := h.service.CreateOrder(c.Request.Context(), userID, input.ShippingAddressID, input.BillingAddressID, input.Notes, h.currency(c))
+	if err != nil {
+		h.checkoutErrorResponse(c, err)
 		return
 	}
 
-	// Create order items from cart items
-	var orderItems []models.OrderItem
-	for _, item := range cart.Items {
-		orderItems = append(orderItems, models.OrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     item.Price,
-			Subtotal:  item.Subtotal,
-		})
+	h.createdResponse(c, order)
+}
+
+// checkoutErrorResponse reports a failed checkout, telling apart carts that
+// need the customer to acknowledge changes
+func (h *OrderHandler) checkoutErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrCartRequiresAcknowledgement) {
+		h.errorResponse(c, http.StatusConflict, err.Error())
+		return
 	}
+	h.errorResponse(c, http.StatusBadRequest, err.Error())
+}
+
+type GuestAddressInput struct {
+	Street  string `json:"street" binding:"required"`
+	City    string `json:"city" binding:"required"`
+	State   string `json:"state" binding:"required"`
+	Country string `json:"country" binding:"required"`
+	ZipCode string `json:"zip_code" binding:"required"`
+}
+
+func (a *GuestAddressInput) toModel() *models.Address {
+	if a == nil {
+		return nil
+	}
+	return &models.Address{
+		Street:  a.Street,
+		City:    a.City,
+		State:   a.State,
+		Country: a.Country,
+		ZipCode: a.ZipCode,
+	}
+}
 
-	// Create order
-	order.UserID = userID
-	order.Items = orderItems
-	order.Status = models.OrderStatusPending
+type GuestCheckoutInput struct {
+	Email           string             `json:"email" binding:"required,email"`
+	ShippingAddress GuestAddressInput  `json:"shipping_address" binding:"required"`
+	BillingAddress  *GuestAddressInput `json:"billing_address"` // Defaults to the shipping address
+	Notes           string             `json:"notes"`
+}
 
-	if err := h.db.Create(&order).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to create order")
+// GuestCheckout godoc
+// @Summary Check out as guest
+// @Description Place an order from a guest cart using an email, a shipping address and an optional billing address
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param X-Cart-Token header string true "Guest cart token"
+// @Param X-Currency header string false "Currency to charge in, defaults to the base currency"
+// @Param checkout body GuestCheckoutInput true "Guest checkout details"
+// @Success 201 {object} Response
+// @Failure 409 {object} Response
+// @Router /checkout/guest [post]
+func (h *OrderHandler) GuestCheckout(c *gin.Context) {
+	token := c.GetHeader(cartTokenHeader)
+	if token == "" {
+		h.errorResponse(c, http.StatusBadRequest, "Cart token required")
 		return
 	}
 
-	// Clear the cart
-	if err := h.db.Delete(&cart).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to clear cart")
+	var input GuestCheckoutInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	shipping := input.ShippingAddress.toModel()
+	billing := input.BillingAddress.toModel()
+
+	order, err := h.service.CreateGuestOrder(c.Request.Context(), token, input.Email, shipping, billing, input.Notes, h.currency(c))
+	if err != nil {
+		h.checkoutErrorResponse(c, err)
 		return
 	}
 
@@ -117,10 +194,15 @@ func (h *Handler) GetOrder(c *gin.Context) {
 // @Param status body string true "New order status"
 // @Success 200 {object} Response
 // @Router /orders/{id}/status [put]
-func (h *Handler) UpdateOrderStatus(c *gin.Context) {
-	orderID := c.Param("id")
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
 	var input struct {
-		Status models.OrderStatus `json:"status"`
+		Status         models.OrderStatus `json:"status"`
+		TrackingNumber string             `json:"tracking_number"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -128,10 +210,13 @@ func (h *Handler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Model(&models.Order{}).Where("id = ?", orderID).Update("status", input.Status).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to update order status")
+	before, _ := h.service.GetOrderForAdmin(c.Request.Context(), uint(id))
+	if err := h.service.UpdateOrderStatus(c.Request.Context(), uint(id), input.Status, input.TrackingNumber); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	after, _ := h.service.GetOrderForAdmin(c.Request.Context(), uint(id))
+	h.audit(c, "order.status_change", "order", id, before, after)
 
 	h.successResponse(c, nil, "Order status updated successfully")
 }
@@ -146,14 +231,180 @@ func (h *Handler) UpdateOrderStatus(c *gin.Context) {
 // @Param id path int true "Order ID"
 // @Success 200 {object} Response
 // @Router /orders/{id}/cancel [post]
-func (h *Handler) CancelOrder(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	orderID := c.Param("id")
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
 
-	if err := h.db.Model(&models.Order{}).Where("id = ? AND user_id = ?", orderID, userID).Update("status", models.OrderStatusCancelled).Error; err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, "Failed to cancel order")
+	if err := h.service.CancelOrder(c.Request.Context(), uint(id), c.GetUint("user_id")); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	h.successResponse(c, nil, "Order cancelled successfully")
 }
+
+// orderFilter reads the admin order listing filters. Dates are UTC days and
+// both from and to are included; totals are in the base currency code.
+func orderFilter(c *gin.Context, code string) (repository.OrderFilter, error) {
+	filter := repository.OrderFilter{
+		Status:  models.OrderStatus(c.Query("status")),
+		Email:   c.Query("email"),
+		Payment: c.Query("payment"),
+		Search:  c.Query("q"),
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date %q", v)
+		}
+		filter.From = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date %q", v)
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	if v := c.Query("min_total"); v != "" {
+		total, err := money.Parse(v, code)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_total %q", v)
+		}
+		filter.MinTotal = total
+	}
+	if v := c.Query("max_total"); v != "" {
+		total, err := money.Parse(v, code)
+		if err != nil {
+			return filter, fmt.Errorf("invalid max_total %q", v)
+		}
+		filter.MaxTotal = total
+	}
+	return filter, nil
+}
+
+// ListAllOrders godoc
+// @Summary List all orders
+// @Description Get a page of all customers' orders, newest first (admin only)
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, processing, shipped, delivered, cancelled or refunded"
+// @Param from query string false "Placed on or after this day, YYYY-MM-DD"
+// @Param to query string false "Placed on or before this day, YYYY-MM-DD"
+// @Param email query string false "Part of the customer or guest email"
+// @Param min_total query number false "Minimum order total in the base currency"
+// @Param max_total query number false "Maximum order total in the base currency"
+// @Param payment query string false "paid or unpaid"
+// @Param q query string false "Order ID or part of the tracking number"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/orders [get]
+func (h *OrderHandler) ListAllOrders(c *gin.Context) {
+	filter, err := orderFilter(c, h.currencies.Base())
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, pageSize := h.pagination(c)
+	orders, total, err := h.service.ListOrders(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: orders, Total: total, Page: page, PageSize: pageSize}, "Orders retrieved successfully")
+}
+
+// GetAnyOrder godoc
+// @Summary Get any order
+// @Description Get an order with its customer, addresses and lines (admin only)
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} Response
+// @Router /admin/orders/{id} [get]
+func (h *OrderHandler) GetAnyOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	order, err := h.service.GetOrderForAdmin(c.Request.Context(), uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, order, "Order retrieved successfully")
+}
+
+// BulkUpdateOrderStatus godoc
+// @Summary Change the status of several orders
+// @Description Move each selected order to a status, e.g. processing, under the same rules as a single status update. Returns the outcome per order (admin only).
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param orders body BulkStatusInput true "Orders and new status"
+// @Success 200 {object} Response
+// @Router /admin/orders/bulk/status [post]
+func (h *OrderHandler) BulkUpdateOrderStatus(c *gin.Context) {
+	var input BulkStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid input: select 1 to %d orders and a status", maxBulkOrders))
+		return
+	}
+
+	results := h.service.BulkUpdateStatus(c.Request.Context(), input.OrderIDs, input.Status)
+	for _, result := range results {
+		if !result.Success || result.PreviousStatus == input.Status {
+			continue
+		}
+		entry := auditEntry(c, "order.status_change")
+		entry.EntityType = "order"
+		entry.EntityID = fmt.Sprint(result.OrderID)
+		entry.Changes = map[string]models.AuditChange{"status": {From: result.PreviousStatus, To: input.Status}}
+		entry.Metadata = map[string]interface{}{"bulk": true}
+		h.recordAudit(c, entry)
+	}
+	h.successResponse(c, results, "Order statuses updated")
+}
+
+// ExportOrders godoc
+// @Summary Export orders
+// @Description Download the selected orders as CSV (admin only)
+// @Tags orders
+// @Accept json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param orders body BulkOrdersInput true "Orders to export"
+// @Success 200 {file} file
+// @Router /admin/orders/bulk/export [post]
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	var input BulkOrdersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid input: select 1 to %d orders", maxBulkOrders))
+		return
+	}
+
+	// Buffer so failures can still be reported as JSON errors
+	var buf bytes.Buffer
+	if err := h.service.ExportOrders(input.OrderIDs, &buf); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=orders.csv")
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
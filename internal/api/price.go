@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+)
+
+type PriceScheduleInput struct {
+	Kind     string      `json:"kind" binding:"required,oneof=change sale"`
+	Price    money.Money `json:"price"`     // In the product's currency
+	StartsAt *time.Time  `json:"starts_at"` // Omit to apply right away
+	EndsAt   *time.Time  `json:"ends_at"`   // Required for sales
+}
+
+// GetPriceHistory godoc
+// @Summary Get a product's price history
+// @Description Get a page of the product's price changes with the time each took effect, newest first
+// @Tags prices
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /products/{id}/price-history [get]
+func (h *PriceHandler) GetPriceHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	page, pageSize := h.pagination(c)
+	changes, total, err := h.service.PriceHistory(uint(id), page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: changes, Total: total, Page: page, PageSize: pageSize}, "Price history retrieved successfully")
+}
+
+// ListPriceSchedules godoc
+// @Summary List a product's price schedules
+// @Description Get the scheduled price changes and sales of a product, latest start first (admin only)
+// @Tags prices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} Response
+// @Router /admin/products/{id}/price-schedules [get]
+func (h *PriceHandler) ListPriceSchedules(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	schedules, err := h.service.ListSchedules(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, schedules, "Price schedules retrieved successfully")
+}
+
+// CreatePriceSchedule godoc
+// @Summary Schedule a price change or sale
+// @Description A change replaces the regular price from starts_at on. A sale sells at price from starts_at to ends_at and shows the regular price as compare_at_price meanwhile (admin only).
+// @Tags prices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param schedule body PriceScheduleInput true "Kind, price and period"
+// @Success 201 {object} Response
+// @Router /admin/products/{id}/price-schedules [post]
+func (h *PriceHandler) CreatePriceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var input PriceScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	schedule := &models.PriceSchedule{
+		ProductID: uint(id),
+		Kind:      input.Kind,
+		Price:     input.Price,
+		EndsAt:    input.EndsAt,
+	}
+	if input.StartsAt != nil {
+		schedule.StartsAt = *input.StartsAt
+	}
+	if userID := c.GetUint("user_id"); userID != 0 {
+		schedule.CreatedBy = &userID
+	}
+
+	if err := h.service.CreateSchedule(schedule); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "price_schedule.create", "price_schedule", schedule.ID, nil, schedule)
+
+	h.createdResponse(c, schedule)
+}
+
+// CancelPriceSchedule godoc
+// @Summary Cancel a price schedule
+// @Description Cancel a schedule that has not started, or end a running sale now (admin only)
+// @Tags prices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param scheduleId path int true "Price schedule ID"
+// @Success 200 {object} Response
+// @Router /admin/products/{id}/price-schedules/{scheduleId} [delete]
+func (h *PriceHandler) CancelPriceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	scheduleID, err := strconv.ParseUint(c.Param("scheduleId"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid price schedule ID")
+		return
+	}
+
+	before, err := h.service.GetSchedule(uint(id), uint(scheduleID))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	schedule, err := h.service.CancelSchedule(uint(id), uint(scheduleID))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "price_schedule.cancel", "price_schedule", scheduleID, before, schedule)
+
+	h.successResponse(c, schedule, "Price schedule cancelled")
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
 	"github.com/sajal/go-ecommerce/internal/service"
 )
 
@@ -28,40 +30,104 @@ func NewProductHandler(handler *Handler, service *service.ProductService) *Produ
 // @Accept json
 // @Produce json
 // @Param category_id query int false "Filter by category ID"
-// @Param min_price query number false "Minimum price"
-// @Param max_price query number false "Maximum price"
+// @Param min_price query number false "Minimum price in the base currency"
+// @Param max_price query number false "Maximum price in the base currency"
+// @Param min_rating query number false "Minimum average rating"
 // @Param search query string false "Search term"
+// @Param currency query string false "Currency to show prices in"
+// @Param sort query string false "Sort order: newest, price_asc, price_desc, rating or reviews"
 // @Success 200 {object} Response
 // @Router /products [get]
 func (h *ProductHandler) ListProducts(c *gin.Context) {
-	filters := make(map[string]interface{})
+	filter := productFilter(c, h.currencies.Base())
+
+	products, err := h.service.ListProducts(c.Request.Context(), filter)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.localizeProducts(c, products) {
+		return
+	}
+
+	h.successResponse(c, products, "Products retrieved successfully")
+}
+
+// productFilter reads the product listing filters from the query string.
+// Prices are in the base currency code.
+func productFilter(c *gin.Context, code string) repository.ProductFilter {
+	var filter repository.ProductFilter
 
 	if categoryID := c.Query("category_id"); categoryID != "" {
 		if id, err := strconv.ParseUint(categoryID, 10, 32); err == nil {
-			filters["category_id"] = uint(id)
+			filter.CategoryID = uint(id)
 		}
 	}
 	if minPrice := c.Query("min_price"); minPrice != "" {
-		if price, err := strconv.ParseFloat(minPrice, 64); err == nil {
-			filters["min_price"] = price
+		if price, err := money.Parse(minPrice, code); err == nil {
+			filter.MinPrice = price
 		}
 	}
 	if maxPrice := c.Query("max_price"); maxPrice != "" {
-		if price, err := strconv.ParseFloat(maxPrice, 64); err == nil {
-			filters["max_price"] = price
+		if price, err := money.Parse(maxPrice, code); err == nil {
+			filter.MaxPrice = price
 		}
 	}
-	if search := c.Query("search"); search != "" {
-		filters["search"] = search
+	if minRating := c.Query("min_rating"); minRating != "" {
+		if rating, err := strconv.ParseFloat(minRating, 64); err == nil {
+			filter.MinRating = rating
+		}
 	}
+	filter.Search = c.Query("search")
+	filter.Sort = c.Query("sort")
+
+	return filter
+}
 
-	products, err := h.service.GetAllProducts()
+// SearchProducts godoc
+// @Summary Search products
+// @Description Ranked full-text search over active products with prefix and typo-tolerant matching. Returns facet counts for categories, price ranges and ratings.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param q query string true "Search text"
+// @Param category_id query int false "Filter by category ID"
+// @Param min_price query number false "Minimum price in the base currency"
+// @Param max_price query number false "Maximum price in the base currency"
+// @Param min_rating query number false "Minimum average rating"
+// @Param currency query string false "Currency to show prices in"
+// @Param sort query string false "Sort order, defaults to relevance: newest, price_asc, price_desc, rating or reviews"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /products/search [get]
+func (h *ProductHandler) SearchProducts(c *gin.Context) {
+	filter := productFilter(c, h.currencies.Base())
+	filter.Search = ""
+
+	page, pageSize := h.pagination(c)
+	result, err := h.service.SearchProducts(c.Request.Context(), c.Query("q"), filter, page, pageSize)
 	if err != nil {
-		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	h.successResponse(c, products, "Products retrieved successfully")
+	if !h.localizeProducts(c, result.Products) {
+		return
+	}
+
+	// Only the first page counts as a search, later pages are browsing
+	if page == 1 {
+		h.searchHandler.service.LogQuery(c.Query("q"), result.Total)
+	}
+
+	h.successResponse(c, gin.H{
+		"items":     result.Products,
+		"total":     result.Total,
+		"page":      page,
+		"page_size": pageSize,
+		"facets":    result.Facets,
+	}, "Products retrieved successfully")
 }
 
 // GetProduct godoc
@@ -71,6 +137,7 @@ func (h *ProductHandler) ListProducts(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Product ID"
+// @Param currency query string false "Currency to show prices in"
 // @Success 200 {object} Response
 // @Router /products/{id} [get]
 func (h *ProductHandler) GetProduct(c *gin.Context) {
@@ -80,11 +147,15 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := h.service.GetProduct(uint(id))
+	product, err := h.service.GetProduct(c.Request.Context(), uint(id))
 	if err != nil {
 		h.errorResponse(c, http.StatusNotFound, err.Error())
 		return
 	}
+	if err := h.currencies.LocalizeProduct(c.Request.Context(), product, h.currency(c)); err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	h.successResponse(c, product, "Product retrieved successfully")
 }
@@ -99,16 +170,18 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 // @Success 201 {object} Response
 // @Router /products [post]
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
-	var product models.Product
+	// Products are active unless the request says otherwise
+	product := models.Product{IsActive: true}
 	if err := c.ShouldBindJSON(&product); err != nil {
 		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	if err := h.service.CreateProduct(&product); err != nil {
+	if err := h.service.CreateProduct(c.Request.Context(), &product); err != nil {
 		h.errorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.audit(c, "product.create", "product", product.ID, nil, product)
 
 	h.createdResponse(c, product)
 }
@@ -136,11 +209,15 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.service.GetProduct(c.Request.Context(), uint(id))
+
 	product.ID = uint(id)
-	if err := h.service.UpdateProduct(&product); err != nil {
+	if err := h.service.UpdateProduct(c.Request.Context(), &product); err != nil {
 		h.errorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	after, _ := h.service.GetProduct(c.Request.Context(), uint(id))
+	h.audit(c, "product.update", "product", id, before, after)
 
 	h.successResponse(c, product, "Product updated successfully")
 }
@@ -161,10 +238,12 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteProduct(uint(id)); err != nil {
+	before, _ := h.service.GetProduct(c.Request.Context(), uint(id))
+	if err := h.service.DeleteProduct(c.Request.Context(), uint(id)); err != nil {
 		h.errorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.audit(c, "product.delete", "product", id, before, nil)
 
 	h.noContentResponse(c)
 }
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/service"
+)
+
+// exportContentTypes maps export formats to their response content type
+var exportContentTypes = map[string]string{
+	service.FormatCSV:  "text/csv",
+	service.FormatJSON: "application/json",
+}
+
+// ImportProducts godoc
+// @Summary Import products in bulk
+// @Description Upload a CSV or JSON file of products to upsert by SKU in the background (admin only). Categories are matched by name and created when missing.
+// @Tags products
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or JSON product file"
+// @Param format query string false "File format: csv or json (defaults to the file extension)"
+// @Param dry_run query bool false "Only validate the file and report what would change"
+// @Success 202 {object} Response
+// @Router /admin/products/import [post]
+func (h *ProductImportHandler) ImportProducts(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Import file required")
+		return
+	}
+	if file.Size > h.config.MaxImportSize {
+		h.errorResponse(c, http.StatusRequestEntityTooLarge, "Import file is too large")
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = service.FormatFromFileName(file.Filename)
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	f, err := file.Open()
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Failed to read import file")
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, h.config.MaxImportSize+1))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Failed to read import file")
+		return
+	}
+	if int64(len(data)) > h.config.MaxImportSize {
+		h.errorResponse(c, http.StatusRequestEntityTooLarge, "Import file is too large")
+		return
+	}
+
+	productImport, err := h.service.StartImport(c.GetUint("user_id"), file.Filename, format, dryRun, data)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, Response{
+		Success: true,
+		Message: "Import started",
+		Data:    productImport,
+	})
+}
+
+// ListProductImports godoc
+// @Summary List product imports
+// @Description Get the most recent product imports without their row errors (admin only)
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /admin/products/imports [get]
+func (h *ProductImportHandler) ListProductImports(c *gin.Context) {
+	imports, err := h.service.ListImports()
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, imports, "Imports retrieved successfully")
+}
+
+// GetProductImport godoc
+// @Summary Get a product import
+// @Description Get the status, counts and per-row errors of a product import (admin only)
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Import ID"
+// @Success 200 {object} Response
+// @Router /admin/products/imports/{id} [get]
+func (h *ProductImportHandler) GetProductImport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	productImport, err := h.service.GetImport(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, productImport, "Import retrieved successfully")
+}
+
+// ExportProducts godoc
+// @Summary Export products
+// @Description Download the products matching the filters as CSV or JSON in the import format (admin only)
+// @Tags products
+// @Produce text/csv
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "File format: csv (default) or json"
+// @Param category_id query int false "Filter by category ID"
+// @Param min_price query number false "Minimum price in the base currency"
+// @Param max_price query number false "Maximum price in the base currency"
+// @Param min_rating query number false "Minimum average rating"
+// @Param search query string false "Search term"
+// @Param sort query string false "Sort order: newest, price_asc, price_desc, rating or reviews"
+// @Success 200 {file} file
+// @Router /admin/products/export [get]
+func (h *ProductImportHandler) ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", service.FormatCSV)
+
+	// Buffer so failures can still be reported as JSON errors
+	var buf bytes.Buffer
+	if err := h.service.ExportProducts(c.Request.Context(), format, productFilter(c, h.currencies.Base()), &buf); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=products."+format)
+	c.Data(http.StatusOK, exportContentTypes[format], buf.Bytes())
+}
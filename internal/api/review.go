@@ -0,0 +1,356 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/models"
+)
+
+type ReviewInput struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Title   string `json:"title" binding:"max=100"`
+	Comment string `json:"comment"`
+}
+
+type ModerationInput struct {
+	Note string `json:"note"`
+}
+
+// ListProductReviews godoc
+// @Summary List product reviews
+// @Description Get a page of approved reviews for a product
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param sort query string false "Sort order: newest, rating or helpful"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /products/{id}/reviews [get]
+func (h *ReviewHandler) ListProductReviews(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	page, pageSize := h.pagination(c)
+	reviews, total, err := h.service.ListProductReviews(c.Request.Context(), uint(productID), c.Query("sort"), page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: reviews, Total: total, Page: page, PageSize: pageSize}, "Reviews retrieved successfully")
+}
+
+// CreateReview godoc
+// @Summary Review a product
+// @Description Review a delivered product. The review is published once approved by a moderator.
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param review body ReviewInput true "Review details"
+// @Success 201 {object} Response
+// @Router /products/{id}/reviews [post]
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var input ReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	review, err := h.service.CreateReview(c.Request.Context(), c.GetUint("user_id"), uint(productID), input.Rating, input.Title, input.Comment)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.createdResponse(c, review)
+}
+
+// UpdateReview godoc
+// @Summary Update a review
+// @Description Edit one of the current user's reviews. Edited reviews go back to moderation.
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param review body ReviewInput true "Review details"
+// @Success 200 {object} Response
+// @Router /reviews/{id} [put]
+func (h *ReviewHandler) UpdateReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	var input ReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	review, err := h.service.UpdateReview(c.Request.Context(), c.GetUint("user_id"), uint(id), input.Rating, input.Title, input.Comment)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, review, "Review updated successfully")
+}
+
+// DeleteReview godoc
+// @Summary Delete a review
+// @Description Delete one of the current user's reviews
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 204 "No Content"
+// @Router /reviews/{id} [delete]
+func (h *ReviewHandler) DeleteReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	if err := h.service.DeleteReview(c.Request.Context(), c.GetUint("user_id"), uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.noContentResponse(c)
+}
+
+// VoteReviewHelpful godoc
+// @Summary Vote a review helpful
+// @Description Mark another user's approved review as helpful
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 200 {object} Response
+// @Router /reviews/{id}/helpful [post]
+func (h *ReviewHandler) VoteReviewHelpful(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	if err := h.service.VoteHelpful(c.Request.Context(), c.GetUint("user_id"), uint(id)); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, nil, "Vote recorded")
+}
+
+// RemoveReviewVote godoc
+// @Summary Withdraw a helpful vote
+// @Description Withdraw the current user's helpful vote on a review
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 204 "No Content"
+// @Router /reviews/{id}/helpful [delete]
+func (h *ReviewHandler) RemoveReviewVote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	if err := h.service.RemoveHelpfulVote(c.Request.Context(), c.GetUint("user_id"), uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.noContentResponse(c)
+}
+
+// UploadReviewImage godoc
+// @Summary Attach an image to a review
+// @Description Upload a JPEG, PNG, WebP or GIF image for one of the current user's reviews
+// @Tags reviews
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param image formData file true "Image file"
+// @Success 201 {object} Response
+// @Router /reviews/{id}/images [post]
+func (h *ReviewHandler) UploadReviewImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Image file required")
+		return
+	}
+	if file.Size > h.config.MaxFileSize {
+		h.errorResponse(c, http.StatusRequestEntityTooLarge, "Image is too large")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Failed to read image")
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, h.config.MaxFileSize+1))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Failed to read image")
+		return
+	}
+	if int64(len(data)) > h.config.MaxFileSize {
+		h.errorResponse(c, http.StatusRequestEntityTooLarge, "Image is too large")
+		return
+	}
+
+	image, err := h.service.AddImage(c.Request.Context(), c.GetUint("user_id"), uint(id), data)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.createdResponse(c, image)
+}
+
+// GetReviewImage godoc
+// @Summary Get a review image
+// @Description Get an image attached to a review. Images of reviews awaiting moderation or rejected are only shown to their author and to staff.
+// @Tags reviews
+// @Produce image/jpeg,image/png,image/webp,image/gif
+// @Param id path int true "Review ID"
+// @Param name path string true "Image name"
+// @Success 200 {file} file
+// @Router /reviews/{id}/images/{name} [get]
+func (h *ReviewHandler) GetReviewImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	role := c.GetString("user_role")
+	staff := role == models.RoleAdmin || role == models.RoleSupport
+	data, contentType, err := h.service.GetImage(c.Request.Context(), uint(id), c.Param("name"), c.GetUint("user_id"), staff)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Keep shared caches from holding on to images of unpublished reviews
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ListReviewQueue godoc
+// @Summary List reviews for moderation
+// @Description Get a page of reviews in a moderation state, oldest first (admin only)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending (default), approved or rejected"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/reviews [get]
+func (h *ReviewHandler) ListReviewQueue(c *gin.Context) {
+	page, pageSize := h.pagination(c)
+	reviews, total, err := h.service.ListModerationQueue(c.Request.Context(), models.ReviewStatus(c.Query("status")), page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: reviews, Total: total, Page: page, PageSize: pageSize}, "Reviews retrieved successfully")
+}
+
+// ApproveReview godoc
+// @Summary Approve a review
+// @Description Publish a review (admin only)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param moderation body ModerationInput false "Moderation note"
+// @Success 200 {object} Response
+// @Router /admin/reviews/{id}/approve [post]
+func (h *ReviewHandler) ApproveReview(c *gin.Context) {
+	h.moderateReview(c, true)
+}
+
+// RejectReview godoc
+// @Summary Reject a review
+// @Description Keep a review from being published (admin only)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param moderation body ModerationInput false "Reason for rejection"
+// @Success 200 {object} Response
+// @Router /admin/reviews/{id}/reject [post]
+func (h *ReviewHandler) RejectReview(c *gin.Context) {
+	h.moderateReview(c, false)
+}
+
+func (h *ReviewHandler) moderateReview(c *gin.Context, approve bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	var input ModerationInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+			return
+		}
+	}
+
+	before, _ := h.service.GetReview(c.Request.Context(), uint(id))
+	review, err := h.service.ModerateReview(c.Request.Context(), c.GetUint("user_id"), uint(id), approve, input.Note)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	action := "review.reject"
+	if approve {
+		action = "review.approve"
+	}
+	h.audit(c, action, "review", id, before, review)
+
+	h.successResponse(c, review, "Review moderated successfully")
+}
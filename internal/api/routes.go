@@ -5,14 +5,22 @@ import (
 )
 
 func (h *Handler) SetupRoutes(r *gin.Engine) {
+	// Probes
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+
 	// Public routes
 	public := r.Group("/api/v1")
+	public.Use(h.CurrencyMiddleware())
 	{
 		// Product routes
 		products := public.Group("/products")
 		{
 			products.GET("", h.productHandler.ListProducts)
+			products.GET("/search", h.productHandler.SearchProducts)
 			products.GET("/:id", h.productHandler.GetProduct)
+			products.GET("/:id/reviews", h.reviewHandler.ListProductReviews)
+			products.GET("/:id/price-history", h.priceHandler.GetPriceHistory)
 		}
 
 		// Auth routes
@@ -20,12 +28,44 @@ func (h *Handler) SetupRoutes(r *gin.Engine) {
 		{
 			auth.POST("/register", h.Register)
 			auth.POST("/login", h.Login)
+			auth.POST("/password-reset", h.ResetPassword)
+		}
+
+		// Search suggestions
+		public.GET("/search/suggest", h.searchHandler.SuggestSearch)
+
+		// Guest checkout
+		public.POST("/checkout/guest", h.orderHandler.GuestCheckout)
+
+		// Shared wishlists
+		public.GET("/wishlists/shared/:token", h.wishlistHandler.GetSharedWishlist)
+
+		// Currencies prices can be shown and charged in
+		public.GET("/currencies", h.currencyHandler.ListCurrencies)
+	}
+
+	// Routes open to guests and users alike
+	shared := r.Group("/api/v1")
+	shared.Use(h.CurrencyMiddleware(), h.OptionalAuthMiddleware())
+	{
+		// Cart routes
+		cart := shared.Group("/cart")
+		{
+			cart.GET("", h.cartHandler.GetCart)
+			cart.DELETE("", h.cartHandler.ClearCart)
+			cart.POST("/acknowledge", h.cartHandler.AcknowledgeCartChanges)
+			cart.POST("/items", h.cartHandler.AddToCart)
+			cart.PUT("/items/:id", h.cartHandler.UpdateCartItem)
+			cart.DELETE("/items/:id", h.cartHandler.RemoveFromCart)
 		}
+
+		// Review images, shown to everyone once the review is approved
+		shared.GET("/reviews/:id/images/:name", h.reviewHandler.GetReviewImage)
 	}
 
 	// Protected routes
 	protected := r.Group("/api/v1")
-	protected.Use(h.AuthMiddleware())
+	protected.Use(h.CurrencyMiddleware(), h.AuthMiddleware())
 	{
 		// User routes
 		users := protected.Group("/users")
@@ -34,36 +74,151 @@ func (h *Handler) SetupRoutes(r *gin.Engine) {
 			users.PUT("/me", h.UpdateUser)
 		}
 
-		// Cart routes
-		cart := protected.Group("/cart")
+		// Address routes
+		addresses := protected.Group("/addresses")
 		{
-			cart.GET("", h.GetCart)
-			cart.DELETE("", h.ClearCart)
-			cart.POST("/items", h.AddToCart)
-			cart.PUT("/items/:id", h.UpdateCartItem)
-			cart.DELETE("/items/:id", h.RemoveFromCart)
+			addresses.GET("", h.addressHandler.ListAddresses)
+			addresses.POST("", h.addressHandler.CreateAddress)
+			addresses.GET("/:id", h.addressHandler.GetAddress)
+			addresses.PUT("/:id", h.addressHandler.UpdateAddress)
+			addresses.DELETE("/:id", h.addressHandler.DeleteAddress)
+			addresses.POST("/:id/default", h.addressHandler.SetDefaultAddress)
+		}
+
+		// Save for later needs an account
+		protected.POST("/cart/items/:id/save-for-later", h.wishlistHandler.SaveForLater)
+
+		// Wishlist routes
+		wishlists := protected.Group("/wishlists")
+		{
+			wishlists.GET("", h.wishlistHandler.ListWishlists)
+			wishlists.POST("", h.wishlistHandler.CreateWishlist)
+			wishlists.GET("/:id", h.wishlistHandler.GetWishlist)
+			wishlists.PUT("/:id", h.wishlistHandler.UpdateWishlist)
+			wishlists.DELETE("/:id", h.wishlistHandler.DeleteWishlist)
+			wishlists.POST("/:id/items", h.wishlistHandler.AddWishlistItem)
+			wishlists.DELETE("/:id/items/:itemId", h.wishlistHandler.RemoveWishlistItem)
+			wishlists.POST("/:id/items/:itemId/move-to-cart", h.wishlistHandler.MoveWishlistItemToCart)
+		}
+
+		// Review routes
+		protected.POST("/products/:id/reviews", h.reviewHandler.CreateReview)
+		reviews := protected.Group("/reviews")
+		{
+			reviews.PUT("/:id", h.reviewHandler.UpdateReview)
+			reviews.DELETE("/:id", h.reviewHandler.DeleteReview)
+			reviews.POST("/:id/helpful", h.reviewHandler.VoteReviewHelpful)
+			reviews.DELETE("/:id/helpful", h.reviewHandler.RemoveReviewVote)
+			reviews.POST("/:id/images", h.reviewHandler.UploadReviewImage)
 		}
 
 		// Order routes
 		orders := protected.Group("/orders")
 		{
-			orders.POST("", h.CreateOrder)
+			orders.POST("", h.orderHandler.CreateOrder)
 			orders.GET("", h.GetOrders)
 			orders.GET("/:id", h.GetOrder)
-			orders.POST("/:id/cancel", h.CancelOrder)
+			orders.POST("/:id/cancel", h.orderHandler.CancelOrder)
+			orders.GET("/:id/invoice", h.invoiceHandler.GetInvoice)
+			orders.GET("/:id/packing-slip", h.invoiceHandler.GetPackingSlip)
+		}
+
+		// Customer support routes, open to admins and support agents. The
+		// audit middleware goes first so refused requests are logged too.
+		staff := protected.Group("/admin")
+		staff.Use(h.AuditMiddleware(), h.StaffMiddleware())
+		{
+			staff.GET("/users", h.customerHandler.ListCustomers)
+			staff.GET("/users/:id", h.customerHandler.GetCustomer)
+			staff.GET("/users/:id/orders", h.customerHandler.ListCustomerOrders)
+			staff.GET("/users/:id/addresses", h.customerHandler.ListCustomerAddresses)
+			staff.GET("/users/:id/reviews", h.customerHandler.ListCustomerReviews)
+			staff.POST("/users/:id/impersonate", h.customerHandler.ImpersonateCustomer)
+			staff.POST("/impersonations/:id/end", h.customerHandler.EndImpersonation)
 		}
 
 		// Admin routes
 		admin := protected.Group("/admin")
-		admin.Use(h.AdminMiddleware())
+		admin.Use(h.AuditMiddleware(), h.AdminMiddleware())
 		{
 			// Product management
 			admin.POST("/products", h.productHandler.CreateProduct)
 			admin.PUT("/products/:id", h.productHandler.UpdateProduct)
 			admin.DELETE("/products/:id", h.productHandler.DeleteProduct)
 
+			// Scheduled price changes and sales
+			admin.GET("/products/:id/price-schedules", h.priceHandler.ListPriceSchedules)
+			admin.POST("/products/:id/price-schedules", h.priceHandler.CreatePriceSchedule)
+			admin.DELETE("/products/:id/price-schedules/:scheduleId", h.priceHandler.CancelPriceSchedule)
+
+			// Prices in other currencies
+			admin.GET("/products/:id/prices", h.currencyHandler.ListProductPrices)
+			admin.PUT("/products/:id/prices/:currency", h.currencyHandler.SetProductPrice)
+			admin.DELETE("/products/:id/prices/:currency", h.currencyHandler.DeleteProductPrice)
+
+			// Bulk product import and export
+			admin.POST("/products/import", h.importHandler.ImportProducts)
+			admin.GET("/products/imports", h.importHandler.ListProductImports)
+			admin.GET("/products/imports/:id", h.importHandler.GetProductImport)
+			admin.GET("/products/export", h.importHandler.ExportProducts)
+
 			// Order management
-			admin.PUT("/orders/:id/status", h.UpdateOrderStatus)
+			admin.GET("/orders", h.orderHandler.ListAllOrders)
+			admin.GET("/orders/:id", h.orderHandler.GetAnyOrder)
+			admin.PUT("/orders/:id/status", h.orderHandler.UpdateOrderStatus)
+			admin.POST("/orders/bulk/status", h.orderHandler.BulkUpdateOrderStatus)
+			admin.POST("/orders/bulk/packing-slips", h.invoiceHandler.GetBulkPackingSlips)
+			admin.POST("/orders/bulk/export", h.orderHandler.ExportOrders)
+			admin.GET("/orders/:id/invoice", h.invoiceHandler.GetOrderInvoice)
+			admin.GET("/orders/:id/packing-slip", h.invoiceHandler.GetOrderPackingSlip)
+
+			// Customer management
+			admin.PUT("/users/:id/role", h.customerHandler.ChangeCustomerRole)
+			admin.PUT("/users/:id/status", h.customerHandler.SetCustomerStatus)
+			admin.POST("/users/:id/password-reset", h.customerHandler.ForceCustomerPasswordReset)
+			admin.DELETE("/users/:id", h.customerHandler.DeleteCustomer)
+			admin.GET("/impersonations", h.customerHandler.ListImpersonations)
+
+			// Exchange rates
+			admin.GET("/exchange-rates", h.currencyHandler.ListExchangeRates)
+			admin.POST("/exchange-rates/refresh", h.currencyHandler.RefreshExchangeRates)
+
+			// Sales analytics
+			admin.GET("/analytics/summary", h.analyticsHandler.GetSalesSummary)
+			admin.GET("/analytics/sales", h.analyticsHandler.GetSalesOverTime)
+			admin.GET("/analytics/top-products", h.analyticsHandler.GetTopProducts)
+			admin.GET("/analytics/top-categories", h.analyticsHandler.GetTopCategories)
+
+			// Search reporting
+			admin.GET("/search/zero-results", h.searchHandler.ListZeroResultSearches)
+
+			// Review moderation
+			admin.GET("/reviews", h.reviewHandler.ListReviewQueue)
+			admin.POST("/reviews/:id/approve", h.reviewHandler.ApproveReview)
+			admin.POST("/reviews/:id/reject", h.reviewHandler.RejectReview)
+
+			// Background jobs
+			admin.GET("/jobs", h.jobHandler.ListJobs)
+			admin.GET("/jobs/stats", h.jobHandler.GetJobStats)
+			admin.GET("/jobs/:id", h.jobHandler.GetJob)
+			admin.POST("/jobs/:id/retry", h.jobHandler.RetryJob)
+
+			// Merchant webhooks
+			admin.GET("/webhooks", h.webhookHandler.ListWebhooks)
+			admin.POST("/webhooks", h.webhookHandler.CreateWebhook)
+			admin.GET("/webhooks/:id", h.webhookHandler.GetWebhook)
+			admin.PUT("/webhooks/:id", h.webhookHandler.UpdateWebhook)
+			admin.DELETE("/webhooks/:id", h.webhookHandler.DeleteWebhook)
+			admin.GET("/webhooks/:id/deliveries", h.webhookHandler.ListWebhookDeliveries)
+			admin.POST("/webhook-deliveries/:id/resend", h.webhookHandler.ResendWebhookDelivery)
+
+			// Email templates
+			admin.GET("/emails/templates", h.emailHandler.ListEmailTemplates)
+			admin.GET("/emails/templates/:name/preview", h.emailHandler.PreviewEmailTemplate)
+
+			// Audit log
+			admin.GET("/audit-logs", h.auditHandler.ListAuditLogs)
+			admin.GET("/audit-logs/export", h.auditHandler.ExportAuditLogs)
 		}
 	}
 }
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SuggestSearch godoc
+// @Summary Search suggestions
+// @Description Autocomplete for the search box: product names, categories and popular past queries starting with the typed text
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Typed text"
+// @Success 200 {object} Response
+// @Router /search/suggest [get]
+func (h *SearchHandler) SuggestSearch(c *gin.Context) {
+	h.successResponse(c, h.service.Suggest(c.Query("q")), "Suggestions retrieved successfully")
+}
+
+// ListZeroResultSearches godoc
+// @Summary Zero-result searches
+// @Description Report the searches that found no products, most frequent first (admin only)
+// @Tags search
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param days query int false "Look back this many days (default 30)"
+// @Param limit query int false "Maximum number of queries (default 100)"
+// @Success 200 {object} Response
+// @Router /admin/search/zero-results [get]
+func (h *SearchHandler) ListZeroResultSearches(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid days")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	stats, err := h.service.ZeroResultQueries(days, limit)
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, stats, "Zero-result searches retrieved successfully")
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/models"
+)
+
+type WebhookInput struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types" binding:"required"`
+	Secret     string   `json:"secret"`    // Generated when empty on create, kept when empty on update
+	IsActive   *bool    `json:"is_active"` // Unchanged when omitted on update
+}
+
+// ListWebhooks godoc
+// @Summary List webhook endpoints
+// @Description Get all webhook subscriptions (admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	endpoints, err := h.service.ListEndpoints()
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, endpoints, "Webhooks retrieved successfully")
+}
+
+// CreateWebhook godoc
+// @Summary Create a webhook endpoint
+// @Description Subscribe a URL to domain events. Deliveries are signed with the secret, which is generated when omitted (admin only).
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param webhook body WebhookInput true "Webhook details"
+// @Success 201 {object} Response
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		Secret:     input.Secret,
+	}
+	if err := h.service.CreateEndpoint(endpoint); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "webhook.create", "webhook", endpoint.ID, nil, endpoint)
+
+	h.createdResponse(c, endpoint)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook endpoint
+// @Description Get a webhook subscription with its failure state (admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} Response
+// @Router /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	endpoint, err := h.service.GetEndpoint(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, endpoint, "Webhook retrieved successfully")
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook endpoint
+// @Description Change the URL, event types, secret or active state. Reactivating a disabled endpoint resets its failure count (admin only).
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param webhook body WebhookInput true "Webhook details"
+// @Success 200 {object} Response
+// @Router /admin/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	before, _ := h.service.GetEndpoint(uint(id))
+	endpoint, err := h.service.UpdateEndpoint(uint(id), input.URL, input.EventTypes, input.Secret, input.IsActive)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.audit(c, "webhook.update", "webhook", id, before, endpoint)
+
+	h.successResponse(c, endpoint, "Webhook updated successfully")
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook endpoint
+// @Description Stop delivering events to an endpoint (admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 204 "No Content"
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	before, _ := h.service.GetEndpoint(uint(id))
+	if err := h.service.DeleteEndpoint(uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	h.audit(c, "webhook.delete", "webhook", id, before, nil)
+
+	h.noContentResponse(c)
+}
+
+// ListWebhookDeliveries godoc
+// @Summary List webhook deliveries
+// @Description Get a page of the delivery log of an endpoint, newest first (admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	page, pageSize := h.pagination(c)
+	deliveries, total, err := h.service.ListDeliveries(uint(id), page, pageSize)
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, PagedData{Items: deliveries, Total: total, Page: page, PageSize: pageSize}, "Deliveries retrieved successfully")
+}
+
+// ResendWebhookDelivery godoc
+// @Summary Resend a webhook delivery
+// @Description Queue the body of a logged delivery to its endpoint again (admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Delivery ID"
+// @Success 202 {object} Response
+// @Router /admin/webhook-deliveries/{id}/resend [post]
+func (h *WebhookHandler) ResendWebhookDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	job, err := h.service.ResendDelivery(uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, Response{
+		Success: true,
+		Message: "Delivery queued",
+		Data:    job,
+	})
+}
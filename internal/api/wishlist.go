@@ -0,0 +1,318 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WishlistInput struct {
+	Name     string `json:"name" binding:"required"`
+	IsPublic bool   `json:"is_public"`
+}
+
+type WishlistItemInput struct {
+	ProductID uint `json:"product_id" binding:"required"`
+}
+
+type MoveToCartInput struct {
+	Quantity int `json:"quantity"`
+}
+
+type SaveForLaterInput struct {
+	WishlistID uint `json:"wishlist_id"`
+}
+
+// ListWishlists godoc
+// @Summary List wishlists
+// @Description Get all wishlists of the current user
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response
+// @Router /wishlists [get]
+func (h *WishlistHandler) ListWishlists(c *gin.Context) {
+	wishlists, err := h.service.GetUserWishlists(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		h.errorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.successResponse(c, wishlists, "Wishlists retrieved successfully")
+}
+
+// CreateWishlist godoc
+// @Summary Create a wishlist
+// @Description Create a named wishlist, optionally public through a share link
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param wishlist body WishlistInput true "Wishlist details"
+// @Success 201 {object} Response
+// @Router /wishlists [post]
+func (h *WishlistHandler) CreateWishlist(c *gin.Context) {
+	var input WishlistInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	wishlist, err := h.service.CreateWishlist(c.Request.Context(), c.GetUint("user_id"), input.Name, input.IsPublic)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.createdResponse(c, wishlist)
+}
+
+// GetWishlist godoc
+// @Summary Get a wishlist
+// @Description Get one of the current user's wishlists with its items
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Wishlist ID"
+// @Success 200 {object} Response
+// @Router /wishlists/{id} [get]
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist ID")
+		return
+	}
+
+	wishlist, err := h.service.GetWishlist(c.Request.Context(), c.GetUint("user_id"), uint(id))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, wishlist, "Wishlist retrieved successfully")
+}
+
+// GetSharedWishlist godoc
+// @Summary Get a shared wishlist
+// @Description Get a public wishlist by its share token
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Param token path string true "Share token"
+// @Success 200 {object} Response
+// @Router /wishlists/shared/{token} [get]
+func (h *WishlistHandler) GetSharedWishlist(c *gin.Context) {
+	wishlist, err := h.service.GetSharedWishlist(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.successResponse(c, wishlist, "Wishlist retrieved successfully")
+}
+
+// UpdateWishlist godoc
+// @Summary Update a wishlist
+// @Description Rename a wishlist or change its visibility. Making a list private revokes its share link.
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Wishlist ID"
+// @Param wishlist body WishlistInput true "Wishlist details"
+// @Success 200 {object} Response
+// @Router /wishlists/{id} [put]
+func (h *WishlistHandler) UpdateWishlist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist ID")
+		return
+	}
+
+	var input WishlistInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	wishlist, err := h.service.UpdateWishlist(c.Request.Context(), c.GetUint("user_id"), uint(id), input.Name, input.IsPublic)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, wishlist, "Wishlist updated successfully")
+}
+
+// DeleteWishlist godoc
+// @Summary Delete a wishlist
+// @Description Delete one of the current user's wishlists
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Wishlist ID"
+// @Success 204 "No Content"
+// @Router /wishlists/{id} [delete]
+func (h *WishlistHandler) DeleteWishlist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist ID")
+		return
+	}
+
+	if err := h.service.DeleteWishlist(c.Request.Context(), c.GetUint("user_id"), uint(id)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.noContentResponse(c)
+}
+
+// AddWishlistItem godoc
+// @Summary Add item to wishlist
+// @Description Add a product to one of the current user's wishlists
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Wishlist ID"
+// @Param item body WishlistItemInput true "Wishlist item details"
+// @Success 201 {object} Response
+// @Router /wishlists/{id}/items [post]
+func (h *WishlistHandler) AddWishlistItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist ID")
+		return
+	}
+
+	var input WishlistItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	item, err := h.service.AddItem(c.Request.Context(), c.GetUint("user_id"), uint(id), input.ProductID)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.createdResponse(c, item)
+}
+
+// RemoveWishlistItem godoc
+// @Summary Remove item from wishlist
+// @Description Remove an item from one of the current user's wishlists
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Wishlist ID"
+// @Param itemId path int true "Wishlist Item ID"
+// @Success 204 "No Content"
+// @Router /wishlists/{id}/items/{itemId} [delete]
+func (h *WishlistHandler) RemoveWishlistItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist ID")
+		return
+	}
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist item ID")
+		return
+	}
+
+	if err := h.service.RemoveItem(c.Request.Context(), c.GetUint("user_id"), uint(id), uint(itemID)); err != nil {
+		h.errorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.noContentResponse(c)
+}
+
+// MoveWishlistItemToCart godoc
+// @Summary Move wishlist item to cart
+// @Description Add a wishlist item to the cart and remove it from the list
+// @Tags wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Wishlist ID"
+// @Param itemId path int true "Wishlist Item ID"
+// @Param X-Currency header string false "Currency to show prices in"
+// @Param item body MoveToCartInput false "Quantity to add, defaults to 1"
+// @Success 200 {object} Response
+// @Router /wishlists/{id}/items/{itemId}/move-to-cart [post]
+func (h *WishlistHandler) MoveWishlistItemToCart(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist ID")
+		return
+	}
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid wishlist item ID")
+		return
+	}
+
+	input := MoveToCartInput{Quantity: 1}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+			return
+		}
+	}
+
+	cart, err := h.service.MoveToCart(c.Request.Context(), c.GetUint("user_id"), uint(id), uint(itemID), input.Quantity)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !h.localizeCart(c, cart) {
+		return
+	}
+
+	h.successResponse(c, cart, "Item moved to cart successfully")
+}
+
+// SaveForLater godoc
+// @Summary Save cart item for later
+// @Description Move a cart item to a wishlist, by default the "Saved for later" list
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Cart Item ID"
+// @Param item body SaveForLaterInput false "Target wishlist"
+// @Success 200 {object} Response
+// @Router /cart/items/{id}/save-for-later [post]
+func (h *WishlistHandler) SaveForLater(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, "Invalid cart item ID")
+		return
+	}
+
+	var input SaveForLaterInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			h.errorResponse(c, http.StatusBadRequest, "Invalid input")
+			return
+		}
+	}
+
+	wishlist, err := h.service.SaveForLater(c.Request.Context(), c.GetUint("user_id"), uint(itemID), input.WishlistID)
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.successResponse(c, wishlist, "Item saved for later")
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/service"
+)
+
+// cliActorRole marks audit entries of actions taken from the command line
+const cliActorRole = "cli"
+
+var createAdminCommand = Command{
+	Name:    "create-admin",
+	Args:    "EMAIL",
+	Summary: "create an admin account, or make an existing account an admin with -promote",
+	Run:     createAdmin,
+}
+
+func createAdmin(env *Env, fs *flag.FlagSet, args []string) error {
+	name := fs.String("name", "Admin", "name of the account")
+	password := fs.String("password", "", "password of the account; read from ADMIN_PASSWORD, or generated and printed, when empty")
+	promote := fs.Bool("promote", false, "make the existing account with the email an admin")
+	args, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	email := strings.TrimSpace(args[0])
+	if !strings.Contains(email, "@") {
+		return fmt.Errorf("invalid email %q", email)
+	}
+
+	generated := false
+	if *password == "" {
+		*password = os.Getenv("ADMIN_PASSWORD")
+	}
+	if *password == "" {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		*password = hex.EncodeToString(buf)
+		generated = true
+	}
+
+	db := env.DB()
+	userService := service.NewUserService(repository.NewUserRepository(db))
+	user, created, err := userService.CreateAdmin(email, *name, *password, *promote)
+	if err != nil {
+		return err
+	}
+
+	action := "user.create_admin"
+	if !created {
+		action = "user.role_change"
+	}
+	entry := &models.AuditLog{
+		Action:     action,
+		ActorRole:  cliActorRole,
+		EntityType: "user",
+		EntityID:   fmt.Sprint(user.ID),
+		Metadata:   map[string]interface{}{"email": user.Email},
+	}
+	if err := repository.NewAuditRepository(db).Create(entry); err != nil {
+		return err
+	}
+
+	fmt.Printf("Admin %s (id %d)\n", user.Email, user.ID)
+	if created && generated {
+		fmt.Printf("Password: %s\n", *password)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/service"
+)
+
+var reindexSearchCommand = Command{
+	Name:    "reindex-search",
+	Summary: "rebuild the product search indexes",
+	Run:     reindexSearch,
+}
+
+func reindexSearch(env *Env, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	db := env.DB()
+	searchService := service.NewSearchService(repository.NewSearchRepository(db), repository.NewProductRepository(db), repository.NewCategoryRepository(db))
+	if err := searchService.Reindex(); err != nil {
+		return err
+	}
+	fmt.Println("Search indexes rebuilt")
+	return nil
+}
+
+var recalcRatingsCommand = Command{
+	Name:    "recalc-ratings",
+	Summary: "recompute the rating aggregates of every product from approved reviews",
+	Run:     recalcRatings,
+}
+
+func recalcRatings(env *Env, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	productService := service.NewProductService(repository.NewProductRepository(env.DB()), env.Config.BaseCurrency)
+	if err := productService.RecalculateRatings(); err != nil {
+		return err
+	}
+	fmt.Println("Ratings recalculated")
+	return nil
+}
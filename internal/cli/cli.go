@@ -0,0 +1,145 @@
+// Package cli implements the subcommands of the application binary. Every
+// command shares the configuration loaded from the environment and, when it
+// needs one, the database connection.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sajal/go-ecommerce/internal/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Env is what commands share
+type Env struct {
+	Config *config.Config
+	sqlLog logger.Interface
+	db     *gorm.DB
+}
+
+// DB connects to the database on first use and checks its schema as
+// MIGRATION_MODE says
+func (e *Env) DB() *gorm.DB {
+	if e.db == nil {
+		e.db = config.InitDB(e.Config, e.sqlLog)
+	}
+	return e.db
+}
+
+// Conn connects to the database without checking its schema
+func (e *Env) Conn() *gorm.DB {
+	if e.db == nil {
+		e.db = config.OpenDB(e.Config, e.sqlLog)
+	}
+	return e.db
+}
+
+// Command is one subcommand
+type Command struct {
+	Name    string
+	Args    string // Arguments after the flags, for the usage line
+	Summary string
+	Help    string // Shown below the summary by -h
+	LogSQL  bool   // Log every statement rather than only slow ones and errors
+	Run     func(env *Env, fs *flag.FlagSet, args []string) error
+}
+
+// commands lists the subcommands in the order usage shows them
+var commands = []Command{
+	serveCommand,
+	migrateCommand,
+	createAdminCommand,
+	seedCommand,
+	reindexSearchCommand,
+	exportOrdersCommand,
+	recalcRatingsCommand,
+}
+
+// errUsage makes Run print the usage of the command that returned it
+var errUsage = errors.New("invalid arguments")
+
+// Run runs the subcommand named by args[0], serve when there is none
+func Run(args []string) error {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return nil
+	}
+
+	var cmd *Command
+	for i := range commands {
+		if commands[i].Name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+	env := &Env{Config: config.LoadConfig()}
+
+	// Other commands keep standard output for their results
+	env.sqlLog = logger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      logger.Warn,
+	})
+	if cmd.LogSQL {
+		env.sqlLog = logger.Default.LogMode(logger.Info)
+	}
+
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s [flags] %s\n\n%s\n", os.Args[0], cmd.Name, cmd.Args, cmd.Summary)
+		if cmd.Help != "" {
+			fmt.Fprintf(fs.Output(), "\n%s\n", cmd.Help)
+		}
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	err := cmd.Run(env, fs, args)
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// parse parses the flags of a command and returns the remaining arguments,
+// failing when there are fewer than min or more than max of them
+func parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func usage() {
+	var b strings.Builder
+	fmt.Fprintf(&b, "usage: %s <command> [flags] [arguments]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-16s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(&b, "\nRun %s <command> -h for the flags of a command.\n", os.Args[0])
+	fmt.Fprint(os.Stderr, b.String())
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/config"
+	"github.com/sajal/go-ecommerce/internal/migrations"
+)
+
+var migrateCommand = Command{
+	Name:    "migrate",
+	Args:    "up [N] | down [N] | status | create NAME | force VERSION",
+	Summary: "apply, roll back or list schema migrations",
+	Help: "  up [N]          apply all pending migrations, or the next N\n" +
+		"  down [N]        roll back the last N migrations (default 1)\n" +
+		"  status          list migrations and when they were applied\n" +
+		"  create NAME     write empty scripts for a new migration\n" +
+		"  force VERSION   clear the dirty flag of a migration repaired by hand",
+	Run: migrate,
+}
+
+func migrate(env *Env, fs *flag.FlagSet, args []string) error {
+	dir := fs.String("dir", "internal/migrations/sql", "directory create writes the scripts to")
+	pending := fs.Bool("pending", false, "make force record the migration as not applied")
+	if len(args) == 0 {
+		return errUsage
+	}
+	command := args[0]
+	switch command {
+	case "up", "down", "status", "create", "force":
+	default:
+		// Lets -h print the usage
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return errUsage
+	}
+
+	if command == "create" {
+		args, err := parse(fs, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		up, down, err := migrations.Create(*dir, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return nil
+	}
+
+	// Migrations run on a schema the server would refuse, so the schema is
+	// not checked
+	migrator, err := config.NewMigrator(env.Conn(), env.Config)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up", "down":
+		args, err := parse(fs, args[1:], 0, 1)
+		if err != nil {
+			return err
+		}
+		n := 0
+		if command == "down" {
+			n = 1
+		}
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[0])
+			}
+		}
+		var done []migrations.Migration
+		if command == "up" {
+			done, err = migrator.Up(n)
+		} else {
+			done, err = migrator.Down(n)
+		}
+		for _, m := range done {
+			fmt.Printf("%s %s\n", command, m)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("nothing to do")
+		}
+		return err
+	case "status":
+		if _, err := parse(fs, args[1:], 0, 0); err != nil {
+			return err
+		}
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Dirty {
+				state += " (dirty)"
+			}
+			fmt.Printf("%-40s %s\n", s.Migration, state)
+		}
+		return nil
+	case "force":
+		args, err := parse(fs, args[1:], 1, 1)
+		if err != nil {
+			return err
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return migrator.Force(version, !*pending)
+	default:
+		return errUsage
+	}
+}
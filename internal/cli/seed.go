@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var seedCommand = Command{
+	Name:    "seed",
+	Summary: "fill an empty store with a fake catalog, customers and orders",
+	Help: "The same seed gives the same data, with order dates relative to today.\n" +
+		"No events are recorded, so no emails or webhooks go out.",
+	Run: seed,
+}
+
+// seedCategories names the seeded categories and the products they hold
+var seedCategories = []struct {
+	Name     string
+	Products []string
+	MinPrice int // Whole units of the base currency
+	MaxPrice int
+}{
+	{"Apparel", []string{"T-Shirt", "Hoodie", "Jacket", "Polo Shirt", "Sweater", "Rain Coat"}, 15, 120},
+	{"Footwear", []string{"Sneakers", "Hiking Boots", "Sandals", "Loafers", "Running Shoes"}, 30, 180},
+	{"Electronics", []string{"Headphones", "Bluetooth Speaker", "Phone Charger", "Smart Watch", "Keyboard", "Webcam"}, 20, 400},
+	{"Home & Kitchen", []string{"Coffee Mug", "Chef Knife", "Cutting Board", "Table Lamp", "Throw Pillow", "Kettle"}, 10, 150},
+	{"Books", []string{"Cookbook", "Travel Guide", "Notebook", "Novel", "Sketchbook"}, 8, 45},
+	{"Outdoors", []string{"Backpack", "Water Bottle", "Tent", "Sleeping Bag", "Camping Chair", "Headlamp"}, 12, 350},
+}
+
+var (
+	seedAdjectives = []string{"Classic", "Modern", "Vintage", "Essential", "Premium", "Compact", "Everyday", "Deluxe", "Urban", "Alpine"}
+	seedFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn", "Robin", "Drew"}
+	seedLastNames  = []string{"Smith", "Garcia", "Müller", "Kim", "Rossi", "Novak", "Silva", "Okafor", "Larsen", "Dubois", "Tanaka", "Cohen"}
+	seedCities     = []struct{ City, State, Country, ZipCode string }{
+		{"Portland", "OR", "US", "97201"},
+		{"Austin", "TX", "US", "73301"},
+		{"Berlin", "BE", "DE", "10115"},
+		{"Lyon", "ARA", "FR", "69001"},
+		{"Toronto", "ON", "CA", "M5H 2N2"},
+		{"Manchester", "ENG", "GB", "M1 1AE"},
+	}
+	seedStreets = []string{"Main Street", "Oak Avenue", "Station Road", "Lake Drive", "Market Square", "Hill Lane"}
+)
+
+// seedStatuses are the statuses seeded orders end up in, weighted by how
+// often they occur
+var seedStatuses = []struct {
+	Status models.OrderStatus
+	Weight int
+}{
+	{models.OrderStatusDelivered, 45},
+	{models.OrderStatusShipped, 15},
+	{models.OrderStatusProcessing, 10},
+	{models.OrderStatusPending, 15},
+	{models.OrderStatusCancelled, 10},
+	{models.OrderStatusRefunded, 5},
+}
+
+// seedDays is how far back seeded orders go
+const seedDays = 90
+
+func seedStatus(rng *rand.Rand) models.OrderStatus {
+	total := 0
+	for _, s := range seedStatuses {
+		total += s.Weight
+	}
+	n := rng.Intn(total)
+	for _, s := range seedStatuses {
+		if n < s.Weight {
+			return s.Status
+		}
+		n -= s.Weight
+	}
+	return models.OrderStatusPending
+}
+
+func seed(env *Env, fs *flag.FlagSet, args []string) error {
+	seedValue := fs.Int64("seed", 1, "random seed; the same seed gives the same data")
+	productCount := fs.Int("products", 60, "number of products")
+	userCount := fs.Int("users", 20, "number of customers")
+	orderCount := fs.Int("orders", 150, "number of orders")
+	password := fs.String("password", "password", "password of every seeded customer")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *productCount < 1 || *userCount < 1 || *orderCount < 0 {
+		return errors.New("seed at least one product and one customer")
+	}
+
+	db := env.DB()
+	var existing int64
+	if err := db.Model(&models.Product{}).Unscoped().Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return errors.New("the catalog is not empty, seed only fills new stores")
+	}
+
+	// Hashed once; BeforeSave leaves hashes alone
+	hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(*seedValue))
+	base := env.Config.BaseCurrency
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Catalog
+		categories := make([]models.Category, len(seedCategories))
+		for i, c := range seedCategories {
+			categories[i] = models.Category{Name: c.Name, Description: c.Name + " for every day"}
+		}
+		if err := tx.Create(&categories).Error; err != nil {
+			return err
+		}
+
+		products := make([]models.Product, *productCount)
+		for i := range products {
+			c := rng.Intn(len(seedCategories))
+			category := seedCategories[c]
+			name := seedAdjectives[rng.Intn(len(seedAdjectives))] + " " + category.Products[rng.Intn(len(category.Products))]
+			whole := category.MinPrice + rng.Intn(category.MaxPrice-category.MinPrice+1)
+			products[i] = models.Product{
+				Name:        name,
+				Description: fmt.Sprintf("A %s from our %s range.", name, category.Name),
+				Price:       money.FromMajor(float64(whole)+0.99, base),
+				Stock:       rng.Intn(200),
+				CategoryID:  categories[c].ID,
+				SKU:         fmt.Sprintf("SEED-%04d", i+1),
+				IsActive:    rng.Intn(10) > 0,
+			}
+		}
+		if err := tx.Create(&products).Error; err != nil {
+			return err
+		}
+
+		// Customers, each with a default shipping address
+		users := make([]models.User, *userCount)
+		for i := range users {
+			first, last := seedFirstNames[rng.Intn(len(seedFirstNames))], seedLastNames[rng.Intn(len(seedLastNames))]
+			users[i] = models.User{
+				Email:    fmt.Sprintf("customer%02d@example.com", i+1),
+				Password: string(hash),
+				Name:     first + " " + last,
+				Role:     models.RoleUser,
+				Locale:   "en",
+				Status:   models.UserStatusActive,
+			}
+		}
+		if err := tx.Create(&users).Error; err != nil {
+			return err
+		}
+
+		addresses := make([]models.Address, len(users))
+		for i := range addresses {
+			place := seedCities[rng.Intn(len(seedCities))]
+			addresses[i] = models.Address{
+				UserID:    &users[i].ID,
+				Type:      "shipping",
+				Street:    fmt.Sprintf("%d %s", 1+rng.Intn(200), seedStreets[rng.Intn(len(seedStreets))]),
+				City:      place.City,
+				State:     place.State,
+				Country:   place.Country,
+				ZipCode:   place.ZipCode,
+				IsDefault: true,
+			}
+		}
+		if err := tx.Create(&addresses).Error; err != nil {
+			return err
+		}
+
+		// Orders, oldest first so IDs follow the order dates
+		placed := make([]time.Time, *orderCount)
+		for i := range placed {
+			placed[i] = today.AddDate(0, 0, -rng.Intn(seedDays)).Add(time.Duration(rng.Intn(86400)) * time.Second)
+		}
+		sort.Slice(placed, func(i, j int) bool { return placed[i].Before(placed[j]) })
+
+		for i, at := range placed {
+			u := rng.Intn(len(users))
+			order := models.Order{
+				CreatedAt:         at,
+				UpdatedAt:         at,
+				UserID:            &users[u].ID,
+				Status:            seedStatus(rng),
+				TotalAmount:       money.Zero(base),
+				ShippingCost:      money.Zero(base),
+				TaxAmount:         money.Zero(base),
+				Discount:          money.Zero(base),
+				ExchangeRate:      1,
+				ShippingAddressID: addresses[u].ID,
+			}
+			if order.Status != models.OrderStatusPending && order.Status != models.OrderStatusCancelled {
+				order.PaymentID = fmt.Sprintf("seed_pi_%05d", i+1)
+			}
+			switch order.Status {
+			case models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusRefunded:
+				order.TrackingNumber = fmt.Sprintf("SEED%08d", rng.Intn(100000000))
+			}
+
+			for _, p := range rng.Perm(len(products))[:1+rng.Intn(min(4, len(products)))] {
+				quantity := 1 + rng.Intn(3)
+				price := products[p].Price
+				order.Items = append(order.Items, models.OrderItem{
+					CreatedAt: at,
+					UpdatedAt: at,
+					ProductID: products[p].ID,
+					Quantity:  quantity,
+					Price:     price,
+					Subtotal:  price.Mul(int64(quantity)),
+				})
+				order.TotalAmount = order.TotalAmount.Add(price.Mul(int64(quantity)))
+			}
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Seeded %d categories, %d products, %d customers and %d orders\n",
+			len(categories), len(products), len(users), len(placed))
+		return nil
+	})
+}
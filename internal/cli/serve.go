@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajal/go-ecommerce/internal/api"
+	"github.com/sajal/go-ecommerce/internal/config"
+	"github.com/sajal/go-ecommerce/internal/currency"
+	"github.com/sajal/go-ecommerce/internal/events"
+	"github.com/sajal/go-ecommerce/internal/health"
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/middleware"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/service"
+	"github.com/sajal/go-ecommerce/internal/storage"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
+)
+
+var serveCommand = Command{
+	Name:    "serve",
+	Summary: "start the API server and background workers (default)",
+	LogSQL:  true,
+	Run:     serve,
+}
+
+func serve(env *Env, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	cfg := env.Config
+
+	// Export traces; spans still buffered are flushed on shutdown
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), cfg.TracingExporter)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
+	// Initialize database
+	db := env.DB()
+
+	// Initialize router
+	router := gin.New()
+
+	// Apply global middlewares. Recovery runs inside the logger and metrics
+	// so that a panicking request is still logged and counted as a 500.
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.TracingMiddleware())
+	router.Use(middleware.MetricsMiddleware())
+	router.Use(middleware.LoggerMiddleware())
+	router.Use(gin.Recovery())
+	router.Use(middleware.CORSMiddleware())
+
+	// Initialize background job queue
+	queue := jobs.NewQueue(repository.NewJobRepository(db))
+	queue.DefaultConcurrency = int(cfg.JobConcurrency)
+
+	// Initialize domain event dispatcher
+	dispatcher := events.NewDispatcher(repository.NewOutboxRepository(db), queue)
+	for _, sink := range strings.Split(cfg.EventSinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "":
+		case "log":
+			dispatcher.AddSink(events.LogSink{})
+		default:
+			log.Fatalf("Unknown event sink %q", sink)
+		}
+	}
+
+	// Initialize audit log, archiving pruned entries to the data directory
+	auditRetention := time.Duration(cfg.AuditRetentionDays) * 24 * time.Hour
+	auditService := service.NewAuditService(repository.NewAuditRepository(db), storage.NewLocalStore(cfg.DataDir, ""), auditRetention)
+
+	// Initialize currencies and the source exchange rates are fetched from
+	currencyService, err := newCurrencyService(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	// Readiness checks of the database and the background subsystems
+	checker := health.NewChecker()
+	checker.Add("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	if cfg.MigrationMode != config.MigrationIgnore {
+		migrator, err := config.NewMigrator(db, cfg)
+		if err != nil {
+			return err
+		}
+		checker.Add("migrations", func(ctx context.Context) error { return migrator.Check() })
+	}
+	checker.Add("jobs", func(ctx context.Context) error { return queue.Ready() })
+	checker.Add("events", func(ctx context.Context) error { return dispatcher.Ready() })
+	checker.Add("exchange_rates", func(ctx context.Context) error { return currencyService.Ready() })
+
+	// Initialize API handler
+	handler := api.NewHandler(db, cfg, queue, dispatcher, auditService, currencyService, checker)
+
+	// Start background workers; they stop after the server has drained
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	queue.Start(workers)
+	dispatcher.Start(workers)
+	auditService.Start(workers)
+	currencyService.Start(workers)
+	handler.Start(workers)
+
+	// Setup routes
+	handler.SetupRoutes(router)
+
+	// Prometheus metrics
+	if cfg.MetricsPath != "" {
+		router.GET(cfg.MetricsPath, gin.WrapH(telemetry.Handler()))
+	}
+
+	// Swagger documentation
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Start server
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadHeaderTimeout: seconds(cfg.ReadTimeoutSeconds),
+		ReadTimeout:       seconds(cfg.ReadTimeoutSeconds),
+		WriteTimeout:      seconds(cfg.WriteTimeoutSeconds),
+		IdleTimeout:       seconds(cfg.IdleTimeoutSeconds),
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-signals.Done():
+	}
+	// A second signal kills the process without waiting
+	stopSignals()
+
+	log.Printf("Shutting down, draining requests and background workers")
+	checker.ShutDown()
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(cfg.ShutdownTimeoutSeconds))
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to drain requests: %v", err)
+	}
+
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		queue.Wait()
+		dispatcher.Wait()
+		auditService.Wait()
+		currencyService.Wait()
+		handler.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		// Jobs still running are returned to their queue when they go stale
+		log.Printf("Background workers did not stop in time")
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	log.Printf("Shut down")
+	return nil
+}
+
+// seconds converts a configured number of seconds
+func seconds(n int64) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// newCurrencyService sets up the configured currencies and the source
+// exchange rates are fetched from
+func newCurrencyService(cfg *config.Config, db *gorm.DB) (*service.CurrencyService, error) {
+	if !currency.Valid(cfg.BaseCurrency) {
+		return nil, fmt.Errorf("invalid BASE_CURRENCY %q", cfg.BaseCurrency)
+	}
+	currencies, err := currency.ParseList(cfg.Currencies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CURRENCIES: %w", err)
+	}
+	rounding, err := money.ParseRounding(cfg.CurrencyRounding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CURRENCY_ROUNDING: %w", err)
+	}
+	rateSource, err := currency.NewSource(cfg.ExchangeRateSource, cfg.ExchangeRates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchange rate configuration: %w", err)
+	}
+	rateRefresh := time.Duration(cfg.ExchangeRateRefreshHours) * time.Hour
+	return service.NewCurrencyService(repository.NewCurrencyRepository(db), rateSource, cfg.BaseCurrency, currencies, rounding, rateRefresh), nil
+}
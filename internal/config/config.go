@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -18,6 +19,48 @@ type Config struct {
 	StripeSecretKey string
 	UploadDir       string
 	MaxFileSize     int64
+	DataDir         string // Private files that are never served directly
+	MaxImportSize   int64
+	JobConcurrency  int64  // Workers per job queue unless the queue sets its own
+	EventSinks      string // Comma-separated external event sinks, e.g. "log"
+
+	// Email
+	StoreName        string
+	StoreURL         string
+	StoreAddress     string // Printed on invoices; ";" separates lines
+	MailBackend      string // "smtp" or "file"
+	MailDir          string // Where the file backend writes .eml files
+	MailFrom         string
+	SMTPHost         string
+	SMTPPort         string
+	SMTPUsername     string
+	SMTPPassword     string
+	EmailTemplateDir string // Overrides the built-in email templates
+
+	// Audit log entries older than this many days are archived and pruned;
+	// 0 keeps them forever
+	AuditRetentionDays int64
+
+	// Currencies
+	BaseCurrency             string // Currency product prices are kept in
+	Currencies               string // Comma-separated currencies customers can choose
+	ExchangeRateSource       string // "static" or "ecb"
+	ExchangeRates            string // Static rates per unit of the base currency, e.g. "EUR=0.92,GBP=0.79"
+	ExchangeRateRefreshHours int64
+	CurrencyRounding         string // Steps converted prices are rounded to, e.g. "CHF=0.05,JPY=10"
+
+	// Migrations
+	MigrationMode string // "check" refuses to start with pending or dirty migrations, "apply" applies pending ones, "ignore" only logs
+
+	// Server timeouts
+	ReadTimeoutSeconds     int64 // Reading a request, body included
+	WriteTimeoutSeconds    int64 // Writing the response, from the end of the request headers
+	IdleTimeoutSeconds     int64 // Keep-alive connections waiting for their next request
+	ShutdownTimeoutSeconds int64 // Draining requests and background workers on SIGTERM
+
+	// Telemetry
+	MetricsPath     string // Where Prometheus scrapes metrics; empty disables the endpoint
+	TracingExporter string // "stdout" or "otlp"; empty disables tracing
 }
 
 func LoadConfig() *Config {
@@ -34,6 +77,41 @@ func LoadConfig() *Config {
 		StripeSecretKey: getEnv("STRIPE_SECRET_KEY", ""),
 		UploadDir:       getEnv("UPLOAD_DIR", "uploads"),
 		MaxFileSize:     getEnvAsInt64("MAX_FILE_SIZE", 5242880), // 5MB default
+		DataDir:         getEnv("DATA_DIR", "data"),
+		MaxImportSize:   getEnvAsInt64("MAX_IMPORT_SIZE", 20971520), // 20MB default
+		JobConcurrency:  getEnvAsInt64("JOB_CONCURRENCY", 2),
+		EventSinks:      getEnv("EVENT_SINKS", ""),
+
+		StoreName:        getEnv("STORE_NAME", "Go E-commerce"),
+		StoreURL:         getEnv("STORE_URL", "http://localhost:8080"),
+		StoreAddress:     getEnv("STORE_ADDRESS", ""),
+		MailBackend:      getEnv("MAIL_BACKEND", "file"),
+		MailDir:          getEnv("MAIL_DIR", "data/mail"),
+		MailFrom:         getEnv("MAIL_FROM", "Go E-commerce <no-reply@localhost>"),
+		SMTPHost:         getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:         getEnv("SMTP_PORT", "587"),
+		SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		EmailTemplateDir: getEnv("EMAIL_TEMPLATE_DIR", ""),
+
+		AuditRetentionDays: getEnvAsInt64("AUDIT_RETENTION_DAYS", 365),
+
+		BaseCurrency:             strings.ToUpper(getEnv("BASE_CURRENCY", "USD")),
+		Currencies:               getEnv("CURRENCIES", ""),
+		ExchangeRateSource:       getEnv("EXCHANGE_RATE_SOURCE", "static"),
+		ExchangeRates:            getEnv("EXCHANGE_RATES", ""),
+		ExchangeRateRefreshHours: getEnvAsInt64("EXCHANGE_RATE_REFRESH_HOURS", 24),
+		CurrencyRounding:         getEnv("CURRENCY_ROUNDING", ""),
+
+		MigrationMode: getEnv("MIGRATION_MODE", "check"),
+
+		ReadTimeoutSeconds:     getEnvAsInt64("READ_TIMEOUT_SECONDS", 30),
+		WriteTimeoutSeconds:    getEnvAsInt64("WRITE_TIMEOUT_SECONDS", 60),
+		IdleTimeoutSeconds:     getEnvAsInt64("IDLE_TIMEOUT_SECONDS", 120),
+		ShutdownTimeoutSeconds: getEnvAsInt64("SHUTDOWN_TIMEOUT_SECONDS", 30),
+
+		MetricsPath:     getEnv("METRICS_PATH", "/metrics"),
+		TracingExporter: getEnv("TRACING_EXPORTER", ""),
 	}
 }
 
@@ -1,16 +1,27 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
-	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/migrations"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func InitDB(config *Config) *gorm.DB {
+// Ways the server treats migrations that have not been applied yet
+const (
+	MigrationCheck  = "check"
+	MigrationApply  = "apply"
+	MigrationIgnore = "ignore"
+)
+
+// OpenDB connects to the database without looking at its schema. SQL is
+// logged to sqlLog.
+func OpenDB(config *Config, sqlLog logger.Interface) *gorm.DB {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		config.DBHost,
 		config.DBPort,
@@ -21,27 +32,60 @@ func InitDB(config *Config) *gorm.DB {
 	)
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: sqlLog,
 	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if err := db.Use(telemetry.GormPlugin{}); err != nil {
+		log.Fatalf("Failed to instrument database: %v", err)
+	}
+	return db
+}
 
-	// Auto migrate the schema
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Product{},
-		&models.Category{},
-		&models.Image{},
-		&models.Order{},
-		&models.OrderItem{},
-		&models.Cart{},
-		&models.CartItem{},
-		&models.Address{},
-		&models.Review{},
-	)
+// NewMigrator creates a migrator whose scripts can read the base currency
+// from the app.base_currency setting
+func NewMigrator(db *gorm.DB, config *Config) (*migrations.Migrator, error) {
+	migrator, err := migrations.New(db)
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return nil, err
+	}
+	migrator.Settings = map[string]string{"app.base_currency": config.BaseCurrency}
+	return migrator, nil
+}
+
+// InitDB connects to the database and makes sure its schema is up to date
+// as configured by MigrationMode
+func InitDB(config *Config, sqlLog logger.Interface) *gorm.DB {
+	db := OpenDB(config, sqlLog)
+
+	migrator, err := NewMigrator(db, config)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	switch config.MigrationMode {
+	case MigrationApply:
+		applied, err := migrator.Up(0)
+		for _, m := range applied {
+			log.Printf("Applied migration %s", m)
+		}
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	case MigrationCheck:
+		if err := migrator.Check(); err != nil {
+			if errors.Is(err, migrations.ErrPending) {
+				log.Fatalf("%v; run \"migrate up\" or set MIGRATION_MODE=apply", err)
+			}
+			log.Fatalf("Failed to check migrations: %v", err)
+		}
+	case MigrationIgnore:
+		if err := migrator.Check(); err != nil {
+			log.Printf("Starting anyway: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown MIGRATION_MODE %q", config.MigrationMode)
 	}
 
 	return db
@@ -0,0 +1,91 @@
+// Package currency holds currency codes, their decimal places and the
+// sources exchange rates are fetched from.
+package currency
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var codePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// minorDigits lists the ISO 4217 currencies that do not have two decimal
+// places
+var minorDigits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Valid reports whether code looks like an ISO 4217 currency code
+func Valid(code string) bool {
+	return codePattern.MatchString(code)
+}
+
+// DecimalsSQL returns a SQL expression for the decimal places of the
+// currency code stored in column
+func DecimalsSQL(column string) string {
+	codes := make([]string, 0, len(minorDigits))
+	for code := range minorDigits {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "(CASE %s", column)
+	for _, code := range codes {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", code, minorDigits[code])
+	}
+	b.WriteString(" ELSE 2 END)")
+	return b.String()
+}
+
+// Normalize upper-cases a currency code and trims spaces around it
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Decimals is the number of decimal places amounts in the currency have
+func Decimals(code string) int {
+	if d, ok := minorDigits[code]; ok {
+		return d
+	}
+	return 2
+}
+
+// ParseList parses a comma-separated list of currency codes
+func ParseList(s string) ([]string, error) {
+	var codes []string
+	for _, part := range strings.Split(s, ",") {
+		code := Normalize(part)
+		if code == "" {
+			continue
+		}
+		if !Valid(code) {
+			return nil, fmt.Errorf("invalid currency code %q", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// ParsePairs parses a comma-separated list of CODE=value pairs such as
+// "EUR=0.92,GBP=0.79"
+func ParsePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(part, "=")
+		code = Normalize(code)
+		if !ok || !Valid(code) || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected CODE=value", part)
+		}
+		pairs[code] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,127 @@
+package currency
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateSource supplies exchange rates as units of each currency per unit of
+// the base currency
+type RateSource interface {
+	Name() string
+	Rates(ctx context.Context, base string) (map[string]float64, error)
+}
+
+// NewSource builds the rate source selected in the configuration. Static
+// rates are written as "EUR=0.92,GBP=0.79".
+func NewSource(name string, staticRates string) (RateSource, error) {
+	switch name {
+	case "", "static":
+		pairs, err := ParsePairs(staticRates)
+		if err != nil {
+			return nil, fmt.Errorf("exchange rates: %v", err)
+		}
+		rates := make(StaticSource, len(pairs))
+		for code, value := range pairs {
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate <= 0 {
+				return nil, fmt.Errorf("exchange rates: invalid rate %q for %s", value, code)
+			}
+			rates[code] = rate
+		}
+		return rates, nil
+	case "ecb":
+		return NewECBSource(), nil
+	default:
+		return nil, fmt.Errorf("unknown exchange rate source %q", name)
+	}
+}
+
+// StaticSource returns fixed rates from the configuration
+type StaticSource map[string]float64
+
+func (s StaticSource) Name() string {
+	return "static"
+}
+
+func (s StaticSource) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	rates := make(map[string]float64, len(s))
+	for code, rate := range s {
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+// ECBDailyURL is the European Central Bank's daily reference rate feed
+const ECBDailyURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// ECBSource fetches the European Central Bank's daily reference rates,
+// which are quoted against the euro, and converts them to the base currency
+type ECBSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewECBSource() *ECBSource {
+	return &ECBSource{URL: ECBDailyURL, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *ECBSource) Name() string {
+	return "ecb"
+}
+
+type ecbFeed struct {
+	Rates []struct {
+		Currency string `xml:"currency,attr"`
+		Rate     string `xml:"rate,attr"`
+	} `xml:"Cube>Cube>Cube"`
+}
+
+func (s *ECBSource) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ecb: unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var feed ecbFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("ecb: %v", err)
+	}
+	perEuro := map[string]float64{"EUR": 1}
+	for _, r := range feed.Rates {
+		rate, err := strconv.ParseFloat(r.Rate, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("ecb: invalid rate %q for %s", r.Rate, r.Currency)
+		}
+		perEuro[r.Currency] = rate
+	}
+
+	baseRate, ok := perEuro[base]
+	if !ok {
+		return nil, fmt.Errorf("ecb: no rate for base currency %s", base)
+	}
+	rates := make(map[string]float64, len(perEuro))
+	for code, rate := range perEuro {
+		if code != base {
+			rates[code] = rate / baseRate
+		}
+	}
+	return rates, nil
+}
@@ -0,0 +1,219 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+)
+
+// Seller identifies the store at the top of every document
+type Seller struct {
+	Name    string
+	Address string // May span several lines
+}
+
+const (
+	pageMargin = 15.0
+	lineHeight = 5.0
+)
+
+// document wraps an A4 page layout with text passed through the Latin-1
+// translation the core PDF fonts need
+type document struct {
+	pdf *fpdf.Fpdf
+	tr  func(string) string
+}
+
+func newDocument() *document {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	return &document{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+func (d *document) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// header prints the seller on the left and the document title on the right
+func (d *document) header(seller Seller, title string, details [][2]string) {
+	d.pdf.AddPage()
+
+	d.pdf.SetFont("Helvetica", "B", 16)
+	d.pdf.CellFormat(100, 8, d.tr(seller.Name), "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(0, 8, d.tr(title), "", 1, "R", false, 0, "")
+
+	d.pdf.SetFont("Helvetica", "", 9)
+	y := d.pdf.GetY()
+	d.pdf.MultiCell(100, 4.5, d.tr(seller.Address), "", "L", false)
+	sellerEnd := d.pdf.GetY()
+
+	d.pdf.SetY(y)
+	for _, detail := range details {
+		d.pdf.SetX(115)
+		d.pdf.CellFormat(40, 4.5, d.tr(detail[0]), "", 0, "L", false, 0, "")
+		d.pdf.CellFormat(0, 4.5, d.tr(detail[1]), "", 1, "R", false, 0, "")
+	}
+	if sellerEnd > d.pdf.GetY() {
+		d.pdf.SetY(sellerEnd)
+	}
+	d.pdf.Ln(8)
+}
+
+// addresses prints up to two labelled addresses side by side
+func (d *document) addresses(blocks ...[2]string) {
+	y := d.pdf.GetY()
+	end := y
+	for i, block := range blocks {
+		x := pageMargin + float64(i)*95
+		d.pdf.SetXY(x, y)
+		d.pdf.SetFont("Helvetica", "B", 10)
+		d.pdf.CellFormat(90, lineHeight, d.tr(block[0]), "", 2, "L", false, 0, "")
+		d.pdf.SetFont("Helvetica", "", 10)
+		d.pdf.MultiCell(90, lineHeight, d.tr(block[1]), "", "L", false)
+		if d.pdf.GetY() > end {
+			end = d.pdf.GetY()
+		}
+	}
+	d.pdf.SetY(end)
+	d.pdf.Ln(8)
+}
+
+// table prints a header row and rows with the given column widths and
+// alignments
+func (d *document) table(widths []float64, aligns []string, headers []string, rows [][]string) {
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.SetFillColor(235, 235, 235)
+	for i, h := range headers {
+		d.pdf.CellFormat(widths[i], 7, d.tr(h), "B", 0, aligns[i], true, 0, "")
+	}
+	d.pdf.Ln(-1)
+
+	d.pdf.SetFont("Helvetica", "", 10)
+	for _, row := range rows {
+		for i, cell := range row {
+			d.pdf.CellFormat(widths[i], 6.5, d.tr(cell), "B", 0, aligns[i], false, 0, "")
+		}
+		d.pdf.Ln(-1)
+	}
+}
+
+func formatAddress(a *models.Address) string {
+	lines := []string{a.Street, strings.TrimSpace(fmt.Sprintf("%s, %s %s", a.City, a.State, a.ZipCode)), a.Country}
+	return strings.Join(lines, "\n")
+}
+
+// customer describes who placed the order
+func customer(order *models.Order) string {
+	if order.User != nil {
+		return fmt.Sprintf("%s\n%s", order.User.Name, order.User.Email)
+	}
+	return order.GuestEmail
+}
+
+func billingAddress(order *models.Order) *models.Address {
+	if order.BillingAddress != nil {
+		return order.BillingAddress
+	}
+	return &order.ShippingAddress
+}
+
+// Invoice renders the invoice for an order
+func Invoice(seller Seller, invoice *models.Invoice, order *models.Order) ([]byte, error) {
+	d := newDocument()
+	d.header(seller, "INVOICE", [][2]string{
+		{"Invoice number", invoice.Number},
+		{"Invoice date", invoice.IssuedAt.Format("2006-01-02")},
+		{"Order number", fmt.Sprintf("#%d", order.ID)},
+		{"Order date", order.CreatedAt.Format("2006-01-02")},
+		{"Currency", order.Currency},
+	})
+
+	d.addresses(
+		[2]string{"Bill to", customer(order) + "\n" + formatAddress(billingAddress(order))},
+		[2]string{"Ship to", formatAddress(&order.ShippingAddress)},
+	)
+
+	var rows [][]string
+	subtotal := money.Zero(order.Currency)
+	for _, item := range order.Items {
+		rows = append(rows, []string{
+			item.Product.Name,
+			item.Product.SKU,
+			fmt.Sprintf("%d", item.Quantity),
+			item.Price.Decimal(),
+			item.Subtotal.Decimal(),
+		})
+		subtotal = subtotal.Add(item.Subtotal)
+	}
+	widths := []float64{80, 30, 15, 27.5, 27.5}
+	aligns := []string{"L", "L", "R", "R", "R"}
+	d.table(widths, aligns, []string{"Item", "SKU", "Qty", "Unit price", "Amount"}, rows)
+
+	d.pdf.Ln(3)
+	totals := [][2]string{{"Subtotal", subtotal.Decimal()}}
+	if !order.Discount.IsZero() {
+		totals = append(totals, [2]string{"Discount", "-" + order.Discount.Decimal()})
+	}
+	if !order.ShippingCost.IsZero() {
+		totals = append(totals, [2]string{"Shipping", order.ShippingCost.Decimal()})
+	}
+	if !order.TaxAmount.IsZero() {
+		totals = append(totals, [2]string{"Tax", order.TaxAmount.Decimal()})
+	}
+	totals = append(totals, [2]string{"Total", order.TotalAmount.Decimal()})
+
+	for i, total := range totals {
+		if i == len(totals)-1 {
+			d.pdf.SetFont("Helvetica", "B", 11)
+		} else {
+			d.pdf.SetFont("Helvetica", "", 10)
+		}
+		d.pdf.SetX(pageMargin + 110)
+		d.pdf.CellFormat(42.5, 6.5, d.tr(total[0]), "", 0, "L", false, 0, "")
+		d.pdf.CellFormat(27.5, 6.5, total[1], "", 1, "R", false, 0, "")
+	}
+
+	return d.bytes()
+}
+
+// PackingSlips renders one packing slip page per order. Packing slips list
+// what to pack and where to send it, without prices.
+func PackingSlips(seller Seller, orders []models.Order) ([]byte, error) {
+	d := newDocument()
+	for i := range orders {
+		order := &orders[i]
+		d.header(seller, "PACKING SLIP", [][2]string{
+			{"Order number", fmt.Sprintf("#%d", order.ID)},
+			{"Order date", order.CreatedAt.Format("2006-01-02")},
+			{"Tracking number", order.TrackingNumber},
+		})
+
+		d.addresses(
+			[2]string{"Ship to", customer(order) + "\n" + formatAddress(&order.ShippingAddress)},
+		)
+
+		var rows [][]string
+		for _, item := range order.Items {
+			rows = append(rows, []string{item.Product.SKU, item.Product.Name, fmt.Sprintf("%d", item.Quantity), ""})
+		}
+		d.table([]float64{35, 105, 20, 20}, []string{"L", "L", "R", "C"}, []string{"SKU", "Item", "Qty", "Packed"}, rows)
+
+		if order.Notes != "" {
+			d.pdf.Ln(6)
+			d.pdf.SetFont("Helvetica", "B", 10)
+			d.pdf.CellFormat(0, lineHeight, "Notes", "", 1, "L", false, 0, "")
+			d.pdf.SetFont("Helvetica", "", 10)
+			d.pdf.MultiCell(0, lineHeight, d.tr(order.Notes), "", "L", false)
+		}
+	}
+	return d.bytes()
+}
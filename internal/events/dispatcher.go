@@ -0,0 +1,240 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// EventsQueue is the job queue that delivers events to subscribers
+	EventsQueue     = "events"
+	JobDeliverEvent = "event.deliver"
+)
+
+// Subscriber handles one event. A returned error retries the delivery to
+// this subscriber only, with the job queue's backoff.
+type Subscriber func(ctx context.Context, event Event) error
+
+// Sink is an external destination that receives every event
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// Handle adapts a function taking a decoded payload into a Subscriber
+func Handle[T any](fn func(ctx context.Context, payload T) error) Subscriber {
+	return func(ctx context.Context, event Event) error {
+		var payload T
+		if err := event.Decode(&payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %w", event.Type, err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type subscription struct {
+	types map[string]bool // Nil matches every type
+	fn    Subscriber
+}
+
+func (s subscription) matches(eventType string) bool {
+	return s.types == nil || s.types[eventType]
+}
+
+// deliveryJob is the payload of a JobDeliverEvent job
+type deliveryJob struct {
+	EventID    uint   `json:"event_id"`
+	Subscriber string `json:"subscriber"`
+}
+
+// Dispatcher moves events from the outbox to subscribers. Each event becomes
+// one delivery job per interested subscriber, enqueued in the transaction
+// that marks the event dispatched, so every subscriber sees every event at
+// least once and retries independently.
+type Dispatcher struct {
+	repo  *repository.OutboxRepository
+	queue *jobs.Queue
+
+	// PollInterval is how often the outbox is checked for new events
+	PollInterval time.Duration
+	// BatchSize is how many events are dispatched per transaction
+	BatchSize int
+	// Retention is how long dispatched events are kept
+	Retention time.Duration
+
+	mu          sync.RWMutex
+	subscribers map[string]subscription
+	wg          sync.WaitGroup
+
+	statusMu sync.Mutex
+	running  bool
+	lastErr  error // Outcome of the last poll of the outbox
+}
+
+func NewDispatcher(repo *repository.OutboxRepository, queue *jobs.Queue) *Dispatcher {
+	d := &Dispatcher{
+		repo:         repo,
+		queue:        queue,
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Retention:    7 * 24 * time.Hour,
+		subscribers:  make(map[string]subscription),
+	}
+	jobs.Register(queue, EventsQueue, JobDeliverEvent, d.deliver)
+	return d
+}
+
+// Subscribe delivers events of the given types, or of every type when none
+// are given, to fn. The name identifies the subscriber in delivery jobs and
+// must stay stable across restarts.
+func (d *Dispatcher) Subscribe(name string, fn Subscriber, types ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.subscribers[name]; ok {
+		panic("events: duplicate subscriber " + name)
+	}
+	sub := subscription{fn: fn}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+	d.subscribers[name] = sub
+}
+
+// AddSink delivers every event to sink
+func (d *Dispatcher) AddSink(sink Sink) {
+	d.Subscribe("sink:"+sink.Name(), sink.Publish)
+}
+
+// Start polls the outbox until ctx is cancelled; Wait blocks until it stops
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.setStatus(true, nil)
+	d.wg.Add(1)
+	go d.run(ctx)
+}
+
+// Wait blocks until the dispatcher has stopped
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Ready reports whether the dispatcher is running and could read the outbox
+// the last time it tried
+func (d *Dispatcher) Ready() error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+	if !d.running {
+		return errors.New("dispatcher is not running")
+	}
+	if d.lastErr != nil {
+		return fmt.Errorf("dispatching events failed: %w", d.lastErr)
+	}
+	return nil
+}
+
+func (d *Dispatcher) setStatus(running bool, err error) {
+	d.statusMu.Lock()
+	d.running, d.lastErr = running, err
+	d.statusMu.Unlock()
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer d.wg.Done()
+	defer d.setStatus(false, nil)
+
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		err := d.DispatchPending()
+		if err != nil {
+			log.Printf("Failed to dispatch events: %v", err)
+		}
+		d.setStatus(true, err)
+
+		if time.Since(lastPrune) > time.Hour {
+			lastPrune = time.Now()
+			if _, err := d.repo.DeleteDispatchedBefore(time.Now().Add(-d.Retention)); err != nil {
+				log.Printf("Failed to prune dispatched events: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending turns all undispatched events into delivery jobs
+func (d *Dispatcher) DispatchPending() error {
+	for {
+		n, err := d.repo.DispatchPending(d.BatchSize, d.enqueueDeliveries)
+		if err != nil {
+			return err
+		}
+		if n < d.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) enqueueDeliveries(tx *gorm.DB, events []models.OutboxEvent) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, event := range events {
+		for name, sub := range d.subscribers {
+			if !sub.matches(event.Type) {
+				continue
+			}
+			if _, err := d.queue.EnqueueTx(tx, JobDeliverEvent, deliveryJob{EventID: event.ID, Subscriber: name}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, job deliveryJob) error {
+	d.mu.RLock()
+	sub, ok := d.subscribers[job.Subscriber]
+	d.mu.RUnlock()
+	if !ok {
+		// The subscriber was removed since the event was dispatched
+		log.Printf("Dropping event %d for unknown subscriber %s", job.EventID, job.Subscriber)
+		return nil
+	}
+
+	event, err := d.repo.FindByID(job.EventID)
+	if err != nil {
+		return fmt.Errorf("event %d not found", job.EventID)
+	}
+	return sub.fn(ctx, fromOutbox(event))
+}
+
+// LogSink writes every event to the standard logger
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, event Event) error {
+	log.Printf("Event %d %s: %s", event.ID, event.Type, event.Payload)
+	return nil
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+)
+
+// Domain event types
+const (
+	TypeOrderPlaced         = "order.placed"
+	TypeOrderStatusChanged  = "order.status_changed"
+	TypeProductStockChanged = "product.stock_changed"
+	TypeUserRegistered      = "user.registered"
+	TypeReviewPosted        = "review.posted"
+)
+
+// Types lists every domain event type
+var Types = []string{
+	TypeOrderPlaced,
+	TypeOrderStatusChanged,
+	TypeProductStockChanged,
+	TypeUserRegistered,
+	TypeReviewPosted,
+}
+
+// Event is a dispatched domain event as seen by subscribers
+type Event struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Decode unmarshals the event payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+func fromOutbox(e *models.OutboxEvent) Event {
+	return Event{
+		ID:         e.ID,
+		Type:       e.Type,
+		OccurredAt: e.CreatedAt,
+		Payload:    e.Payload,
+	}
+}
+
+// OrderPlacedData is the payload of TypeOrderPlaced
+type OrderPlacedData struct {
+	Order *models.Order `json:"order"`
+}
+
+// OrderStatusChangedData is the payload of TypeOrderStatusChanged
+type OrderStatusChangedData struct {
+	OrderID        uint               `json:"order_id"`
+	UserID         *uint              `json:"user_id"` // Nil for guest orders
+	OldStatus      models.OrderStatus `json:"old_status"`
+	NewStatus      models.OrderStatus `json:"new_status"`
+	TrackingNumber string             `json:"tracking_number,omitempty"`
+}
+
+// ProductStockChangedData is the payload of TypeProductStockChanged
+type ProductStockChangedData struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	OldStock  int    `json:"old_stock"`
+	NewStock  int    `json:"new_stock"`
+}
+
+// UserRegisteredData is the payload of TypeUserRegistered
+type UserRegisteredData struct {
+	User *models.User `json:"user"`
+}
+
+// ReviewPostedData is the payload of TypeReviewPosted
+type ReviewPostedData struct {
+	Review *models.Review `json:"review"`
+}
+
+// OrderPlaced records that order was placed. The order is encoded when the
+// event is saved, after the order itself.
+func OrderPlaced(order *models.Order) models.OutboxEvent {
+	return models.OutboxEvent{Type: TypeOrderPlaced, Data: OrderPlacedData{Order: order}}
+}
+
+// OrderStatusChanged records an order moving from its current status to status
+func OrderStatusChanged(order *models.Order, status models.OrderStatus, trackingNumber string) models.OutboxEvent {
+	return models.OutboxEvent{Type: TypeOrderStatusChanged, Data: OrderStatusChangedData{
+		OrderID:        order.ID,
+		UserID:         order.UserID,
+		OldStatus:      order.Status,
+		NewStatus:      status,
+		TrackingNumber: trackingNumber,
+	}}
+}
+
+// ProductStockChanged records the stock of product changing to stock
+func ProductStockChanged(product *models.Product, stock int) models.OutboxEvent {
+	return models.OutboxEvent{Type: TypeProductStockChanged, Data: ProductStockChangedData{
+		ProductID: product.ID,
+		SKU:       product.SKU,
+		OldStock:  product.Stock,
+		NewStock:  stock,
+	}}
+}
+
+// UserRegistered records a new account
+func UserRegistered(user *models.User) models.OutboxEvent {
+	return models.OutboxEvent{Type: TypeUserRegistered, Data: UserRegisteredData{User: user}}
+}
+
+// ReviewPosted records a new review awaiting moderation
+func ReviewPosted(review *models.Review) models.OutboxEvent {
+	return models.OutboxEvent{Type: TypeReviewPosted, Data: ReviewPostedData{Review: review}}
+}
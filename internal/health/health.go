@@ -0,0 +1,128 @@
+// Package health runs the readiness checks of the server's components and
+// reports their status.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component and overall statuses
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check returns an error when its component cannot serve requests
+type Check func(ctx context.Context) error
+
+// Component is the outcome of one check
+type Component struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// OK reports whether every component passed and the server is not shutting
+// down
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	name string
+	fn   Check
+}
+
+// Checker holds the checks of the components the server depends on. It is
+// safe for concurrent use.
+type Checker struct {
+	// Timeout bounds each check; a check that runs longer fails
+	Timeout time.Duration
+
+	mu           sync.RWMutex
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{Timeout: 2 * time.Second}
+}
+
+// Add registers the check of a component
+func (c *Checker) Add(name string, fn Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// ShutDown makes every later report fail so load balancers stop sending
+// requests while the server drains
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Run runs all checks at once and reports their outcome
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Components: make(map[string]Component, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range checks {
+		wg.Add(1)
+		go func(ch check) {
+			defer wg.Done()
+			start := time.Now()
+			err := c.run(ctx, ch.fn)
+			component := Component{Status: StatusOK, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				component.Status = StatusFailing
+				component.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[ch.name] = component
+			if err != nil {
+				report.Status = StatusFailing
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	if c.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// run runs one check, giving up after the timeout. Checks that ignore their
+// context finish in the background.
+func (c *Checker) run(ctx context.Context, fn Check) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New("timed out")
+	}
+}
@@ -0,0 +1,353 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultQueue runs job types registered without a queue of their own
+	DefaultQueue = "default"
+	// DefaultMaxAttempts is how often a job runs before it is marked dead
+	DefaultMaxAttempts = 5
+
+	minBackoff = 10 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Handler runs one job. A returned error retries the job with backoff until
+// it runs out of attempts.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type attemptKey struct{}
+
+// Attempt returns which run of its job a handler's ctx belongs to, starting at 1
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+type registration struct {
+	queue   string
+	handler Handler
+}
+
+// Option adjusts a job as it is enqueued
+type Option func(*models.Job)
+
+// Delay schedules the job to run no sooner than d from now
+func Delay(d time.Duration) Option {
+	return func(job *models.Job) {
+		job.RunAt = time.Now().Add(d)
+	}
+}
+
+// At schedules the job to run no sooner than t
+func At(t time.Time) Option {
+	return func(job *models.Job) {
+		job.RunAt = t
+	}
+}
+
+// MaxAttempts overrides how often the job is tried
+func MaxAttempts(n int) Option {
+	return func(job *models.Job) {
+		job.MaxAttempts = n
+	}
+}
+
+// Queue stores jobs in Postgres and runs them on per-queue worker pools.
+// Job types are registered before Start, and each type runs on the queue it
+// was registered with.
+type Queue struct {
+	repo *repository.JobRepository
+
+	// PollInterval is how long idle workers wait before looking for due jobs
+	PollInterval time.Duration
+	// JobTimeout bounds one run of a job; running jobs older than this are
+	// assumed lost and returned to their queue
+	JobTimeout time.Duration
+	// DefaultConcurrency is the worker count of queues without SetConcurrency
+	DefaultConcurrency int
+
+	mu          sync.RWMutex
+	handlers    map[string]registration
+	concurrency map[string]int
+	wake        map[string]chan struct{}
+	started     bool
+	ctx         context.Context // Passed to Start
+	wg          sync.WaitGroup
+}
+
+func NewQueue(repo *repository.JobRepository) *Queue {
+	return &Queue{
+		repo:               repo,
+		PollInterval:       5 * time.Second,
+		JobTimeout:         15 * time.Minute,
+		DefaultConcurrency: 2,
+		handlers:           make(map[string]registration),
+		concurrency:        make(map[string]int),
+		wake:               make(map[string]chan struct{}),
+	}
+}
+
+// Register runs jobs of jobType on the named queue with h
+func (q *Queue) Register(queue, jobType string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		panic("jobs: Register called after Start")
+	}
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	q.handlers[jobType] = registration{queue: queue, handler: h}
+	if _, ok := q.wake[queue]; !ok {
+		q.wake[queue] = make(chan struct{}, 1)
+	}
+}
+
+// Register runs jobs of jobType on the named queue with fn, decoding each
+// payload into T first
+func Register[T any](q *Queue, queue, jobType string, fn func(ctx context.Context, payload T) error) {
+	q.Register(queue, jobType, func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// SetConcurrency limits how many jobs of a queue run at once
+func (q *Queue) SetConcurrency(queue string, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.concurrency[queue] = n
+}
+
+// Enqueue stores a job of a registered type with its JSON-encoded payload
+func (q *Queue) Enqueue(jobType string, payload interface{}, opts ...Option) (*models.Job, error) {
+	return q.enqueue(q.repo, jobType, payload, opts)
+}
+
+// EnqueueTx is Enqueue within the transaction tx, so the job only exists if
+// tx commits
+func (q *Queue) EnqueueTx(tx *gorm.DB, jobType string, payload interface{}, opts ...Option) (*models.Job, error) {
+	return q.enqueue(repository.NewJobRepository(tx), jobType, payload, opts)
+}
+
+func (q *Queue) enqueue(repo *repository.JobRepository, jobType string, payload interface{}, opts []Option) (*models.Job, error) {
+	q.mu.RLock()
+	reg, ok := q.handlers[jobType]
+	q.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown job type %q", jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		Queue:       reg.queue,
+		Type:        jobType,
+		Payload:     data,
+		Status:      models.JobStatusPending,
+		RunAt:       time.Now(),
+		MaxAttempts: DefaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if err := repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	if !job.RunAt.After(time.Now()) {
+		q.notify(job.Queue)
+	}
+	return job, nil
+}
+
+// notify wakes an idle worker of the queue without waiting for it
+func (q *Queue) notify(queue string) {
+	q.mu.RLock()
+	ch := q.wake[queue]
+	q.mu.RUnlock()
+
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the workers of every registered queue. They stop when ctx
+// is cancelled; Wait blocks until they have.
+func (q *Queue) Start(ctx context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		return
+	}
+	q.started = true
+	q.ctx = ctx
+
+	for queue, wake := range q.wake {
+		n := q.DefaultConcurrency
+		if c, ok := q.concurrency[queue]; ok {
+			n = c
+		}
+		for i := 0; i < n; i++ {
+			q.wg.Add(1)
+			go q.work(ctx, queue, wake)
+		}
+	}
+
+	q.wg.Add(1)
+	go q.reap(ctx)
+}
+
+// Wait blocks until all workers have stopped
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Ready reports whether the workers are running
+func (q *Queue) Ready() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if !q.started {
+		return errors.New("workers are not started")
+	}
+	if q.ctx.Err() != nil {
+		return errors.New("workers are stopping")
+	}
+	return nil
+}
+
+func (q *Queue) work(ctx context.Context, queue string, wake chan struct{}) {
+	defer q.wg.Done()
+
+	for {
+		// A draining worker finishes the job it holds but claims no more
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := q.repo.Claim(queue)
+		if err == nil {
+			q.run(ctx, job)
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to claim job from queue %s: %v", queue, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	q.mu.RLock()
+	reg, ok := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	err := errors.New("no handler registered")
+	if ok {
+		// Let the job finish even if shutdown starts meanwhile
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.JobTimeout)
+		runCtx = context.WithValue(runCtx, attemptKey{}, job.Attempts)
+		err = safeRun(runCtx, reg.handler, job.Payload)
+		cancel()
+	}
+
+	if err == nil {
+		if err := q.repo.Complete(job.ID); err != nil {
+			log.Printf("Failed to complete job %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %d (%s) failed for good after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		if err := q.repo.MarkDead(job.ID, err.Error()); err != nil {
+			log.Printf("Failed to mark job %d dead: %v", job.ID, err)
+		}
+		return
+	}
+
+	if err := q.repo.Reschedule(job.ID, time.Now().Add(Backoff(job.Attempts)), err.Error()); err != nil {
+		log.Printf("Failed to reschedule job %d: %v", job.ID, err)
+	}
+}
+
+// safeRun turns a panicking handler into a failed job
+func safeRun(ctx context.Context, h Handler, payload json.RawMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, payload)
+}
+
+// Backoff is the delay before the next try of a job that failed its
+// attempt-th run. It doubles per attempt, up to an hour, with some jitter so
+// failures of many jobs spread out.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := maxBackoff
+	if attempt < 10 {
+		d = minBackoff << (attempt - 1)
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// reap returns jobs abandoned by crashed workers to their queue
+func (q *Queue) reap(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		// Allow a grace period past the timeout for the worker to record the result
+		n, err := q.repo.RequeueStale(time.Now().Add(-q.JobTimeout - time.Minute))
+		if err != nil {
+			log.Printf("Failed to requeue stale jobs: %v", err)
+		} else if n > 0 {
+			log.Printf("Requeued %d stale jobs", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/money"
+)
+
+// DefaultLocale is used when a template has no translation for the
+// requested locale
+const DefaultLocale = "en"
+
+// Template names
+const (
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplateOrderShipped      = "order_shipped"
+	TemplateOrderCancelled    = "order_cancelled"
+	TemplateRefundIssued      = "refund_issued"
+	TemplateWelcome           = "welcome"
+	TemplatePasswordReset     = "password_reset"
+	TemplatePriceDrop         = "price_drop"
+	TemplateBackInStock       = "back_in_stock"
+)
+
+// TemplateNames lists every email template
+var TemplateNames = []string{
+	TemplateOrderConfirmation,
+	TemplateOrderShipped,
+	TemplateOrderCancelled,
+	TemplateRefundIssued,
+	TemplateWelcome,
+	TemplatePasswordReset,
+	TemplatePriceDrop,
+	TemplateBackInStock,
+}
+
+//go:embed templates
+var embedded embed.FS
+
+var funcs = map[string]interface{}{
+	// money formats an amount followed by its currency code
+	"money": func(m money.Money) string {
+		return m.String()
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02")
+	},
+}
+
+// Templates renders emails from templates stored as
+// <locale>/<name>.txt and <locale>/<name>.html. The text template defines
+// the subject in a "subject" block; the HTML template defines a "content"
+// block rendered inside <locale>/layout.html. Files in Dir, when set,
+// override the built-in ones.
+type Templates struct {
+	Dir string
+}
+
+func NewTemplates(dir string) *Templates {
+	return &Templates{Dir: dir}
+}
+
+// localePattern is a language code with an optional region, e.g. en or de-at
+var localePattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]{2})?$`)
+
+// ValidLocale reports whether locale is a language code with an optional
+// region, e.g. en or de-at, and so safe to use in template paths
+func ValidLocale(locale string) bool {
+	return localePattern.MatchString(locale)
+}
+
+// candidates lists the locales to try for locale, most specific first
+func candidates(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	var list []string
+	if ValidLocale(locale) {
+		list = append(list, locale)
+		if i := strings.Index(locale, "-"); i > 0 {
+			list = append(list, locale[:i])
+		}
+	}
+	return append(list, DefaultLocale)
+}
+
+// read returns the first of the locale's fallbacks that has the file
+func (t *Templates) read(locale, file string) ([]byte, error) {
+	for _, l := range candidates(locale) {
+		if t.Dir != "" {
+			data, err := os.ReadFile(filepath.Join(t.Dir, l, file))
+			if err == nil {
+				return data, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		data, err := embedded.ReadFile(path.Join("templates", l, file))
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("template %s not found", file)
+}
+
+func validName(name string) bool {
+	for _, n := range TemplateNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Render builds the message for a template in the given locale. The
+// recipient is left for the caller to fill in.
+func (t *Templates) Render(name, locale string, data interface{}) (*Message, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("unknown template %q", name)
+	}
+
+	textSource, err := t.read(locale, name+".txt")
+	if err != nil {
+		return nil, err
+	}
+	textTmpl, err := texttemplate.New(name).Funcs(funcs).Parse(string(textSource))
+	if err != nil {
+		return nil, err
+	}
+	if textTmpl.Lookup("subject") == nil {
+		return nil, fmt.Errorf("template %s.txt does not define a subject", name)
+	}
+
+	var subject, text bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return nil, err
+	}
+
+	layoutSource, err := t.read(locale, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+	htmlSource, err := t.read(locale, name+".html")
+	if err != nil {
+		return nil, err
+	}
+	htmlTmpl, err := htmltemplate.New("layout").Funcs(funcs).Parse(string(layoutSource))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := htmlTmpl.Parse(string(htmlSource)); err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // LoggerMiddleware logs request details
@@ -19,13 +21,18 @@ func LoggerMiddleware() gin.HandlerFunc {
 		// Calculate duration
 		duration := time.Since(start)
 
-		// Log request details
-		fmt.Printf("[%s] %s %s %d %s\n",
-			time.Now().Format("2006-01-02 15:04:05"),
+		// Log request details as labeled fields, with the trace ID when the
+		// request is traced
+		line := fmt.Sprintf("%s %s status=%d duration=%s request_id=%s",
 			c.Request.Method,
 			c.Request.URL.Path,
 			c.Writer.Status(),
 			duration,
+			c.GetString(RequestIDKey),
 		)
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			line += " trace_id=" + sc.TraceID().String()
+		}
+		log.Print(line)
 	}
 }
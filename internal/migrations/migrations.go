@@ -0,0 +1,392 @@
+// Package migrations applies the versioned SQL migrations embedded in the
+// binary and records the applied versions in the schema_migrations table.
+//
+// Migrations live in sql/ as <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Each runs in a transaction together with its
+// record, so a failed migration leaves nothing behind. Statements that
+// cannot run in a transaction, such as CREATE INDEX CONCURRENTLY, go in a
+// migration whose first line is NoTransaction; such a migration is marked
+// dirty while it runs and stays dirty when it fails, until it is repaired by
+// hand and forced.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NoTransaction is the first line of migrations that run outside a
+// transaction
+const NoTransaction = "-- migrate:no-transaction"
+
+// lockKey is the Postgres advisory lock held while migrating so instances
+// starting together do not apply the same migration twice
+const lockKey = 7_461_223_908
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var (
+	filePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+var (
+	ErrPending = errors.New("database schema has pending migrations")
+	ErrDirty   = errors.New("database schema is dirty")
+)
+
+// Migration is one version of the schema
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+func (m Migration) transactional(sql string) bool {
+	return !strings.HasPrefix(strings.TrimSpace(sql), NoTransaction)
+}
+
+// record is an applied migration
+type record struct {
+	Version   int64 `gorm:"primaryKey"`
+	Name      string
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+func (record) TableName() string {
+	return "schema_migrations"
+}
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	dirty boolean NOT NULL DEFAULT false,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	Dirty     bool
+}
+
+// Load reads the embedded migrations, oldest first
+func Load() ([]Migration, error) {
+	return load(embedded, "sql")
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %s has no up script", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations
+type Migrator struct {
+	// Settings are set as transaction-local Postgres settings, such as
+	// app.base_currency, while a migration runs in a transaction; scripts
+	// read them with current_setting
+	Settings map[string]string
+
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New creates a migrator for the embedded migrations
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[int64]record, error) {
+	if err := db.Exec(createTable).Error; err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Status lists every migration with the time it was applied, oldest first
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if r, ok := applied[migration.Version]; ok {
+			appliedAt := r.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+			statuses[i].Dirty = r.Dirty
+		}
+	}
+	return statuses, nil
+}
+
+// Check returns ErrDirty when a migration failed halfway and ErrPending when
+// migrations have not been applied yet
+func (m *Migrator) Check() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, s := range statuses {
+		if s.Dirty {
+			return fmt.Errorf("%w: migration %s did not finish", ErrDirty, s.Migration)
+		}
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration.String())
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s", ErrPending, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// locked runs fn on one connection holding the migration lock
+func (m *Migrator) locked(fn func(db *gorm.DB) error) error {
+	return m.db.Connection(func(db *gorm.DB) error {
+		if err := db.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		defer db.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		return fn(db)
+	})
+}
+
+// Up applies up to n pending migrations, all of them when n is 0, and
+// returns the ones it applied
+func (m *Migrator) Up(n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for _, r := range applied {
+			if r.Dirty {
+				return fmt.Errorf("%w: migration %04d_%s did not finish", ErrDirty, r.Version, r.Name)
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(db, migration); err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) apply(db *gorm.DB, migration Migration) error {
+	r := record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+	if migration.transactional(migration.Up) {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := m.configure(tx); err != nil {
+				return err
+			}
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&r).Error
+		})
+	}
+
+	r.Dirty = true
+	if err := db.Create(&r).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(migration.Up).Error; err != nil {
+		return err
+	}
+	return db.Model(&r).Update("dirty", false).Error
+}
+
+// configure applies the settings to the transaction
+func (m *Migrator) configure(tx *gorm.DB) error {
+	for name, value := range m.Settings {
+		if err := tx.Exec("SELECT set_config(?, ?, true)", name, value).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down rolls back the last n applied migrations, newest first, and returns
+// the ones it rolled back
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			migration := m.migrations[i]
+			r, ok := applied[migration.Version]
+			if !ok {
+				continue
+			}
+			if r.Dirty {
+				return fmt.Errorf("%w: migration %s did not finish", ErrDirty, migration)
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("migration %s cannot be rolled back", migration)
+			}
+			if err := m.revert(db, migration); err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) revert(db *gorm.DB, migration Migration) error {
+	if migration.transactional(migration.Down) {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := m.configure(tx); err != nil {
+				return err
+			}
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&record{Version: migration.Version}).Error
+		})
+	}
+
+	if err := db.Model(&record{Version: migration.Version}).Update("dirty", true).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(migration.Down).Error; err != nil {
+		return err
+	}
+	return db.Delete(&record{Version: migration.Version}).Error
+}
+
+// Force clears the dirty flag of a migration after it was finished or
+// undone by hand. With applied false the migration is recorded as not
+// applied.
+func (m *Migrator) Force(version int64, applied bool) error {
+	return m.locked(func(db *gorm.DB) error {
+		records, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		if _, ok := records[version]; !ok {
+			return fmt.Errorf("migration %d has no record", version)
+		}
+		if !applied {
+			return db.Delete(&record{Version: version}).Error
+		}
+		return db.Model(&record{Version: version}).Update("dirty", false).Error
+	})
+}
+
+// Create writes empty up and down scripts for a new migration into dir,
+// numbered after the last migration there, and returns their paths
+func Create(dir, name string) (up string, down string, err error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !namePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q, use letters, digits and underscores", name)
+	}
+
+	existing, err := load(os.DirFS(dir), ".")
+	if err != nil {
+		return "", "", err
+	}
+	version := int64(1)
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down = base+".up.sql", base+".down.sql"
+	for _, file := range []string{up, down} {
+		if err := os.WriteFile(file, []byte("-- "+filepath.Base(file)+"\n"), 0o644); err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
@@ -0,0 +1,31 @@
+DROP FUNCTION IF EXISTS audit_logs_append_only() CASCADE;
+
+DROP TABLE IF EXISTS product_prices;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS price_changes;
+DROP TABLE IF EXISTS price_schedules;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS impersonation_sessions;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS invoice_sequences;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS product_imports;
+DROP TABLE IF EXISTS search_queries;
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS wishlists;
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS review_images;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS carts;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS images;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,538 @@
+-- Schema as created by AutoMigrate before versioned migrations. Every
+-- statement is idempotent so databases created by AutoMigrate adopt it;
+-- columns added after the first release are added to tables it created.
+-- Their amounts are converted to minor units by the next migration.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    email text NOT NULL,
+    password text NOT NULL,
+    name text NOT NULL,
+    role text DEFAULT 'user',
+    address text,
+    phone text,
+    locale varchar(10),
+    status varchar(20) DEFAULT 'active',
+    status_reason text,
+    password_reset_required boolean DEFAULT false,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS locale varchar(10),
+    ADD COLUMN IF NOT EXISTS status varchar(20) DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_reason text,
+    ADD COLUMN IF NOT EXISTS password_reset_required boolean DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    description text,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS products (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    description text,
+    price bigint NOT NULL,
+    currency varchar(3) NOT NULL DEFAULT '',
+    stock bigint NOT NULL,
+    category_id bigint NOT NULL,
+    sku text,
+    is_active boolean DEFAULT true,
+    compare_at_price bigint,
+    rating_average decimal DEFAULT 0,
+    rating_count bigint DEFAULT 0,
+    rating_one_star bigint DEFAULT 0,
+    rating_two_star bigint DEFAULT 0,
+    rating_three_star bigint DEFAULT 0,
+    rating_four_star bigint DEFAULT 0,
+    rating_five_star bigint DEFAULT 0,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_categories_products FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS compare_at_price bigint,
+    ADD COLUMN IF NOT EXISTS rating_average decimal DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_count bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_one_star bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_two_star bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_three_star bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_four_star bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_five_star bigint DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_products_rating_average ON products (rating_average);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+
+CREATE TABLE IF NOT EXISTS images (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    url text NOT NULL,
+    product_id bigint NOT NULL,
+    is_primary boolean DEFAULT false,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_products_images FOREIGN KEY (product_id) REFERENCES products(id)
+);
+CREATE INDEX IF NOT EXISTS idx_images_deleted_at ON images (deleted_at);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint,
+    type varchar(20) NOT NULL,
+    street text NOT NULL,
+    city text NOT NULL,
+    state text NOT NULL,
+    country text NOT NULL,
+    zip_code text NOT NULL,
+    is_default boolean DEFAULT false,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_addresses_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON addresses (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint,
+    guest_email text,
+    status varchar(20) DEFAULT 'pending',
+    total_amount bigint NOT NULL,
+    shipping_cost bigint,
+    tax_amount bigint,
+    discount bigint,
+    currency varchar(3) NOT NULL DEFAULT '',
+    exchange_rate decimal NOT NULL DEFAULT 1,
+    shipping_address_id bigint NOT NULL,
+    billing_address_id bigint,
+    payment_id text,
+    tracking_number text,
+    notes text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_orders_shipping_address FOREIGN KEY (shipping_address_id) REFERENCES addresses(id),
+    CONSTRAINT fk_orders_billing_address FOREIGN KEY (billing_address_id) REFERENCES addresses(id)
+);
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS guest_email text,
+    ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS exchange_rate decimal NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS billing_address_id bigint REFERENCES addresses(id);
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    order_id bigint NOT NULL,
+    product_id bigint NOT NULL,
+    quantity bigint NOT NULL,
+    price bigint NOT NULL,
+    subtotal bigint NOT NULL,
+    currency varchar(3) NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    CONSTRAINT fk_order_items_product FOREIGN KEY (product_id) REFERENCES products(id),
+    CONSTRAINT fk_orders_items FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_order_items_deleted_at ON order_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS carts (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint,
+    token varchar(64),
+    total bigint DEFAULT 0,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_carts_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS token varchar(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_user_id ON carts (user_id);
+CREATE INDEX IF NOT EXISTS idx_carts_deleted_at ON carts (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_token ON carts (token) WHERE token <> '';
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    cart_id bigint NOT NULL,
+    product_id bigint NOT NULL,
+    quantity bigint NOT NULL,
+    price bigint NOT NULL,
+    subtotal bigint NOT NULL,
+    previous_price bigint,
+    currency varchar(3) NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    CONSTRAINT fk_cart_items_product FOREIGN KEY (product_id) REFERENCES products(id),
+    CONSTRAINT fk_carts_items FOREIGN KEY (cart_id) REFERENCES carts(id)
+);
+ALTER TABLE cart_items
+    ADD COLUMN IF NOT EXISTS previous_price bigint,
+    ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_cart_items_deleted_at ON cart_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    product_id bigint NOT NULL,
+    rating bigint NOT NULL,
+    title varchar(100),
+    comment text,
+    is_verified boolean DEFAULT false,
+    status varchar(20) DEFAULT 'pending',
+    moderation_note text,
+    moderated_by bigint,
+    moderated_at timestamptz,
+    helpful_count bigint DEFAULT 0,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_reviews_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_reviews_product FOREIGN KEY (product_id) REFERENCES products(id),
+    CONSTRAINT chk_reviews_rating CHECK (rating >= 1 AND rating <= 5)
+);
+-- Reviews written before moderation were already public
+ALTER TABLE reviews
+    ADD COLUMN IF NOT EXISTS status varchar(20) DEFAULT 'approved',
+    ADD COLUMN IF NOT EXISTS moderation_note text,
+    ADD COLUMN IF NOT EXISTS moderated_by bigint,
+    ADD COLUMN IF NOT EXISTS moderated_at timestamptz,
+    ADD COLUMN IF NOT EXISTS helpful_count bigint DEFAULT 0;
+ALTER TABLE reviews ALTER COLUMN status SET DEFAULT 'pending';
+CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews (status);
+CREATE INDEX IF NOT EXISTS idx_reviews_deleted_at ON reviews (deleted_at);
+
+CREATE TABLE IF NOT EXISTS review_images (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    review_id bigint NOT NULL,
+    url text NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_reviews_images FOREIGN KEY (review_id) REFERENCES reviews(id)
+);
+CREATE INDEX IF NOT EXISTS idx_review_images_review_id ON review_images (review_id);
+CREATE INDEX IF NOT EXISTS idx_review_images_deleted_at ON review_images (deleted_at);
+
+CREATE TABLE IF NOT EXISTS review_votes (
+    id bigserial,
+    created_at timestamptz,
+    review_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_votes_review_user ON review_votes (review_id,user_id);
+
+CREATE TABLE IF NOT EXISTS wishlists (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    name varchar(100) NOT NULL,
+    is_public boolean DEFAULT false,
+    share_token varchar(64),
+    PRIMARY KEY (id),
+    CONSTRAINT fk_wishlists_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlists_share_token ON wishlists (share_token) WHERE share_token <> '';
+CREATE INDEX IF NOT EXISTS idx_wishlists_user_id ON wishlists (user_id);
+CREATE INDEX IF NOT EXISTS idx_wishlists_deleted_at ON wishlists (deleted_at);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    wishlist_id bigint NOT NULL,
+    product_id bigint NOT NULL,
+    price_at_added bigint,
+    currency varchar(3) NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    CONSTRAINT fk_wishlist_items_product FOREIGN KEY (product_id) REFERENCES products(id),
+    CONSTRAINT fk_wishlists_items FOREIGN KEY (wishlist_id) REFERENCES wishlists(id)
+);
+ALTER TABLE wishlist_items ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_product_id ON wishlist_items (product_id);
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_wishlist_id ON wishlist_items (wishlist_id);
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_deleted_at ON wishlist_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS search_queries (
+    id bigserial,
+    created_at timestamptz,
+    query varchar(200) NOT NULL,
+    result_count bigint NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_search_queries_query ON search_queries (query);
+CREATE INDEX IF NOT EXISTS idx_search_queries_created_at ON search_queries (created_at);
+
+CREATE TABLE IF NOT EXISTS product_imports (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    format varchar(10) NOT NULL,
+    file_name text,
+    file_path text,
+    dry_run boolean,
+    status varchar(20) DEFAULT 'pending',
+    total_rows bigint,
+    created bigint,
+    updated bigint,
+    failed bigint,
+    categories_created bigint,
+    errors jsonb,
+    message text,
+    started_at timestamptz,
+    finished_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_product_imports_deleted_at ON product_imports (deleted_at);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    queue varchar(50) NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    run_at timestamptz NOT NULL,
+    type varchar(100) NOT NULL,
+    payload jsonb,
+    attempts bigint DEFAULT 0,
+    max_attempts bigint NOT NULL,
+    last_error text,
+    locked_at timestamptz,
+    completed_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs (type);
+CREATE INDEX IF NOT EXISTS idx_jobs_claim ON jobs (queue,status,run_at);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id bigserial,
+    created_at timestamptz,
+    type varchar(100) NOT NULL,
+    payload jsonb,
+    dispatched_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched_at ON outbox_events (dispatched_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_type ON outbox_events (type);
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types jsonb,
+    is_active boolean DEFAULT true,
+    failure_count bigint DEFAULT 0,
+    disabled_at timestamptz,
+    disabled_reason text,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_deleted_at ON webhook_endpoints (deleted_at);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial,
+    created_at timestamptz,
+    endpoint_id bigint NOT NULL,
+    event_id bigint,
+    event_type varchar(100),
+    body jsonb,
+    attempt bigint,
+    success boolean,
+    status_code bigint,
+    response_body text,
+    error text,
+    duration_ms bigint,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries (created_at);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id bigserial,
+    created_at timestamptz,
+    order_id bigint NOT NULL,
+    year bigint NOT NULL,
+    sequence bigint NOT NULL,
+    number varchar(30) NOT NULL,
+    issued_at timestamptz NOT NULL,
+    total bigint NOT NULL,
+    currency varchar(3) NOT NULL DEFAULT '',
+    file_path text NOT NULL,
+    PRIMARY KEY (id)
+);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_number ON invoices (number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_year_sequence ON invoices (year,sequence);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_order_id ON invoices (order_id);
+
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    year bigint,
+    last_number bigint NOT NULL,
+    PRIMARY KEY (year)
+);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id bigserial,
+    created_at timestamptz,
+    user_id bigint NOT NULL,
+    token_hash char(64) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id bigserial,
+    created_at timestamptz,
+    impersonator_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    reason text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    ended_at timestamptz,
+    ended_by bigint,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_impersonation_sessions_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_impersonation_sessions_impersonator FOREIGN KEY (impersonator_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_user_id ON impersonation_sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_impersonator_id ON impersonation_sessions (impersonator_id);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id bigserial,
+    created_at timestamptz,
+    actor_id bigint,
+    actor_role varchar(20),
+    impersonator_id bigint,
+    action varchar(100) NOT NULL,
+    entity_type varchar(50),
+    entity_id varchar(50),
+    changes jsonb,
+    metadata jsonb,
+    ip varchar(45),
+    request_id varchar(64),
+    method varchar(10),
+    path text,
+    status bigint,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request_id ON audit_logs (request_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs (entity_type,entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS price_schedules (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    product_id bigint NOT NULL,
+    kind varchar(20) NOT NULL,
+    price bigint NOT NULL,
+    currency varchar(3) NOT NULL DEFAULT '',
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz,
+    created_by bigint,
+    started_at timestamptz,
+    ended_at timestamptz,
+    cancelled_at timestamptz,
+    PRIMARY KEY (id)
+);
+ALTER TABLE price_schedules ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_price_schedules_product_id ON price_schedules (product_id);
+
+CREATE TABLE IF NOT EXISTS price_changes (
+    id bigserial,
+    product_id bigint NOT NULL,
+    effective_at timestamptz NOT NULL,
+    price bigint NOT NULL,
+    compare_at_price bigint,
+    currency varchar(3) NOT NULL DEFAULT '',
+    reason varchar(20) NOT NULL,
+    schedule_id bigint,
+    PRIMARY KEY (id)
+);
+ALTER TABLE price_changes ADD COLUMN IF NOT EXISTS currency varchar(3) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_price_changes_product ON price_changes (product_id,effective_at);
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id bigserial,
+    updated_at timestamptz,
+    currency varchar(3) NOT NULL,
+    rate decimal NOT NULL,
+    source varchar(20) NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exchange_rates_currency ON exchange_rates (currency);
+
+CREATE TABLE IF NOT EXISTS product_prices (
+    id bigserial,
+    created_at timestamptz,
+    updated_at timestamptz,
+    product_id bigint NOT NULL,
+    currency varchar(3) NOT NULL,
+    price bigint NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_prices_product_currency ON product_prices (product_id,currency);
+
+-- Weighted full-text search vector and the trigram index used for
+-- typo-tolerant matching
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(sku, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(description, '')), 'C')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
+
+-- The audit log is append-only: updates are rejected by the database, and
+-- rows are only deleted when pruned after archiving
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_logs is append-only';
+    END
+    $$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
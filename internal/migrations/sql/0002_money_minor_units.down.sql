@@ -0,0 +1,2 @@
+-- Nothing to undo: the schema is the one the first migration creates, and
+-- amounts converted to minor units stay converted.
@@ -0,0 +1,67 @@
+-- Converts databases created by AutoMigrate before amounts were integer
+-- minor units, sets the currency of records stored before they recorded
+-- one, and lets carts, orders and addresses belong to guests. Databases
+-- created by the first migration are left as they are. Rows without a
+-- currency were in the base currency, read from app.base_currency.
+
+-- Decimal places of a currency, as in internal/currency
+CREATE FUNCTION pg_temp.minor_digits(code text) RETURNS int AS $$
+    SELECT CASE
+        WHEN code IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW',
+                      'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN code IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        ELSE 2
+    END
+$$ LANGUAGE sql IMMUTABLE;
+
+UPDATE products SET currency = current_setting('app.base_currency') WHERE currency = '';
+UPDATE orders SET currency = current_setting('app.base_currency') WHERE currency = '';
+UPDATE price_schedules SET currency = current_setting('app.base_currency') WHERE currency = '';
+UPDATE price_changes SET currency = current_setting('app.base_currency') WHERE currency = '';
+UPDATE wishlist_items SET currency = current_setting('app.base_currency') WHERE currency = '';
+UPDATE cart_items SET currency = current_setting('app.base_currency') WHERE currency = '';
+UPDATE order_items SET currency = orders.currency FROM orders
+    WHERE orders.id = order_items.order_id AND order_items.currency = '';
+UPDATE invoices SET currency = orders.currency FROM orders
+    WHERE orders.id = invoices.order_id AND invoices.currency = '';
+
+-- Amounts in decimal major units become minor units of the row's currency,
+-- rounded half away from zero; carts recorded no currency and were in the
+-- base currency
+DO $$
+DECLARE
+    amount record;
+BEGIN
+    FOR amount IN
+        SELECT c.table_name, c.column_name,
+               CASE WHEN c.table_name = 'carts' THEN quote_literal(current_setting('app.base_currency'))
+                    ELSE 'currency' END AS code
+        FROM information_schema.columns c
+        WHERE c.table_schema = current_schema()
+          AND c.data_type IN ('numeric', 'double precision', 'real')
+          AND (c.table_name, c.column_name) IN (
+              ('products', 'price'), ('products', 'compare_at_price'),
+              ('product_prices', 'price'),
+              ('price_schedules', 'price'),
+              ('price_changes', 'price'), ('price_changes', 'compare_at_price'),
+              ('wishlist_items', 'price_at_added'),
+              ('carts', 'total'),
+              ('cart_items', 'price'), ('cart_items', 'subtotal'), ('cart_items', 'previous_price'),
+              ('orders', 'total_amount'), ('orders', 'shipping_cost'), ('orders', 'tax_amount'), ('orders', 'discount'),
+              ('order_items', 'price'), ('order_items', 'subtotal'),
+              ('invoices', 'total'))
+    LOOP
+        EXECUTE format(
+            'ALTER TABLE %I ALTER COLUMN %I TYPE bigint USING round(%I::numeric * power(10::numeric, pg_temp.minor_digits(%s)))::bigint',
+            amount.table_name, amount.column_name, amount.column_name, amount.code);
+    END LOOP;
+END
+$$;
+
+-- Guest carts have a token instead of a user, and guest orders and their
+-- addresses have no user
+ALTER TABLE carts ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE orders ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE addresses ALTER COLUMN user_id DROP NOT NULL;
+
+DROP FUNCTION pg_temp.minor_digits(text);
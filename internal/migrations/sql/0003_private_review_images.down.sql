@@ -0,0 +1,3 @@
+UPDATE review_images SET url = '/uploads/' || file_path WHERE file_path <> '';
+
+ALTER TABLE review_images DROP COLUMN IF EXISTS file_path;
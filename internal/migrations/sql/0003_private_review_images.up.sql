@@ -0,0 +1,11 @@
+-- Review images move from the public upload directory into the private
+-- data directory and are served through the API only while their review is
+-- approved. Files uploaded before must be moved by hand from
+-- UPLOAD_DIR/reviews to DATA_DIR/reviews.
+
+ALTER TABLE review_images ADD COLUMN IF NOT EXISTS file_path text NOT NULL DEFAULT '';
+
+UPDATE review_images
+SET file_path = substring(url FROM '^/uploads/(.*)$'),
+    url = '/api/v1/reviews/' || review_id || '/images/' || substring(url FROM '[^/]*$')
+WHERE url LIKE '/uploads/reviews/%';
@@ -11,8 +11,8 @@ type Address struct {
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	UserID    uint           `gorm:"not null" json:"user_id"`
-	User      User           `json:"user"`
+	UserID    *uint          `json:"user_id"` // Nil for addresses entered at guest checkout
+	User      *User          `json:"user,omitempty"`
 	Type      string         `gorm:"type:varchar(20);not null" json:"type"` // shipping or billing
 	Street    string         `gorm:"not null" json:"street"`
 	City      string         `gorm:"not null" json:"city"`
@@ -21,3 +21,21 @@ type Address struct {
 	ZipCode   string         `gorm:"not null" json:"zip_code"`
 	IsDefault bool           `gorm:"default:false" json:"is_default"`
 }
+
+// BelongsTo reports whether the address is owned by the given user
+func (a *Address) BelongsTo(userID uint) bool {
+	return a.UserID != nil && *a.UserID == userID
+}
+
+// Copy returns the address as a new row without an owner, for an order to
+// keep as it was at checkout whatever later happens to the address book
+func (a *Address) Copy() *Address {
+	return &Address{
+		Type:    a.Type,
+		Street:  a.Street,
+		City:    a.City,
+		State:   a.State,
+		Country: a.Country,
+		ZipCode: a.ZipCode,
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// AuditChange is the value of one field before and after an action
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditLog records one administrative or security-sensitive action. Entries
+// are never updated; old ones are archived and pruned.
+type AuditLog struct {
+	ID             uint                   `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time              `gorm:"index" json:"created_at"`
+	ActorID        *uint                  `gorm:"index" json:"actor_id"` // Nil for anonymous requests such as failed logins
+	ActorRole      string                 `gorm:"type:varchar(20)" json:"actor_role,omitempty"`
+	ImpersonatorID *uint                  `json:"impersonator_id,omitempty"` // Staff member acting as the actor
+	Action         string                 `gorm:"type:varchar(100);not null;index" json:"action"`
+	EntityType     string                 `gorm:"type:varchar(50);index:idx_audit_logs_entity" json:"entity_type,omitempty"`
+	EntityID       string                 `gorm:"type:varchar(50);index:idx_audit_logs_entity" json:"entity_id,omitempty"`
+	Changes        map[string]AuditChange `gorm:"type:jsonb;serializer:json" json:"changes,omitempty"`
+	Metadata       map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"metadata,omitempty"`
+	IP             string                 `gorm:"type:varchar(45)" json:"ip"`
+	RequestID      string                 `gorm:"type:varchar(64);index" json:"request_id"`
+	Method         string                 `gorm:"type:varchar(10)" json:"method"`
+	Path           string                 `json:"path"`
+	Status         int                    `json:"status,omitempty"` // Response status of requests logged without an explicit action
+}
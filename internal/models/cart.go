@@ -3,29 +3,89 @@ package models
 import (
 	"time"
 
+	"github.com/sajal/go-ecommerce/internal/money"
 	"gorm.io/gorm"
 )
 
+// Codes for problems found when a cart is repriced against the catalog
+const (
+	CartWarningPriceChanged = "price_changed"
+	CartWarningUnavailable  = "product_unavailable"
+	CartWarningRemoved      = "product_removed"
+	CartWarningExceedsStock = "quantity_exceeds_stock"
+)
+
 type Cart struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	UserID    uint           `gorm:"uniqueIndex;not null" json:"user_id"`
-	User      User           `json:"user"`
+	UserID    *uint          `gorm:"uniqueIndex" json:"user_id"`
+	User      *User          `json:"user,omitempty"`
+	Token     string         `gorm:"size:64;uniqueIndex:idx_carts_token,where:token <> ''" json:"token,omitempty"` // Opaque key for guest carts
 	Items     []CartItem     `json:"items"`
-	Total     float64        `gorm:"default:0" json:"total"`
+	Total     money.Money    `gorm:"default:0" json:"total"` // Recomputed in the currency of its products whenever the cart is loaded
+
+	// Set when any line carries a warning the customer has not acknowledged
+	RequiresAcknowledgement bool `gorm:"-" json:"requires_acknowledgement"`
+
+	// Total in the currency the customer chose, set for display only
+	DisplayTotal *money.Money `gorm:"-" json:"display_total,omitempty"`
 }
 
 type CartItem struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	CartID    uint           `gorm:"not null" json:"cart_id"`
-	ProductID uint           `gorm:"not null" json:"product_id"`
-	Product   Product        `json:"product"`
-	Quantity  int            `gorm:"not null" json:"quantity"`
-	Price     float64        `gorm:"not null" json:"price"`
-	Subtotal  float64        `gorm:"not null" json:"subtotal"`
+	ID            uint              `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt    `gorm:"index" json:"-"`
+	CartID        uint              `gorm:"not null" json:"cart_id"`
+	ProductID     uint              `gorm:"not null" json:"product_id"`
+	Product       Product           `json:"product"`
+	Quantity      int               `gorm:"not null" json:"quantity"`
+	Price         money.Money       `gorm:"not null" json:"price"`
+	Subtotal      money.Money       `gorm:"not null" json:"subtotal"`
+	PreviousPrice *money.Money      `json:"previous_price,omitempty"` // Price before an unacknowledged change
+	Currency      string            `gorm:"type:varchar(3);not null;default:''" json:"-"`
+	Warnings      []CartItemWarning `gorm:"-" json:"warnings,omitempty"`
+
+	// Prices in the currency the customer chose, set for display only
+	DisplayPrice    *money.Money `gorm:"-" json:"display_price,omitempty"`
+	DisplaySubtotal *money.Money `gorm:"-" json:"display_subtotal,omitempty"`
+}
+
+func (i *CartItem) BeforeSave(tx *gorm.DB) error {
+	i.Currency = currencyOf(i.Currency, &i.Price, &i.Subtotal, i.PreviousPrice)
+	return nil
+}
+
+func (i *CartItem) AfterFind(tx *gorm.DB) error {
+	setCurrency(i.Currency, &i.Price, &i.Subtotal, i.PreviousPrice)
+	return nil
+}
+
+// SetPrice moves the line to a new catalog price. The price the customer saw
+// is kept in PreviousPrice until acknowledged, and dropped again when the
+// price goes back to it. It reports whether the line changed.
+func (i *CartItem) SetPrice(price money.Money) bool {
+	changed := false
+	if !i.Price.Equal(price) {
+		if i.PreviousPrice == nil {
+			previous := i.Price
+			i.PreviousPrice = &previous
+		}
+		i.Price = price
+		changed = true
+	}
+	if i.PreviousPrice != nil && i.PreviousPrice.Equal(i.Price) {
+		// Price went back to what the customer saw
+		i.PreviousPrice = nil
+		changed = true
+	}
+	return changed
+}
+
+// CartItemWarning explains why a cart line needs the customer's attention
+type CartItemWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
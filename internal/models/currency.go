@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/money"
+	"gorm.io/gorm"
+)
+
+// ExchangeRate is how many units of a currency one unit of the store's base
+// currency buys
+type ExchangeRate struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex" json:"currency"`
+	Rate      float64   `gorm:"not null" json:"rate"`
+	Source    string    `gorm:"type:varchar(20);not null" json:"source"`
+}
+
+// ProductPrice replaces the converted price of a product in one currency
+type ProductPrice struct {
+	ID        uint        `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	ProductID uint        `gorm:"not null;uniqueIndex:idx_product_prices_product_currency,priority:1" json:"product_id"`
+	Currency  string      `gorm:"type:varchar(3);not null;uniqueIndex:idx_product_prices_product_currency,priority:2" json:"currency"`
+	Price     money.Money `gorm:"not null" json:"price"`
+}
+
+func (p *ProductPrice) BeforeSave(tx *gorm.DB) error {
+	p.Currency = currencyOf(p.Currency, &p.Price)
+	return nil
+}
+
+func (p *ProductPrice) AfterFind(tx *gorm.DB) error {
+	setCurrency(p.Currency, &p.Price)
+	return nil
+}
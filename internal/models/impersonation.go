@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ImpersonationSession records a staff member acting as a customer. The
+// session's token stops working once it expires or is ended.
+type ImpersonationSession struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ImpersonatorID uint       `gorm:"not null;index" json:"impersonator_id"`
+	Impersonator   *User      `json:"impersonator,omitempty"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	User           *User      `json:"user,omitempty"`
+	Reason         string     `gorm:"not null" json:"reason"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at"`
+	EndedBy        *uint      `json:"ended_by"`
+}
+
+// Active reports whether the session can still be used at the given time
+func (s *ImpersonationSession) Active(now time.Time) bool {
+	return s.EndedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/money"
+	"gorm.io/gorm"
+)
+
+// Invoice is the immutable record of an issued invoice. Numbers run
+// without gaps within each calendar year.
+type Invoice struct {
+	ID        uint        `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	OrderID   uint        `gorm:"not null;uniqueIndex" json:"order_id"`
+	Year      int         `gorm:"not null;uniqueIndex:idx_invoices_year_sequence" json:"year"`
+	Sequence  int         `gorm:"not null;uniqueIndex:idx_invoices_year_sequence" json:"sequence"`
+	Number    string      `gorm:"type:varchar(30);not null;uniqueIndex" json:"number"`
+	IssuedAt  time.Time   `gorm:"not null" json:"issued_at"`
+	Total     money.Money `gorm:"not null" json:"total"`
+	Currency  string      `gorm:"type:varchar(3);not null;default:''" json:"-"`
+	FilePath  string      `gorm:"not null" json:"-"` // Location of the PDF in storage
+}
+
+func (i *Invoice) BeforeSave(tx *gorm.DB) error {
+	i.Currency = currencyOf(i.Currency, &i.Total)
+	return nil
+}
+
+func (i *Invoice) AfterFind(tx *gorm.DB) error {
+	setCurrency(i.Currency, &i.Total)
+	return nil
+}
+
+// InvoiceSequence holds the last invoice number issued in a year
+type InvoiceSequence struct {
+	Year       int `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int `gorm:"not null"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusDead      JobStatus = "dead" // Out of attempts; only retried by an admin
+)
+
+// Job is a unit of background work waiting in, or taken from, a queue
+type Job struct {
+	ID          uint            `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Queue       string          `gorm:"type:varchar(50);not null;index:idx_jobs_claim,priority:1" json:"queue"`
+	Status      JobStatus       `gorm:"type:varchar(20);not null;default:'pending';index:idx_jobs_claim,priority:2" json:"status"`
+	RunAt       time.Time       `gorm:"not null;index:idx_jobs_claim,priority:3" json:"run_at"` // Not picked up before this time
+	Type        string          `gorm:"type:varchar(100);not null;index" json:"type"`
+	Payload     json.RawMessage `gorm:"type:jsonb;serializer:json" json:"payload" swaggertype:"object"`
+	Attempts    int             `gorm:"default:0" json:"attempts"`
+	MaxAttempts int             `gorm:"not null" json:"max_attempts"`
+	LastError   string          `gorm:"type:text" json:"last_error,omitempty"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty"` // When a worker claimed the job
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
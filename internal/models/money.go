@@ -0,0 +1,28 @@
+package models
+
+import "github.com/sajal/go-ecommerce/internal/money"
+
+// Amounts are stored as bigint minor units without their currency. Every
+// record with amounts keeps the currency in its own Currency column: a
+// BeforeSave hook takes it from the amounts and an AfterFind hook puts it
+// back on them.
+
+// setCurrency sets code on the given amounts, skipping nil ones
+func setCurrency(code string, amounts ...*money.Money) {
+	for _, m := range amounts {
+		if m != nil {
+			m.Currency = code
+		}
+	}
+}
+
+// currencyOf returns the currency of the first amount that has one, or
+// current when none does
+func currencyOf(current string, amounts ...*money.Money) string {
+	for _, m := range amounts {
+		if m != nil && m.Currency != "" {
+			return m.Currency
+		}
+	}
+	return current
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/sajal/go-ecommerce/internal/money"
 	"gorm.io/gorm"
 )
 
@@ -14,23 +15,29 @@ const (
 	OrderStatusShipped    OrderStatus = "shipped"
 	OrderStatusDelivered  OrderStatus = "delivered"
 	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusRefunded   OrderStatus = "refunded"
 )
 
 type Order struct {
 	ID                uint           `gorm:"primarykey" json:"id"`
-	CreatedAt         time.Time      `json:"created_at"`
+	CreatedAt         time.Time      `gorm:"index" json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
 	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
-	UserID            uint           `gorm:"not null" json:"user_id"`
-	User              User           `json:"user"`
+	UserID            *uint          `json:"user_id"`
+	User              *User          `json:"user,omitempty"`
+	GuestEmail        string         `json:"guest_email,omitempty"` // Set for guest checkouts
 	Status            OrderStatus    `gorm:"type:varchar(20);default:'pending'" json:"status"`
-	TotalAmount       float64        `gorm:"not null" json:"total_amount"`
-	ShippingCost      float64        `json:"shipping_cost"`
-	TaxAmount         float64        `json:"tax_amount"`
-	Discount          float64        `json:"discount"`
+	TotalAmount       money.Money    `gorm:"not null" json:"total_amount"`
+	ShippingCost      money.Money    `json:"shipping_cost"`
+	TaxAmount         money.Money    `json:"tax_amount"`
+	Discount          money.Money    `json:"discount"`
+	Currency          string         `gorm:"type:varchar(3);not null;default:''" json:"currency"` // Currency every amount of the order is in
+	ExchangeRate      float64        `gorm:"not null;default:1" json:"exchange_rate"`             // Units of Currency per unit of the base currency at purchase
 	Items             []OrderItem    `json:"items"`
 	ShippingAddressID uint           `gorm:"not null" json:"shipping_address_id"`
 	ShippingAddress   Address        `gorm:"foreignKey:ShippingAddressID" json:"shipping_address"`
+	BillingAddressID  *uint          `json:"billing_address_id"` // Nil when billed to the shipping address
+	BillingAddress    *Address       `gorm:"foreignKey:BillingAddressID" json:"billing_address,omitempty"`
 	PaymentID         string         `json:"payment_id"`
 	TrackingNumber    string         `json:"tracking_number"`
 	Notes             string         `json:"notes"`
@@ -45,6 +52,46 @@ type OrderItem struct {
 	ProductID uint           `gorm:"not null" json:"product_id"`
 	Product   Product        `json:"product"`
 	Quantity  int            `gorm:"not null" json:"quantity"`
-	Price     float64        `gorm:"not null" json:"price"` // Price at time of purchase
-	Subtotal  float64        `gorm:"not null" json:"subtotal"`
+	Price     money.Money    `gorm:"not null" json:"price"` // Price at time of purchase
+	Subtotal  money.Money    `gorm:"not null" json:"subtotal"`
+	Currency  string         `gorm:"type:varchar(3);not null;default:''" json:"-"`
+}
+
+func (o *Order) BeforeSave(tx *gorm.DB) error {
+	o.Currency = currencyOf(o.Currency, &o.TotalAmount, &o.ShippingCost, &o.TaxAmount, &o.Discount)
+	return nil
+}
+
+func (o *Order) AfterFind(tx *gorm.DB) error {
+	setCurrency(o.Currency, &o.TotalAmount, &o.ShippingCost, &o.TaxAmount, &o.Discount)
+	return nil
+}
+
+func (i *OrderItem) BeforeSave(tx *gorm.DB) error {
+	i.Currency = currencyOf(i.Currency, &i.Price, &i.Subtotal)
+	return nil
+}
+
+func (i *OrderItem) AfterFind(tx *gorm.DB) error {
+	setCurrency(i.Currency, &i.Price, &i.Subtotal)
+	return nil
+}
+
+// BelongsTo reports whether the order was placed by the given user
+func (o *Order) BelongsTo(userID uint) bool {
+	return o.UserID != nil && *o.UserID == userID
+}
+
+// IsGuest reports whether the order was placed through guest checkout
+func (o *Order) IsGuest() bool {
+	return o.UserID == nil
+}
+
+// CustomerEmail returns the email of the account or guest that placed the
+// order; the user must be loaded for account orders
+func (o *Order) CustomerEmail() string {
+	if o.User != nil {
+		return o.User.Email
+	}
+	return o.GuestEmail
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxEvent is a domain event stored in the same transaction as the state
+// change it describes, waiting to be dispatched to subscribers
+type OutboxEvent struct {
+	ID           uint            `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Type         string          `gorm:"type:varchar(100);not null;index" json:"type"`
+	Payload      json.RawMessage `gorm:"type:jsonb;serializer:json" json:"payload" swaggertype:"object"`
+	DispatchedAt *time.Time      `gorm:"index" json:"dispatched_at,omitempty"`
+
+	// Data is encoded into Payload when the event is saved, so it may point
+	// at records created earlier in the same transaction
+	Data interface{} `gorm:"-" json:"-"`
+}
+
+// BeforeCreate is a GORM hook that encodes Data into Payload
+func (e *OutboxEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.Data == nil {
+		return nil
+	}
+	payload, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	e.Payload = payload
+	return nil
+}
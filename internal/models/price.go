@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/money"
+	"gorm.io/gorm"
+)
+
+// Kinds of scheduled price changes
+const (
+	// PriceScheduleChange replaces the regular price from StartsAt on
+	PriceScheduleChange = "change"
+	// PriceScheduleSale discounts the price between StartsAt and EndsAt
+	PriceScheduleSale = "sale"
+)
+
+// Reasons recorded in the price history
+const (
+	PriceChangeCreated     = "created"
+	PriceChangeUpdated     = "updated"
+	PriceChangeScheduled   = "scheduled"
+	PriceChangeSaleStarted = "sale_started"
+	PriceChangeSaleEnded   = "sale_ended"
+)
+
+// PriceSchedule is a future price change or sale of a product, applied and
+// reverted by background jobs at its boundaries
+type PriceSchedule struct {
+	ID          uint        `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	ProductID   uint        `gorm:"not null;index" json:"product_id"`
+	Kind        string      `gorm:"type:varchar(20);not null" json:"kind"`
+	Price       money.Money `gorm:"not null" json:"price"`
+	Currency    string      `gorm:"type:varchar(3);not null;default:''" json:"-"`
+	StartsAt    time.Time   `gorm:"not null" json:"starts_at"`
+	EndsAt      *time.Time  `json:"ends_at,omitempty"` // Sales only
+	CreatedBy   *uint       `json:"created_by,omitempty"`
+	StartedAt   *time.Time  `json:"started_at,omitempty"`
+	EndedAt     *time.Time  `json:"ended_at,omitempty"`
+	CancelledAt *time.Time  `json:"cancelled_at,omitempty"`
+}
+
+func (s *PriceSchedule) BeforeSave(tx *gorm.DB) error {
+	s.Currency = currencyOf(s.Currency, &s.Price)
+	return nil
+}
+
+func (s *PriceSchedule) AfterFind(tx *gorm.DB) error {
+	setCurrency(s.Currency, &s.Price)
+	return nil
+}
+
+// Pending reports whether the schedule has yet to start
+func (s *PriceSchedule) Pending() bool {
+	return s.StartedAt == nil && s.CancelledAt == nil
+}
+
+// Running reports whether the schedule is a sale that has started and not
+// yet ended
+func (s *PriceSchedule) Running() bool {
+	return s.Kind == PriceScheduleSale && s.StartedAt != nil && s.EndedAt == nil && s.CancelledAt == nil
+}
+
+// Apply sets the product's prices as of the start of the schedule. A change
+// replaces the regular price and keeps a running sale; a sale moves the
+// regular price to the compare-at price.
+func (s *PriceSchedule) Apply(product *Product) {
+	if s.Kind == PriceScheduleSale {
+		regular := product.RegularPrice()
+		product.CompareAtPrice = &regular
+		product.Price = s.Price
+		return
+	}
+	if product.CompareAtPrice != nil {
+		price := s.Price
+		product.CompareAtPrice = &price
+		return
+	}
+	product.Price = s.Price
+}
+
+// PriceChange is an entry in a product's price history
+type PriceChange struct {
+	ID             uint         `gorm:"primarykey" json:"id"`
+	ProductID      uint         `gorm:"not null;index:idx_price_changes_product,priority:1" json:"product_id"`
+	EffectiveAt    time.Time    `gorm:"not null;index:idx_price_changes_product,priority:2" json:"effective_at"`
+	Price          money.Money  `gorm:"not null" json:"price"`
+	CompareAtPrice *money.Money `json:"compare_at_price,omitempty"`
+	Currency       string       `gorm:"type:varchar(3);not null;default:''" json:"-"`
+	Reason         string       `gorm:"type:varchar(20);not null" json:"reason"`
+	ScheduleID     *uint        `json:"schedule_id,omitempty"`
+}
+
+func (c *PriceChange) BeforeSave(tx *gorm.DB) error {
+	c.Currency = currencyOf(c.Currency, &c.Price, c.CompareAtPrice)
+	return nil
+}
+
+func (c *PriceChange) AfterFind(tx *gorm.DB) error {
+	setCurrency(c.Currency, &c.Price, c.CompareAtPrice)
+	return nil
+}
+
+// NewPriceChange records the current prices of the product
+func NewPriceChange(product *Product, reason string, scheduleID *uint, at time.Time) *PriceChange {
+	return &PriceChange{
+		ProductID:      product.ID,
+		EffectiveAt:    at,
+		Price:          product.Price,
+		CompareAtPrice: product.CompareAtPrice,
+		Reason:         reason,
+		ScheduleID:     scheduleID,
+	}
+}
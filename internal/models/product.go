@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/sajal/go-ecommerce/internal/money"
 	"gorm.io/gorm"
 )
 
@@ -13,13 +14,54 @@ type Product struct {
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 	Name        string         `gorm:"not null" json:"name"`
 	Description string         `json:"description"`
-	Price       float64        `gorm:"not null" json:"price"`
+	Price       money.Money    `gorm:"not null" json:"price"`
+	Currency    string         `gorm:"type:varchar(3);not null;default:''" json:"currency"` // The store's base currency
 	Stock       int            `gorm:"not null" json:"stock"`
 	CategoryID  uint           `gorm:"not null" json:"category_id"`
 	Category    Category       `json:"category"`
 	Images      []Image        `json:"images"`
 	SKU         string         `gorm:"uniqueIndex" json:"sku"`
-	IsActive    bool           `gorm:"default:true" json:"is_active"`
+	IsActive    bool           `json:"is_active"` // Saved as given; a column default would turn false into true on create
+
+	// Regular price while a sale runs, shown struck through; set and
+	// cleared by price schedules only
+	CompareAtPrice *money.Money `json:"compare_at_price,omitempty"`
+
+	// Prices in the currency the customer chose, set for display only
+	DisplayPrice          *money.Money `gorm:"-" json:"display_price,omitempty"`
+	DisplayCompareAtPrice *money.Money `gorm:"-" json:"display_compare_at_price,omitempty"`
+
+	// Aggregates over approved reviews, maintained by ReviewService
+	RatingAverage   float64         `gorm:"default:0;index" json:"rating_average"`
+	RatingCount     int             `gorm:"default:0" json:"rating_count"`
+	RatingHistogram RatingHistogram `gorm:"embedded;embeddedPrefix:rating_" json:"rating_histogram"`
+}
+
+// RegularPrice is the price the product sells at outside of sales
+func (p *Product) RegularPrice() money.Money {
+	if p.CompareAtPrice != nil {
+		return *p.CompareAtPrice
+	}
+	return p.Price
+}
+
+func (p *Product) BeforeSave(tx *gorm.DB) error {
+	p.Currency = currencyOf(p.Currency, &p.Price, p.CompareAtPrice)
+	return nil
+}
+
+func (p *Product) AfterFind(tx *gorm.DB) error {
+	setCurrency(p.Currency, &p.Price, p.CompareAtPrice)
+	return nil
+}
+
+// RatingHistogram counts approved reviews per star rating
+type RatingHistogram struct {
+	OneStar   int `gorm:"default:0" json:"1"`
+	TwoStar   int `gorm:"default:0" json:"2"`
+	ThreeStar int `gorm:"default:0" json:"3"`
+	FourStar  int `gorm:"default:0" json:"4"`
+	FiveStar  int `gorm:"default:0" json:"5"`
 }
 
 type Category struct {
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ImportStatus string
+
+const (
+	ImportStatusPending   ImportStatus = "pending"
+	ImportStatusRunning   ImportStatus = "running"
+	ImportStatusCompleted ImportStatus = "completed"
+	ImportStatusFailed    ImportStatus = "failed"
+)
+
+// ProductImport tracks a bulk product import and its per-row outcome
+type ProductImport struct {
+	ID                uint             `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt   `gorm:"index" json:"-"`
+	UserID            uint             `gorm:"not null" json:"user_id"` // Admin who started the import
+	Format            string           `gorm:"type:varchar(10);not null" json:"format"`
+	FileName          string           `json:"file_name"`
+	FilePath          string           `json:"-"` // Location of the uploaded file in storage
+	DryRun            bool             `json:"dry_run"`
+	Status            ImportStatus     `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	TotalRows         int              `json:"total_rows"`
+	Created           int              `json:"created"`
+	Updated           int              `json:"updated"`
+	Failed            int              `json:"failed"`
+	CategoriesCreated int              `json:"categories_created"`
+	Errors            []ImportRowError `gorm:"type:jsonb;serializer:json" json:"errors"`
+	Message           string           `json:"message,omitempty"` // Why the whole import failed
+	StartedAt         *time.Time       `json:"started_at,omitempty"`
+	FinishedAt        *time.Time       `json:"finished_at,omitempty"`
+}
+
+// ImportRowError explains why one row of an import was rejected
+type ImportRowError struct {
+	Row   int    `json:"row"` // 1-based data row, not counting the CSV header
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
@@ -6,17 +6,49 @@ import (
 	"gorm.io/gorm"
 )
 
+type ReviewStatus string
+
+const (
+	ReviewStatusPending  ReviewStatus = "pending"
+	ReviewStatusApproved ReviewStatus = "approved"
+	ReviewStatusRejected ReviewStatus = "rejected"
+)
+
 type Review struct {
-	ID         uint           `gorm:"primarykey" json:"id"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
-	UserID     uint           `gorm:"not null" json:"user_id"`
-	User       User           `json:"user"`
-	ProductID  uint           `gorm:"not null" json:"product_id"`
-	Product    Product        `json:"product"`
-	Rating     int            `gorm:"not null;check:rating >= 1 AND rating <= 5" json:"rating"`
-	Title      string         `gorm:"size:100" json:"title"`
-	Comment    string         `gorm:"type:text" json:"comment"`
-	IsVerified bool           `gorm:"default:false" json:"is_verified"` // Whether the user purchased the product
+	ID             uint           `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	UserID         uint           `gorm:"not null" json:"user_id"`
+	User           User           `json:"user"`
+	ProductID      uint           `gorm:"not null" json:"product_id"`
+	Product        Product        `json:"product"`
+	Rating         int            `gorm:"not null;check:rating >= 1 AND rating <= 5" json:"rating"`
+	Title          string         `gorm:"size:100" json:"title"`
+	Comment        string         `gorm:"type:text" json:"comment"`
+	IsVerified     bool           `gorm:"default:false" json:"is_verified"` // Whether the user purchased the product
+	Status         ReviewStatus   `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	ModerationNote string         `json:"moderation_note,omitempty"`
+	ModeratedBy    *uint          `json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time     `json:"moderated_at,omitempty"`
+	HelpfulCount   int            `gorm:"default:0" json:"helpful_count"`
+	Images         []ReviewImage  `json:"images"`
+}
+
+type ReviewImage struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ReviewID  uint           `gorm:"not null;index" json:"review_id"`
+	URL       string         `gorm:"not null" json:"url"`
+	FilePath  string         `gorm:"not null;default:''" json:"-"` // Name in the private store
+}
+
+// ReviewVote records that a user found a review helpful
+type ReviewVote struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ReviewID  uint      `gorm:"not null;uniqueIndex:idx_review_votes_review_user" json:"review_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_review_votes_review_user" json:"user_id"`
 }
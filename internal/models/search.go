@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SearchQuery logs one storefront search for reporting and suggestions
+type SearchQuery struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	Query       string    `gorm:"size:200;not null;index" json:"query"` // Normalized search text
+	ResultCount int64     `gorm:"not null" json:"result_count"`
+}
@@ -7,6 +7,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// Account roles. Support staff may look up customers and impersonate them;
+// only admins may change accounts.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "active"
+	UserStatusDisabled UserStatus = "disabled" // Temporarily locked out
+	UserStatusBanned   UserStatus = "banned"
+)
+
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -18,11 +34,31 @@ type User struct {
 	Role      string         `gorm:"default:user" json:"role"`
 	Address   string         `json:"address"`
 	Phone     string         `json:"phone"`
+	Locale    string         `gorm:"type:varchar(10)" json:"locale"` // Language of emails, e.g. "en" or "de"
+
+	Status                UserStatus `gorm:"type:varchar(20);default:'active';index" json:"status"`
+	StatusReason          string     `json:"status_reason,omitempty"`
+	PasswordResetRequired bool       `gorm:"default:false" json:"password_reset_required"` // Sign-in is refused until the password is reset
+}
+
+// PasswordResetToken lets a user choose a new password. Only a hash of the
+// token is stored; the token itself is emailed to the user.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
 }
 
 // BeforeSave is a GORM hook that hashes the password before saving
 func (u *User) BeforeSave(tx *gorm.DB) error {
 	if u.Password != "" {
+		// Loaded users carry the hash, which must not be hashed again
+		if _, err := bcrypt.Cost([]byte(u.Password)); err == nil {
+			return nil
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return err
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookEndpoint is a merchant URL subscribed to domain events
+type WebhookEndpoint struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	URL            string         `gorm:"not null" json:"url"`
+	Secret         string         `gorm:"not null" json:"secret"` // Key for the HMAC signature of each delivery
+	EventTypes     []string       `gorm:"type:jsonb;serializer:json" json:"event_types"`
+	IsActive       bool           `gorm:"default:true" json:"is_active"`
+	FailureCount   int            `gorm:"default:0" json:"failure_count"` // Consecutive failed deliveries
+	DisabledAt     *time.Time     `json:"disabled_at,omitempty"`
+	DisabledReason string         `json:"disabled_reason,omitempty"`
+}
+
+// Subscribes reports whether the endpoint wants events of eventType
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery logs one attempt to deliver an event to an endpoint
+type WebhookDelivery struct {
+	ID           uint            `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time       `gorm:"index" json:"created_at"`
+	EndpointID   uint            `gorm:"not null;index" json:"endpoint_id"`
+	EventID      uint            `gorm:"index" json:"event_id"`
+	EventType    string          `gorm:"type:varchar(100)" json:"event_type"`
+	Body         json.RawMessage `gorm:"type:jsonb;serializer:json" json:"body" swaggertype:"object"`
+	Attempt      int             `json:"attempt"`
+	Success      bool            `json:"success"`
+	StatusCode   int             `json:"status_code,omitempty"`
+	ResponseBody string          `gorm:"type:text" json:"response_body,omitempty"` // Truncated
+	Error        string          `gorm:"type:text" json:"error,omitempty"`
+	DurationMs   int64           `json:"duration_ms"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/money"
+	"gorm.io/gorm"
+)
+
+// SavedForLaterListName names the list cart lines are parked in
+const SavedForLaterListName = "Saved for later"
+
+type Wishlist struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	UserID     uint           `gorm:"not null;index" json:"user_id"`
+	User       *User          `json:"user,omitempty"`
+	Name       string         `gorm:"size:100;not null" json:"name"`
+	IsPublic   bool           `gorm:"default:false" json:"is_public"`
+	ShareToken string         `gorm:"size:64;uniqueIndex:idx_wishlists_share_token,where:share_token <> ''" json:"share_token,omitempty"` // Set while the list is public
+	Items      []WishlistItem `json:"items"`
+}
+
+type WishlistItem struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	WishlistID   uint           `gorm:"not null;index" json:"wishlist_id"`
+	ProductID    uint           `gorm:"not null;index" json:"product_id"`
+	Product      Product        `json:"product"`
+	PriceAtAdded money.Money    `json:"price_at_added"` // Product price when the item was added
+	Currency     string         `gorm:"type:varchar(3);not null;default:''" json:"-"`
+}
+
+func (i *WishlistItem) BeforeSave(tx *gorm.DB) error {
+	i.Currency = currencyOf(i.Currency, &i.PriceAtAdded)
+	return nil
+}
+
+func (i *WishlistItem) AfterFind(tx *gorm.DB) error {
+	setCurrency(i.Currency, &i.PriceAtAdded)
+	return nil
+}
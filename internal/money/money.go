@@ -0,0 +1,359 @@
+// Package money represents amounts as integer minor units of a currency,
+// e.g. cents, so that sums and multiples are exact. Rounding happens only
+// where an amount is divided or converted, and always by the rules below.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/sajal/go-ecommerce/internal/currency"
+)
+
+// Money is an amount in the minor unit of its currency. The zero value is
+// zero without a currency and takes the currency of whatever it is added to.
+//
+// Only the amount is stored in the database, as a bigint; records keep the
+// currency in a column of their own and set it on their amounts when loaded.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New returns amount minor units of code
+func New(amount int64, code string) Money {
+	return Money{Amount: amount, Currency: code}
+}
+
+// Zero returns no money in code
+func Zero(code string) Money {
+	return Money{Currency: code}
+}
+
+// scale is the number of minor units in one major unit of code
+func scale(code string) int64 {
+	s := int64(1)
+	for i := 0; i < currency.Decimals(code); i++ {
+		s *= 10
+	}
+	return s
+}
+
+// Parse reads a decimal amount in major units such as "19.99". Amounts with
+// more decimal places than the currency has are refused rather than
+// rounded.
+func Parse(s string, code string) (Money, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "eE/") {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt64(scale(code)))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places", s, currency.Decimals(code))
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("amount %q is too large", s)
+	}
+	return New(r.Num().Int64(), code), nil
+}
+
+// FromMajor converts a number of major units, rounding half away from zero
+// to the nearest minor unit. Use it for values that were floats already.
+func FromMajor(amount float64, code string) Money {
+	return New(int64(math.Round(amount*float64(scale(code)))), code)
+}
+
+// Major returns the amount in major units. The result is for display and
+// reporting only and must not be used to calculate further amounts.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / float64(scale(m.Currency))
+}
+
+// Decimal writes the amount in major units with the currency's decimal
+// places, e.g. "19.99"
+func (m Money) Decimal() string {
+	decimals := currency.Decimals(m.Currency)
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if decimals == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	s := scale(m.Currency)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/s, decimals, amount%s)
+}
+
+// String writes the amount followed by its currency, e.g. "19.99 USD"
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// ErrCurrencyMismatch is returned by AddChecked for amounts in different
+// currencies
+var ErrCurrencyMismatch = errors.New("money: amounts in different currencies cannot be combined")
+
+// combine returns the currency of an operation on a and b and whether they
+// can be combined at all
+func combine(a, b Money) (string, bool) {
+	switch {
+	case a.Currency == b.Currency, b.Currency == "":
+		return a.Currency, true
+	case a.Currency == "":
+		return b.Currency, true
+	}
+	return "", false
+}
+
+// common returns the currency of an operation on a and b. Mixing currencies
+// is a programming error.
+func common(a, b Money) string {
+	code, ok := combine(a, b)
+	if !ok {
+		panic(fmt.Sprintf("money: %s and %s amounts cannot be combined", a.Currency, b.Currency))
+	}
+	return code
+}
+
+func (m Money) Add(o Money) Money {
+	return New(m.Amount+o.Amount, common(m, o))
+}
+
+// AddChecked adds like Add but reports mixed currencies as an error. Use it
+// for amounts whose currencies come from stored records.
+func (m Money) AddChecked(o Money) (Money, error) {
+	code, ok := combine(m, o)
+	if !ok {
+		return m, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return New(m.Amount+o.Amount, code), nil
+}
+
+func (m Money) Sub(o Money) Money {
+	return New(m.Amount-o.Amount, common(m, o))
+}
+
+// Mul multiplies the amount by a whole number such as a quantity
+func (m Money) Mul(n int64) Money {
+	return New(m.Amount*n, m.Currency)
+}
+
+func (m Money) Neg() Money {
+	return New(-m.Amount, m.Currency)
+}
+
+// Cmp returns -1, 0 or 1 as m is less than, equal to or greater than o
+func (m Money) Cmp(o Money) int {
+	common(m, o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// Equal reports whether both amounts and currencies are the same
+func (m Money) Equal(o Money) bool {
+	return m.Amount == o.Amount && m.Currency == o.Currency
+}
+
+// Sum adds amounts of one currency
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, m := range amounts {
+		total = total.Add(m)
+	}
+	return total
+}
+
+// roundRat rounds r to the nearest integer, halves away from zero
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
+
+// Percent returns the given share of the amount in basis points (1/100 of a
+// percent), rounded half away from zero. Taxes and percentage discounts are
+// calculated once on the total they apply to and then allocated to lines,
+// never rounded per line and summed.
+func (m Money) Percent(basisPoints int64) Money {
+	r := new(big.Rat).SetFrac64(m.Amount*basisPoints, 10000)
+	return New(roundRat(r), m.Currency)
+}
+
+// Convert converts the amount into code at rate units of code per unit of
+// the amount's currency, rounding half away from zero to the minor unit of
+// code
+func (m Money) Convert(rate float64, code string) Money {
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, new(big.Rat).SetFloat64(rate))
+	r.Mul(r, new(big.Rat).SetFrac64(scale(code), scale(m.Currency)))
+	return New(roundRat(r), code)
+}
+
+// RoundTo rounds the amount to the nearest multiple of step minor units,
+// halves away from zero, e.g. to 5 for prices in steps of 0.05
+func (m Money) RoundTo(step int64) Money {
+	if step <= 1 {
+		return m
+	}
+	return New(roundRat(new(big.Rat).SetFrac64(m.Amount, step))*step, m.Currency)
+}
+
+// Allocate splits the amount into parts proportional to weights, such as a
+// discount or tax over order lines by their subtotals. The parts always add
+// up to the amount: each part is rounded down and the minor units left over
+// go one each to the parts with the largest remainders, earlier parts first
+// on ties. Without positive weights the amount is split evenly.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+	if len(weights) == 0 {
+		return parts
+	}
+
+	var total int64
+	for _, w := range weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total == 0 {
+		weights = make([]int64, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = int64(len(weights))
+	}
+
+	amount := m.Amount
+	sign := int64(1)
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+
+	remainders := make([]*big.Int, len(weights))
+	left := amount
+	for i, w := range weights {
+		if w < 0 {
+			w = 0
+		}
+		share := new(big.Int).Mul(big.NewInt(amount), big.NewInt(w))
+		q, r := new(big.Int).QuoRem(share, big.NewInt(total), new(big.Int))
+		parts[i] = New(q.Int64(), m.Currency)
+		remainders[i] = r
+		left -= q.Int64()
+	}
+	for ; left > 0; left-- {
+		best := -1
+		for i, r := range remainders {
+			if r.Sign() > 0 && (best < 0 || r.Cmp(remainders[best]) > 0) {
+				best = i
+			}
+		}
+		if best < 0 {
+			best = 0
+		}
+		parts[best].Amount++
+		remainders[best] = new(big.Int)
+	}
+
+	for i := range parts {
+		parts[i].Amount *= sign
+	}
+	return parts
+}
+
+// jsonMoney is how amounts appear in the API: minor units and a currency
+type jsonMoney struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.Amount, Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return errors.New("money must be an object with an amount in minor units and a currency")
+	}
+	code := currency.Normalize(v.Currency)
+	if code != "" && !currency.Valid(code) {
+		return fmt.Errorf("invalid currency code %q", v.Currency)
+	}
+	*m = New(v.Amount, code)
+	return nil
+}
+
+// GormDataType stores amounts as bigint columns
+func (Money) GormDataType() string {
+	return "bigint"
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
+
+// Scan reads an amount in minor units. Aggregates such as AVG return
+// fractions of a minor unit, which are rounded half away from zero.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		m.Amount = 0
+	case int64:
+		m.Amount = v
+	case float64:
+		m.Amount = int64(math.Round(v))
+	case []byte:
+		return m.scanDecimal(string(v))
+	case string:
+		return m.scanDecimal(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T", value)
+	}
+	return nil
+}
+
+func (m *Money) scanDecimal(s string) error {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("money: cannot scan %q", s)
+	}
+	m.Amount = roundRat(r)
+	return nil
+}
@@ -0,0 +1,210 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+// quickConfig runs property checks with a fixed seed so failures reproduce
+var quickConfig = &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(1))}
+
+// roundHalfAwayFromZero is the reference rounding of n/d in exact integer
+// arithmetic
+func roundHalfAwayFromZero(n, d int64) int64 {
+	q, rem := n/d, n%d
+	if rem < 0 {
+		rem = -rem
+	}
+	if 2*rem >= d {
+		if n < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+func TestAllocateSumsToAmount(t *testing.T) {
+	property := func(amount int32, raw []int16) bool {
+		weights := make([]int64, len(raw)%12+1)
+		for i := range weights {
+			if i < len(raw) {
+				weights[i] = int64(raw[i])
+			}
+		}
+		m := New(int64(amount), "USD")
+		parts := m.Allocate(weights)
+		if len(parts) != len(weights) {
+			return false
+		}
+
+		var total, positive int64
+		for _, w := range weights {
+			if w > 0 {
+				positive += w
+			}
+		}
+		for i, p := range parts {
+			if p.Currency != "USD" {
+				return false
+			}
+			total += p.Amount
+			// Parts have the sign of the amount and differ from the exact
+			// share by less than one minor unit
+			if (amount > 0 && p.Amount < 0) || (amount < 0 && p.Amount > 0) {
+				return false
+			}
+			if positive > 0 {
+				w := weights[i]
+				if w < 0 {
+					w = 0
+				}
+				exact := new(big.Rat).SetFrac64(int64(amount)*w, positive)
+				diff := new(big.Rat).Sub(new(big.Rat).SetInt64(p.Amount), exact)
+				if diff.Abs(diff).Cmp(big.NewRat(1, 1)) >= 0 {
+					return false
+				}
+			}
+		}
+		return total == int64(amount)
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllocateExamples(t *testing.T) {
+	tests := []struct {
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{-100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{5, []int64{0, 0}, []int64{3, 2}},
+		{1000, []int64{2999, 1, 0}, []int64{1000, 0, 0}},
+		{7, []int64{1, 2, 4}, []int64{1, 2, 4}},
+	}
+	for _, tt := range tests {
+		parts := New(tt.amount, "USD").Allocate(tt.weights)
+		for i, p := range parts {
+			if p.Amount != tt.want[i] {
+				t.Errorf("%d.Allocate(%v) = %v, want %v", tt.amount, tt.weights, parts, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPercentRoundsHalfAwayFromZero(t *testing.T) {
+	property := func(amount int32, basisPoints uint16) bool {
+		got := New(int64(amount), "EUR").Percent(int64(basisPoints))
+		want := roundHalfAwayFromZero(int64(amount)*int64(basisPoints), 10000)
+		return got.Amount == want && got.Currency == "EUR"
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+
+	// 19% of 0.50 is 0.095 and of -0.50 is -0.095
+	if got := New(50, "EUR").Percent(1900); got.Amount != 10 {
+		t.Errorf("19%% of 50 = %d, want 10", got.Amount)
+	}
+	if got := New(-50, "EUR").Percent(1900); got.Amount != -10 {
+		t.Errorf("19%% of -50 = %d, want -10", got.Amount)
+	}
+}
+
+func TestRoundingSteps(t *testing.T) {
+	rounding, err := ParseRounding("CHF=0.05,JPY=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := map[string]int64{"CHF": 5, "JPY": 10, "USD": 1}
+
+	property := func(amount int32, pick uint8) bool {
+		codes := []string{"CHF", "JPY", "USD"}
+		code := codes[int(pick)%len(codes)]
+		step := steps[code]
+		m := New(int64(amount), code)
+
+		got := rounding.Round(m)
+		want := roundHalfAwayFromZero(int64(amount), step) * step
+		return got.Amount == want && got.Amount%step == 0 && got.Currency == code
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		m    Money
+		want int64
+	}{
+		{New(1002, "CHF"), 1000},
+		{New(1003, "CHF"), 1005},
+		{New(-1003, "CHF"), -1005},
+		{New(1245, "JPY"), 1250},
+		{New(1244, "JPY"), 1240},
+	}
+	for _, tt := range tests {
+		if got := rounding.Round(tt.m); got.Amount != tt.want {
+			t.Errorf("Round(%v) = %v, want %d", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestTotalsIndependentOfLineOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 500; run++ {
+		lines := make([]Money, r.Intn(20)+1)
+		for i := range lines {
+			price := New(r.Int63n(1_000_000)-1000, "USD")
+			lines[i] = price.Mul(r.Int63n(50) + 1)
+		}
+		want := Sum(lines...)
+		tax := want.Percent(825)
+
+		for shuffle := 0; shuffle < 5; shuffle++ {
+			order := r.Perm(len(lines))
+			total := Zero("USD")
+			shuffled := make([]Money, len(lines))
+			for i, j := range order {
+				total = total.Add(lines[j])
+				shuffled[i] = lines[j]
+			}
+			if !total.Equal(want) {
+				t.Fatalf("total %v in order %v, %v in line order", total, order, want)
+			}
+			// Tax is calculated once on the total and its shares add up to it
+			// in any order; lines with equal remainders may swap a minor unit
+			if got := total.Percent(825); !got.Equal(tax) {
+				t.Fatalf("tax %v in order %v, %v in line order", got, order, tax)
+			}
+			if got := Sum(tax.Allocate(amounts(shuffled))...); !got.Equal(tax) {
+				t.Fatalf("tax shares add up to %v in order %v, want %v", got, order, tax)
+			}
+		}
+	}
+}
+
+func amounts(lines []Money) []int64 {
+	weights := make([]int64, len(lines))
+	for i, m := range lines {
+		weights[i] = m.Amount
+	}
+	return weights
+}
+
+func TestAddCheckedRefusesMixedCurrencies(t *testing.T) {
+	total, err := Money{}.AddChecked(New(500, "EUR"))
+	if err != nil || !total.Equal(New(500, "EUR")) {
+		t.Fatalf("zero + 5.00 EUR = %v, %v", total, err)
+	}
+	if _, err := total.AddChecked(New(500, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("5.00 EUR + 5.00 USD error = %v, want ErrCurrencyMismatch", err)
+	}
+}
@@ -0,0 +1,36 @@
+package money
+
+import (
+	"fmt"
+
+	"github.com/sajal/go-ecommerce/internal/currency"
+)
+
+// Rounding maps currencies to the step converted prices are rounded to, in
+// minor units, e.g. 5 for Swiss francs rounded to 0.05. Currencies without
+// a step are rounded to their minor unit only.
+type Rounding map[string]int64
+
+// ParseRounding parses rounding steps written in major units as
+// "CHF=0.05,JPY=10"
+func ParseRounding(s string) (Rounding, error) {
+	pairs, err := currency.ParsePairs(s)
+	if err != nil {
+		return nil, err
+	}
+	rounding := make(Rounding, len(pairs))
+	for code, value := range pairs {
+		step, err := Parse(value, code)
+		if err != nil || !step.IsPositive() {
+			return nil, fmt.Errorf("invalid rounding step %q for %s", value, code)
+		}
+		rounding[code] = step.Amount
+	}
+	return rounding, nil
+}
+
+// Round rounds an amount to the nearest step of its currency, halves away
+// from zero
+func (r Rounding) Round(m Money) Money {
+	return m.RoundTo(r[m.Currency])
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/sajal/go-ecommerce/internal/models"
 	"gorm.io/gorm"
 )
@@ -13,13 +15,18 @@ func NewAddressRepository(db *gorm.DB) *AddressRepository {
 	return &AddressRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *AddressRepository) WithContext(ctx context.Context) *AddressRepository {
+	return &AddressRepository{DB: r.DB.WithContext(ctx)}
+}
+
 func (r *AddressRepository) Create(address *models.Address) error {
 	return r.DB.Create(address).Error
 }
 
 func (r *AddressRepository) FindByID(id uint) (*models.Address, error) {
 	var address models.Address
-	err := r.DB.Preload("User").First(&address, id).Error
+	err := r.DB.First(&address, id).Error
 	return &address, err
 }
 
@@ -37,12 +44,21 @@ func (r *AddressRepository) Delete(id uint) error {
 	return r.DB.Delete(&models.Address{}, id).Error
 }
 
-func (r *AddressRepository) SetDefault(userID uint, addressID uint) error {
-	// Reset all addresses to non-default
-	if err := r.DB.Model(&models.Address{}).Where("user_id = ?", userID).Update("is_default", false).Error; err != nil {
-		return err
-	}
+func (r *AddressRepository) FindDefault(userID uint, addressType string) (*models.Address, error) {
+	var address models.Address
+	err := r.DB.Where("user_id = ? AND type = ? AND is_default = ?", userID, addressType, true).First(&address).Error
+	return &address, err
+}
+
+// SetDefault makes the address the user's default for its type
+func (r *AddressRepository) SetDefault(userID uint, addressID uint, addressType string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// Reset addresses of the same type to non-default
+		if err := tx.Model(&models.Address{}).Where("user_id = ? AND type = ?", userID, addressType).Update("is_default", false).Error; err != nil {
+			return err
+		}
 
-	// Set the specified address as default
-	return r.DB.Model(&models.Address{}).Where("id = ? AND user_id = ?", addressID, userID).Update("is_default", true).Error
+		// Set the specified address as default
+		return tx.Model(&models.Address{}).Where("id = ? AND user_id = ?", addressID, userID).Update("is_default", true).Error
+	})
 }
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/currency"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"gorm.io/gorm"
+)
+
+// Sales report bucket sizes, as understood by Postgres date_trunc
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// lostStatuses are the order states that do not count as sales
+var lostStatuses = []models.OrderStatus{models.OrderStatusCancelled, models.OrderStatusRefunded}
+
+// DateRange selects orders placed at or after From and before To
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// SalesTotals sums the sales of a period
+type SalesTotals struct {
+	Orders            int64       `json:"orders"`
+	Revenue           money.Money `json:"revenue"`
+	AverageOrderValue money.Money `json:"average_order_value"`
+}
+
+// SalesBucket sums the sales of one day, week or month
+type SalesBucket struct {
+	Period time.Time `json:"period"`
+	SalesTotals
+}
+
+// ProductSales sums the sales of one product
+type ProductSales struct {
+	ProductID uint        `json:"product_id"`
+	Name      string      `json:"name"`
+	SKU       string      `json:"sku"`
+	Quantity  int64       `json:"quantity"`
+	Orders    int64       `json:"orders"`
+	Revenue   money.Money `json:"revenue"`
+}
+
+// CategorySales sums the sales of the products in one category
+type CategorySales struct {
+	CategoryID uint        `json:"category_id"`
+	Name       string      `json:"name"`
+	Quantity   int64       `json:"quantity"`
+	Orders     int64       `json:"orders"`
+	Revenue    money.Money `json:"revenue"`
+}
+
+// RefundStats compares refunded orders with all orders that were not
+// cancelled
+type RefundStats struct {
+	Orders         int64       `json:"orders"`
+	Refunded       int64       `json:"refunded"`
+	RefundedAmount money.Money `json:"refunded_amount"`
+	Rate           float64     `json:"rate"`
+}
+
+// CustomerStats splits the customers who ordered in a period into those
+// whose first order fell in the period and those who had ordered before.
+// Guest checkouts are counted by email.
+type CustomerStats struct {
+	NewCustomers       int64       `json:"new_customers"`
+	ReturningCustomers int64       `json:"returning_customers"`
+	GuestCustomers     int64       `json:"guest_customers"`
+	NewRevenue         money.Money `json:"new_revenue"`
+	ReturningRevenue   money.Money `json:"returning_revenue"`
+	GuestRevenue       money.Money `json:"guest_revenue"`
+}
+
+type AnalyticsRepository struct {
+	DB       *gorm.DB
+	currency string // Base currency revenue is reported in
+}
+
+func NewAnalyticsRepository(db *gorm.DB, currency string) *AnalyticsRepository {
+	return &AnalyticsRepository{DB: db, currency: currency}
+}
+
+// Orders are stored in minor units of the currency they were placed in, so
+// amounts are converted back to minor units of the base currency at the rate
+// of the purchase. Sums are exact numerics rounded once when scanned.
+func baseOrderAmount(column string, base string) string {
+	return fmt.Sprintf("(%s::numeric / orders.exchange_rate::numeric * POWER(10::numeric, %d - %s))",
+		column, currency.Decimals(base), currency.DecimalsSQL("orders.currency"))
+}
+
+func baseOrderTotal(base string) string {
+	return baseOrderAmount("orders.total_amount", base)
+}
+
+func baseOrderSubtotal(base string) string {
+	return baseOrderAmount("order_items.subtotal", base)
+}
+
+// inBase sets the base currency on amounts scanned from aggregates
+func (r *AnalyticsRepository) inBase(amounts ...*money.Money) {
+	for _, m := range amounts {
+		m.Currency = r.currency
+	}
+}
+
+// sales scopes orders to those placed in the range that still count as sales
+func (r *AnalyticsRepository) sales(rng DateRange) *gorm.DB {
+	return r.DB.Model(&models.Order{}).
+		Where("orders.created_at >= ? AND orders.created_at < ?", rng.From, rng.To).
+		Where("orders.status NOT IN ?", lostStatuses)
+}
+
+// Totals sums the sales in the range
+func (r *AnalyticsRepository) Totals(rng DateRange) (*SalesTotals, error) {
+	var totals SalesTotals
+	err := r.sales(rng).
+		Select("COUNT(*) AS orders, COALESCE(SUM(" + baseOrderTotal(r.currency) + "), 0) AS revenue, COALESCE(AVG(" + baseOrderTotal(r.currency) + "), 0) AS average_order_value").
+		Scan(&totals).Error
+	r.inBase(&totals.Revenue, &totals.AverageOrderValue)
+	return &totals, err
+}
+
+// SalesByPeriod sums the sales in the range per day, week or month, oldest
+// first. Periods without sales are left out.
+func (r *AnalyticsRepository) SalesByPeriod(rng DateRange, interval string) ([]SalesBucket, error) {
+	var buckets []SalesBucket
+	err := r.sales(rng).
+		Select("date_trunc(?, orders.created_at) AS period, COUNT(*) AS orders, SUM("+baseOrderTotal(r.currency)+") AS revenue, AVG("+baseOrderTotal(r.currency)+") AS average_order_value", interval).
+		Group("period").
+		Order("period").
+		Scan(&buckets).Error
+	for i := range buckets {
+		r.inBase(&buckets[i].Revenue, &buckets[i].AverageOrderValue)
+	}
+	return buckets, err
+}
+
+// TopProducts returns the products with the highest revenue in the range.
+// Products removed from the catalog are still reported.
+func (r *AnalyticsRepository) TopProducts(rng DateRange, limit int) ([]ProductSales, error) {
+	var stats []ProductSales
+	err := r.sales(rng).
+		Joins("JOIN order_items ON order_items.order_id = orders.id AND order_items.deleted_at IS NULL").
+		Joins("LEFT JOIN products ON products.id = order_items.product_id").
+		Select("order_items.product_id, COALESCE(products.name, '') AS name, COALESCE(products.sku, '') AS sku, " +
+			"SUM(order_items.quantity) AS quantity, COUNT(DISTINCT orders.id) AS orders, SUM(" + baseOrderSubtotal(r.currency) + ") AS revenue").
+		Group("order_items.product_id, products.name, products.sku").
+		Order("revenue DESC, quantity DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	for i := range stats {
+		r.inBase(&stats[i].Revenue)
+	}
+	return stats, err
+}
+
+// TopCategories returns the categories with the highest revenue in the range
+func (r *AnalyticsRepository) TopCategories(rng DateRange, limit int) ([]CategorySales, error) {
+	var stats []CategorySales
+	err := r.sales(rng).
+		Joins("JOIN order_items ON order_items.order_id = orders.id AND order_items.deleted_at IS NULL").
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Select("products.category_id, COALESCE(categories.name, '') AS name, " +
+			"SUM(order_items.quantity) AS quantity, COUNT(DISTINCT orders.id) AS orders, SUM(" + baseOrderSubtotal(r.currency) + ") AS revenue").
+		Group("products.category_id, categories.name").
+		Order("revenue DESC, quantity DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	for i := range stats {
+		r.inBase(&stats[i].Revenue)
+	}
+	return stats, err
+}
+
+// Refunds counts the refunded orders among the orders placed in the range
+func (r *AnalyticsRepository) Refunds(rng DateRange) (*RefundStats, error) {
+	var stats RefundStats
+	err := r.DB.Model(&models.Order{}).
+		Select("COUNT(*) AS orders, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS refunded, "+
+			"COALESCE(SUM("+baseOrderTotal(r.currency)+") FILTER (WHERE status = ?), 0) AS refunded_amount",
+			models.OrderStatusRefunded, models.OrderStatusRefunded).
+		Where("created_at >= ? AND created_at < ?", rng.From, rng.To).
+		Where("status <> ?", models.OrderStatusCancelled).
+		Scan(&stats).Error
+	r.inBase(&stats.RefundedAmount)
+	if stats.Orders > 0 {
+		stats.Rate = float64(stats.Refunded) / float64(stats.Orders)
+	}
+	return &stats, err
+}
+
+// Customers splits the customers who bought in the range into new and
+// returning ones
+func (r *AnalyticsRepository) Customers(rng DateRange) (*CustomerStats, error) {
+	// Each registered customer's first sale ever
+	firsts := r.DB.Model(&models.Order{}).
+		Select("user_id, MIN(created_at) AS first_order").
+		Where("user_id IS NOT NULL AND status NOT IN ?", lostStatuses).
+		Group("user_id")
+
+	var stats CustomerStats
+	err := r.sales(rng).
+		Joins("LEFT JOIN (?) AS firsts ON firsts.user_id = orders.user_id", firsts).
+		Select("COUNT(DISTINCT orders.user_id) FILTER (WHERE firsts.first_order >= ?) AS new_customers, "+
+			"COUNT(DISTINCT orders.user_id) FILTER (WHERE firsts.first_order < ?) AS returning_customers, "+
+			"COUNT(DISTINCT LOWER(orders.guest_email)) FILTER (WHERE orders.user_id IS NULL) AS guest_customers, "+
+			"COALESCE(SUM("+baseOrderTotal(r.currency)+") FILTER (WHERE firsts.first_order >= ?), 0) AS new_revenue, "+
+			"COALESCE(SUM("+baseOrderTotal(r.currency)+") FILTER (WHERE firsts.first_order < ?), 0) AS returning_revenue, "+
+			"COALESCE(SUM("+baseOrderTotal(r.currency)+") FILTER (WHERE orders.user_id IS NULL), 0) AS guest_revenue",
+			rng.From, rng.From, rng.From, rng.From).
+		Scan(&stats).Error
+	r.inBase(&stats.NewRevenue, &stats.ReturningRevenue, &stats.GuestRevenue)
+	return &stats, err
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// auditPruneLock is the Postgres advisory lock key held while pruning, so
+// only one server instance archives a batch
+const auditPruneLock = 7305001
+
+// AuditFilter narrows an audit log listing; zero fields match everything
+type AuditFilter struct {
+	ActorID    uint
+	Action     string // Exact action, or a prefix ending in "." such as "auth."
+	EntityType string
+	EntityID   string
+	RequestID  string
+	From       time.Time
+	To         time.Time
+}
+
+type AuditRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+func (r *AuditRepository) Create(entry *models.AuditLog) error {
+	return r.DB.Create(entry).Error
+}
+
+func (r *AuditRepository) filtered(filter AuditFilter) *gorm.DB {
+	query := r.DB.Model(&models.AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		if filter.Action[len(filter.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", escapeLike(filter.Action)+"%")
+		} else {
+			query = query.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
+
+// FindFiltered returns one page of entries matching the filter, newest first
+func (r *AuditRepository) FindFiltered(filter AuditFilter, offset, limit int) ([]models.AuditLog, int64, error) {
+	var entries []models.AuditLog
+	var total int64
+
+	query := r.filtered(filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
+// FindAfter returns up to limit entries matching the filter with IDs above
+// afterID, oldest first, for reading the log in batches
+func (r *AuditRepository) FindAfter(filter AuditFilter, afterID uint, limit int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := r.filtered(filter).Where("id > ?", afterID).Order("id").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// PruneBefore deletes up to limit of the oldest entries created before the
+// cutoff, passing them to archive first in the same transaction. It returns
+// the number of entries deleted, which is zero while another instance is
+// pruning.
+func (r *AuditRepository) PruneBefore(cutoff time.Time, limit int, archive func([]models.AuditLog) error) (int, error) {
+	var n int
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", auditPruneLock).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var entries []models.AuditLog
+		if err := tx.Where("created_at < ?", cutoff).Order("id").Limit(limit).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		if err := archive(entries); err != nil {
+			return err
+		}
+
+		ids := make([]uint, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.ID
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.AuditLog{}).Error; err != nil {
+			return err
+		}
+		n = len(entries)
+		return nil
+	})
+	return n, err
+}
@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"context"
+	"errors"
+
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CartRepository struct {
@@ -13,22 +18,86 @@ func NewCartRepository(db *gorm.DB) *CartRepository {
 	return &CartRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *CartRepository) WithContext(ctx context.Context) *CartRepository {
+	return &CartRepository{DB: r.DB.WithContext(ctx)}
+}
+
 func (r *CartRepository) FindByUserID(userID uint) (*models.Cart, error) {
 	var cart models.Cart
 	err := r.DB.Preload("Items.Product").Where("user_id = ?", userID).First(&cart).Error
 	return &cart, err
 }
 
+func (r *CartRepository) FindByToken(token string) (*models.Cart, error) {
+	var cart models.Cart
+	err := r.DB.Preload("Items.Product").Where("token = ? AND user_id IS NULL", token).First(&cart).Error
+	return &cart, err
+}
+
 func (r *CartRepository) Create(cart *models.Cart) error {
 	return r.DB.Create(cart).Error
 }
 
+func (r *CartRepository) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cart_id = ?", id).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Cart{}, id).Error
+	})
+}
+
+// MergeGuestCart moves the guest cart with token into the user's cart in one
+// transaction. The guest cart is locked first, so a concurrent merge of the
+// same cart waits and then finds it gone. merge gets the items of both carts
+// and returns the user cart lines to write, new ones without an ID. It
+// reports false when there is no guest cart.
+func (r *CartRepository) MergeGuestCart(token string, userCartID uint, merge func(guest, user []models.CartItem) []*models.CartItem) (bool, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var guest models.Cart
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token = ? AND user_id IS NULL", token).First(&guest).Error
+		if err != nil {
+			return err
+		}
+
+		var guestItems, userItems []models.CartItem
+		if err := tx.Preload("Product").Where("cart_id = ?", guest.ID).Find(&guestItems).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Product").Where("cart_id = ?", userCartID).Find(&userItems).Error; err != nil {
+			return err
+		}
+
+		for _, item := range merge(guestItems, userItems) {
+			item.CartID = userCartID
+			if err := tx.Omit(clause.Associations).Save(item).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("cart_id = ?", guest.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Cart{}, guest.ID).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (r *CartRepository) AddItem(item *models.CartItem) error {
 	return r.DB.Create(item).Error
 }
 
 func (r *CartRepository) UpdateItem(item *models.CartItem) error {
-	return r.DB.Save(item).Error
+	return r.DB.Omit(clause.Associations).Save(item).Error
+}
+
+func (r *CartRepository) UpdateTotal(cartID uint, total money.Money) error {
+	return r.DB.Model(&models.Cart{}).Where("id = ?", cartID).Update("total", total).Error
 }
 
 func (r *CartRepository) RemoveItem(id uint) error {
@@ -39,6 +108,10 @@ func (r *CartRepository) ClearCart(userID uint) error {
 	return r.DB.Exec("DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id = ?)", userID).Error
 }
 
+func (r *CartRepository) ClearItems(cartID uint) error {
+	return r.DB.Exec("DELETE FROM cart_items WHERE cart_id = ?", cartID).Error
+}
+
 func (r *CartRepository) FindCartItem(cartID uint, productID uint) (*models.CartItem, error) {
 	var item models.CartItem
 	err := r.DB.Where("cart_id = ? AND product_id = ?", cartID, productID).First(&item).Error
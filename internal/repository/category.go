@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/sajal/go-ecommerce/internal/models"
 	"gorm.io/gorm"
 )
@@ -13,6 +15,11 @@ func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
 	return &CategoryRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *CategoryRepository) WithContext(ctx context.Context) *CategoryRepository {
+	return &CategoryRepository{DB: r.DB.WithContext(ctx)}
+}
+
 func (r *CategoryRepository) Create(category *models.Category) error {
 	return r.DB.Create(category).Error
 }
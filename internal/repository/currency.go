@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CurrencyRepository struct {
+	DB *gorm.DB
+}
+
+func NewCurrencyRepository(db *gorm.DB) *CurrencyRepository {
+	return &CurrencyRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *CurrencyRepository) WithContext(ctx context.Context) *CurrencyRepository {
+	return &CurrencyRepository{DB: r.DB.WithContext(ctx)}
+}
+
+func (r *CurrencyRepository) FindRates() ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := r.DB.Order("currency").Find(&rates).Error
+	return rates, err
+}
+
+// SaveRates inserts or replaces the rates of the given currencies
+func (r *CurrencyRepository) SaveRates(rates []models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).Create(&rates).Error
+}
+
+// FindProductPrices returns the price overrides of a product in every
+// currency
+func (r *CurrencyRepository) FindProductPrices(productID uint) ([]models.ProductPrice, error) {
+	var prices []models.ProductPrice
+	err := r.DB.Where("product_id = ?", productID).Order("currency").Find(&prices).Error
+	return prices, err
+}
+
+// FindPricesIn returns the overrides of the given products in one currency
+func (r *CurrencyRepository) FindPricesIn(productIDs []uint, currency string) ([]models.ProductPrice, error) {
+	var prices []models.ProductPrice
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+	err := r.DB.Where("product_id IN ? AND currency = ?", productIDs, currency).Find(&prices).Error
+	return prices, err
+}
+
+// SaveProductPrice inserts or replaces the override of a product in the
+// price's currency
+func (r *CurrencyRepository) SaveProductPrice(price *models.ProductPrice) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "updated_at"}),
+	}).Create(price).Error
+}
+
+// DeleteProductPrice removes an override. It reports false when there was
+// none.
+func (r *CurrencyRepository) DeleteProductPrice(productID uint, currency string) (bool, error) {
+	result := r.DB.Where("product_id = ? AND currency = ?", productID, currency).Delete(&models.ProductPrice{})
+	return result.RowsAffected > 0, result.Error
+}
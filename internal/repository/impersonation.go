@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+type ImpersonationRepository struct {
+	DB *gorm.DB
+}
+
+func NewImpersonationRepository(db *gorm.DB) *ImpersonationRepository {
+	return &ImpersonationRepository{DB: db}
+}
+
+func (r *ImpersonationRepository) Create(session *models.ImpersonationSession) error {
+	return r.DB.Create(session).Error
+}
+
+func (r *ImpersonationRepository) FindByID(id uint) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	err := r.DB.Preload("Impersonator").Preload("User").First(&session, id).Error
+	return &session, err
+}
+
+// FindPaged returns one page of sessions, newest first. A non-zero userID
+// limits them to the sessions impersonating that customer.
+func (r *ImpersonationRepository) FindPaged(userID uint, offset, limit int) ([]models.ImpersonationSession, int64, error) {
+	var sessions []models.ImpersonationSession
+	var total int64
+
+	query := r.DB.Model(&models.ImpersonationSession{})
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Impersonator").Preload("User").Order("id DESC").Offset(offset).Limit(limit).Find(&sessions).Error
+	return sessions, total, err
+}
+
+// End closes an open session; ending a closed session has no effect
+func (r *ImpersonationRepository) End(id uint, endedBy uint) error {
+	return r.DB.Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL", id).
+		Updates(map[string]interface{}{"ended_at": time.Now(), "ended_by": endedBy}).Error
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+type InvoiceRepository struct {
+	DB *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) *InvoiceRepository {
+	return &InvoiceRepository{DB: db}
+}
+
+func (r *InvoiceRepository) FindByOrderID(orderID uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.DB.Where("order_id = ?", orderID).First(&invoice).Error
+	return &invoice, err
+}
+
+// Issue allocates the next number of the invoice's year and inserts the
+// invoice in one transaction. The sequence row stays locked until commit,
+// so concurrent invoices queue up, and a failed issue (including one where
+// render fails) releases its number, leaving no gaps. render receives the
+// numbered invoice before it is saved.
+func (r *InvoiceRepository) Issue(invoice *models.Invoice, render func(invoice *models.Invoice) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var sequence models.InvoiceSequence
+		err := tx.Raw(`
+			INSERT INTO invoice_sequences (year, last_number) VALUES (?, 1)
+			ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+			RETURNING year, last_number`, invoice.Year).Scan(&sequence).Error
+		if err != nil {
+			return err
+		}
+
+		invoice.Sequence = sequence.LastNumber
+		if err := render(invoice); err != nil {
+			return err
+		}
+		return tx.Create(invoice).Error
+	})
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobFilter narrows a job listing; empty fields match everything
+type JobFilter struct {
+	Queue  string
+	Type   string
+	Status models.JobStatus
+}
+
+// JobCount is the number of jobs of a queue in one status
+type JobCount struct {
+	Queue  string           `json:"queue"`
+	Status models.JobStatus `json:"status"`
+	Count  int64            `json:"count"`
+}
+
+type JobRepository struct {
+	DB *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{DB: db}
+}
+
+func (r *JobRepository) Create(job *models.Job) error {
+	return r.DB.Create(job).Error
+}
+
+func (r *JobRepository) FindByID(id uint) (*models.Job, error) {
+	var job models.Job
+	err := r.DB.First(&job, id).Error
+	return &job, err
+}
+
+func (r *JobRepository) FindFiltered(filter JobFilter, offset, limit int) ([]models.Job, int64, error) {
+	var jobs []models.Job
+	var total int64
+
+	query := r.DB.Model(&models.Job{})
+	if filter.Queue != "" {
+		query = query.Where("queue = ?", filter.Queue)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// CountByStatus counts jobs per queue and status
+func (r *JobRepository) CountByStatus() ([]JobCount, error) {
+	var counts []JobCount
+	err := r.DB.Model(&models.Job{}).
+		Select("queue, status, COUNT(*) AS count").
+		Group("queue, status").
+		Order("queue, status").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// Claim takes the next due job of a queue and marks it running. Locked rows
+// are skipped so concurrent workers never receive the same job. It returns
+// gorm.ErrRecordNotFound when nothing is due.
+func (r *JobRepository) Claim(queue string) (*models.Job, error) {
+	var job models.Job
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ? AND status = ? AND run_at <= ?", queue, models.JobStatusPending, time.Now()).
+			Order("run_at, id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Model(&job).Select("status", "attempts", "locked_at").Updates(&job).Error
+	})
+	return &job, err
+}
+
+func (r *JobRepository) Complete(id uint) error {
+	return r.DB.Model(&models.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       models.JobStatusCompleted,
+		"completed_at": time.Now(),
+		"locked_at":    nil,
+		"last_error":   "",
+	}).Error
+}
+
+// Reschedule returns a failed job to its queue to run again at runAt
+func (r *JobRepository) Reschedule(id uint, runAt time.Time, lastError string) error {
+	return r.DB.Model(&models.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     models.JobStatusPending,
+		"run_at":     runAt,
+		"locked_at":  nil,
+		"last_error": lastError,
+	}).Error
+}
+
+// MarkDead moves a job that ran out of attempts to the dead-letter state
+func (r *JobRepository) MarkDead(id uint, lastError string) error {
+	return r.DB.Model(&models.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     models.JobStatusDead,
+		"locked_at":  nil,
+		"last_error": lastError,
+	}).Error
+}
+
+// Retry makes a dead or pending job due now with a fresh set of attempts
+func (r *JobRepository) Retry(id uint) (bool, error) {
+	result := r.DB.Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, []models.JobStatus{models.JobStatusDead, models.JobStatusPending}).
+		Updates(map[string]interface{}{
+			"status":   models.JobStatusPending,
+			"run_at":   time.Now(),
+			"attempts": 0,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RequeueStale returns jobs whose worker has held them since before the
+// cutoff, such as after a crash, to their queue
+func (r *JobRepository) RequeueStale(cutoff time.Time) (int64, error) {
+	result := r.DB.Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", models.JobStatusRunning, cutoff).
+		Updates(map[string]interface{}{
+			"status":    models.JobStatusPending,
+			"locked_at": nil,
+		})
+	return result.RowsAffected, result.Error
+}
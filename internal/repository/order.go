@@ -1,10 +1,35 @@
 package repository
 
 import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
 	"gorm.io/gorm"
 )
 
+// Payment states an order listing can be filtered by
+const (
+	PaymentPaid   = "paid"
+	PaymentUnpaid = "unpaid"
+)
+
+// OrderFilter narrows an admin order listing; zero fields match everything
+type OrderFilter struct {
+	UserID   uint
+	Status   models.OrderStatus
+	From     time.Time   // Placed at or after
+	To       time.Time   // Placed before
+	Email    string      // Part of the customer or guest email
+	MinTotal money.Money // In the base currency
+	MaxTotal money.Money // In the base currency
+	Payment  string      // PaymentPaid or PaymentUnpaid
+	Search   string      // Order ID or part of the tracking number
+}
+
 type OrderRepository struct {
 	DB *gorm.DB
 }
@@ -13,13 +38,57 @@ func NewOrderRepository(db *gorm.DB) *OrderRepository {
 	return &OrderRepository{DB: db}
 }
 
-func (r *OrderRepository) Create(order *models.Order) error {
-	return r.DB.Create(order).Error
+// WithContext returns a copy of the repository whose statements run in ctx,
+// so they are cancelled and traced with the request
+func (r *OrderRepository) WithContext(ctx context.Context) *OrderRepository {
+	return &OrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// Create inserts the order and records events in the same transaction
+func (r *OrderRepository) Create(order *models.Order, events ...models.OutboxEvent) error {
+	return withEvents(r.DB, events, func(tx *gorm.DB) error {
+		return tx.Create(order).Error
+	})
+}
+
+// CreateFromCart inserts the order placed from cart, empties the cart and
+// records events in one transaction, so an order is never placed without
+// its cart being emptied. Guest carts are deleted as they are not used again.
+func (r *OrderRepository) CreateFromCart(order *models.Order, cart *models.Cart, events ...models.OutboxEvent) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if cart.UserID != nil {
+			if err := tx.Exec("DELETE FROM cart_items WHERE cart_id = ?", cart.ID).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Cart{}, cart.ID).Error; err != nil {
+				return err
+			}
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		return tx.Create(&events).Error
+	})
+}
+
+// withDeleted preloads rows even when soft-deleted: products removed from
+// the catalog and addresses removed from an address book still describe
+// past orders
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
 }
 
 func (r *OrderRepository) FindByID(id uint) (*models.Order, error) {
 	var order models.Order
-	err := r.DB.Preload("User").Preload("Items").Preload("Items.Product").First(&order, id).Error
+	err := r.DB.Preload("User").Preload("Items").Preload("Items.Product", withDeleted).
+		Preload("ShippingAddress", withDeleted).Preload("BillingAddress", withDeleted).First(&order, id).Error
 	return &order, err
 }
 
@@ -33,16 +102,104 @@ func (r *OrderRepository) Update(order *models.Order) error {
 	return r.DB.Save(order).Error
 }
 
-func (r *OrderRepository) UpdateStatus(id uint, status models.OrderStatus) error {
-	return r.DB.Model(&models.Order{}).Where("id = ?", id).Update("status", status).Error
+// UpdateStatus sets the order status and records events in the same transaction
+func (r *OrderRepository) UpdateStatus(id uint, status models.OrderStatus, events ...models.OutboxEvent) error {
+	return withEvents(r.DB, events, func(tx *gorm.DB) error {
+		return tx.Model(&models.Order{}).Where("id = ?", id).Update("status", status).Error
+	})
+}
+
+// UpdateShipment sets the order status and tracking number and records
+// events in the same transaction
+func (r *OrderRepository) UpdateShipment(id uint, status models.OrderStatus, trackingNumber string, events ...models.OutboxEvent) error {
+	return withEvents(r.DB, events, func(tx *gorm.DB) error {
+		return tx.Model(&models.Order{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":          status,
+			"tracking_number": trackingNumber,
+		}).Error
+	})
 }
 
 func (r *OrderRepository) Delete(id uint) error {
 	return r.DB.Delete(&models.Order{}, id).Error
 }
 
-func (r *OrderRepository) FindAll() ([]models.Order, error) {
+// FindFiltered returns one page of orders matching the filter, newest first,
+// with their customer, shipping address and lines
+func (r *OrderRepository) FindFiltered(filter OrderFilter, offset, limit int) ([]models.Order, int64, error) {
 	var orders []models.Order
-	err := r.DB.Preload("User").Preload("Items").Preload("Items.Product").Find(&orders).Error
-	return orders, err
+	var total int64
+
+	query := r.DB.Model(&models.Order{}).Joins("LEFT JOIN users ON users.id = orders.user_id")
+	if filter.UserID != 0 {
+		query = query.Where("orders.user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("orders.status = ?", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("orders.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("orders.created_at < ?", filter.To)
+	}
+	if filter.Email != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter.Email)) + "%"
+		query = query.Where("LOWER(users.email) LIKE ? OR LOWER(orders.guest_email) LIKE ?", pattern, pattern)
+	}
+	if filter.MinTotal.IsPositive() {
+		query = query.Where(baseOrderTotal(filter.MinTotal.Currency)+" >= ?", filter.MinTotal.Amount)
+	}
+	if filter.MaxTotal.IsPositive() {
+		query = query.Where(baseOrderTotal(filter.MaxTotal.Currency)+" <= ?", filter.MaxTotal.Amount)
+	}
+	switch filter.Payment {
+	case PaymentPaid:
+		query = query.Where("orders.payment_id <> ''")
+	case PaymentUnpaid:
+		query = query.Where("orders.payment_id = '' OR orders.payment_id IS NULL")
+	}
+	if search := strings.TrimPrefix(strings.TrimSpace(filter.Search), "#"); search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		if id, err := strconv.ParseUint(search, 10, 32); err == nil {
+			query = query.Where("orders.id = ? OR LOWER(orders.tracking_number) LIKE ?", id, pattern)
+		} else {
+			query = query.Where("LOWER(orders.tracking_number) LIKE ?", pattern)
+		}
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").Preload("ShippingAddress", withDeleted).Preload("Items").
+		Order("orders.id DESC").Offset(offset).Limit(limit).Find(&orders).Error
+	return orders, total, err
+}
+
+// FindByIDs returns the given orders with everything FindByID loads, in the
+// order of ids. Missing orders are left out.
+func (r *OrderRepository) FindByIDs(ids []uint) ([]models.Order, error) {
+	var found []models.Order
+	err := r.DB.Preload("User").Preload("Items").Preload("Items.Product", withDeleted).
+		Preload("ShippingAddress", withDeleted).Preload("BillingAddress", withDeleted).Where("id IN ?", ids).Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Order, len(found))
+	for _, order := range found {
+		byID[order.ID] = order
+	}
+	orders := make([]models.Order, 0, len(found))
+	for _, id := range ids {
+		if order, ok := byID[id]; ok {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// withEvents runs fn and records events in the outbox in one transaction.
+// Without events fn runs on db directly.
+func withEvents(db *gorm.DB, events []models.OutboxEvent, fn func(tx *gorm.DB) error) error {
+	if len(events) == 0 {
+		return fn(db)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Create(&events).Error
+	})
+}
+
+type OutboxRepository struct {
+	DB *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{DB: db}
+}
+
+func (r *OutboxRepository) FindByID(id uint) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	err := r.DB.First(&event, id).Error
+	return &event, err
+}
+
+// DispatchPending locks up to limit undispatched events, oldest first, and
+// hands them to fn in a transaction. The events are marked dispatched if fn
+// succeeds; fn may use tx to record its own work atomically with that.
+func (r *OutboxRepository) DispatchPending(limit int, fn func(tx *gorm.DB, events []models.OutboxEvent) error) (int, error) {
+	var count int
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var events []models.OutboxEvent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		if err := fn(tx, events); err != nil {
+			return err
+		}
+
+		ids := make([]uint, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		count = len(events)
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("dispatched_at", time.Now()).Error
+	})
+	return count, err
+}
+
+// DeleteDispatchedBefore prunes events dispatched before the cutoff
+func (r *OutboxRepository) DeleteDispatchedBefore(cutoff time.Time) (int64, error) {
+	result := r.DB.Where("dispatched_at < ?", cutoff).Delete(&models.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
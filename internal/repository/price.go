@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PriceRepository struct {
+	DB *gorm.DB
+}
+
+func NewPriceRepository(db *gorm.DB) *PriceRepository {
+	return &PriceRepository{DB: db}
+}
+
+// Transaction runs fn with a repository whose queries share one transaction
+func (r *PriceRepository) Transaction(fn func(tx *PriceRepository) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(&PriceRepository{DB: tx})
+	})
+}
+
+func (r *PriceRepository) CreateSchedule(schedule *models.PriceSchedule) error {
+	return r.DB.Create(schedule).Error
+}
+
+func (r *PriceRepository) FindSchedule(id uint) (*models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	err := r.DB.First(&schedule, id).Error
+	return &schedule, err
+}
+
+// FindSchedules returns the schedules of a product, latest start first
+func (r *PriceRepository) FindSchedules(productID uint) ([]models.PriceSchedule, error) {
+	var schedules []models.PriceSchedule
+	err := r.DB.Where("product_id = ?", productID).Order("starts_at DESC, id DESC").Find(&schedules).Error
+	return schedules, err
+}
+
+// CountOverlappingSales counts the pending and running sales of a product
+// that overlap the period from start to end
+func (r *PriceRepository) CountOverlappingSales(productID uint, start, end time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.PriceSchedule{}).
+		Where("product_id = ? AND kind = ? AND cancelled_at IS NULL AND ended_at IS NULL", productID, models.PriceScheduleSale).
+		Where("starts_at < ? AND ends_at > ?", end, start).
+		Count(&count).Error
+	return count, err
+}
+
+// FindHistory returns one page of a product's price history, newest first
+func (r *PriceRepository) FindHistory(productID uint, offset, limit int) ([]models.PriceChange, int64, error) {
+	var changes []models.PriceChange
+	var total int64
+
+	query := r.DB.Model(&models.PriceChange{}).Where("product_id = ?", productID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("effective_at DESC, id DESC").Offset(offset).Limit(limit).Find(&changes).Error
+	return changes, total, err
+}
+
+// CancelSchedule cancels a schedule that has not started. It reports false
+// when the schedule started or was cancelled in the meantime.
+func (r *PriceRepository) CancelSchedule(id uint, now time.Time) (bool, error) {
+	result := r.DB.Model(&models.PriceSchedule{}).
+		Where("id = ? AND started_at IS NULL AND cancelled_at IS NULL", id).
+		Update("cancelled_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+// setPrices saves the product's prices with a history entry
+func setPrices(tx *gorm.DB, product *models.Product, reason string, scheduleID uint, now time.Time) error {
+	err := tx.Model(product).Updates(map[string]interface{}{
+		"price":            product.Price,
+		"compare_at_price": product.CompareAtPrice,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(models.NewPriceChange(product, reason, &scheduleID, now)).Error
+}
+
+// StartSchedule applies a pending schedule to its product in one
+// transaction. A sale whose end has already passed, or whose product was
+// deleted, is cancelled instead. It reports false when nothing was applied.
+func (r *PriceRepository) StartSchedule(id uint, now time.Time) (bool, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var schedule models.PriceSchedule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("started_at IS NULL AND cancelled_at IS NULL").
+			First(&schedule, id).Error
+		if err != nil {
+			return err
+		}
+
+		var product models.Product
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, schedule.ProductID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) ||
+			(schedule.Kind == models.PriceScheduleSale && !schedule.EndsAt.After(now)) {
+			if err := tx.Model(&schedule).Update("cancelled_at", now).Error; err != nil {
+				return err
+			}
+			return gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		reason := models.PriceChangeScheduled
+		if schedule.Kind == models.PriceScheduleSale {
+			reason = models.PriceChangeSaleStarted
+		}
+		schedule.Apply(&product)
+		if err := setPrices(tx, &product, reason, schedule.ID, now); err != nil {
+			return err
+		}
+		return tx.Model(&schedule).Update("started_at", now).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// EndSale puts the product of a running sale back to its regular price in
+// one transaction. It reports false when the sale is not running.
+func (r *PriceRepository) EndSale(id uint, now time.Time) (bool, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var schedule models.PriceSchedule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kind = ? AND started_at IS NOT NULL AND ended_at IS NULL AND cancelled_at IS NULL", models.PriceScheduleSale).
+			First(&schedule, id).Error
+		if err != nil {
+			return err
+		}
+
+		var product models.Product
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, schedule.ProductID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && product.CompareAtPrice != nil {
+			product.Price = *product.CompareAtPrice
+			product.CompareAtPrice = nil
+			if err := setPrices(tx, &product, models.PriceChangeSaleEnded, schedule.ID, now); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&schedule).Update("ended_at", now).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
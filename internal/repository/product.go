@@ -1,10 +1,34 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
 	"gorm.io/gorm"
 )
 
+// ProductFilter narrows and orders a product listing. Zero values disable
+// the corresponding filter.
+type ProductFilter struct {
+	CategoryID uint
+	MinPrice   money.Money // In the base currency
+	MaxPrice   money.Money // In the base currency
+	MinRating  float64
+	Search     string
+	Sort       string // One of ProductSortOrders
+}
+
+// ProductSortOrders maps the public sort keys for product listings to SQL
+var ProductSortOrders = map[string]string{
+	"newest":     "created_at DESC",
+	"price_asc":  "price ASC",
+	"price_desc": "price DESC",
+	"rating":     "rating_average DESC, rating_count DESC",
+	"reviews":    "rating_count DESC",
+}
+
 type ProductRepository struct {
 	DB *gorm.DB
 }
@@ -13,13 +37,35 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{DB: db}
 }
 
-func (r *ProductRepository) Create(product *models.Product) error {
-	return r.DB.Create(product).Error
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *ProductRepository) WithContext(ctx context.Context) *ProductRepository {
+	return &ProductRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// Create inserts the product with the first entry of its price history and
+// records events in the same transaction
+func (r *ProductRepository) Create(product *models.Product, events ...models.OutboxEvent) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return withEvents(tx, events, func(tx *gorm.DB) error {
+			if err := tx.Create(product).Error; err != nil {
+				return err
+			}
+			return tx.Create(models.NewPriceChange(product, models.PriceChangeCreated, nil, product.CreatedAt)).Error
+		})
+	})
 }
 
 func (r *ProductRepository) FindByID(id uint) (*models.Product, error) {
 	var product models.Product
-	err := r.DB.Preload("Category").Preload("Reviews").First(&product, id).Error
+	err := r.DB.Preload("Category").First(&product, id).Error
+	return &product, err
+}
+
+// FindBySKU looks a product up by SKU, including deleted products since
+// their SKUs stay taken
+func (r *ProductRepository) FindBySKU(sku string) (*models.Product, error) {
+	var product models.Product
+	err := r.DB.Unscoped().Where("sku = ?", sku).First(&product).Error
 	return &product, err
 }
 
@@ -29,28 +75,88 @@ func (r *ProductRepository) FindAll() ([]models.Product, error) {
 	return products, err
 }
 
+func (r *ProductRepository) FindFiltered(filter ProductFilter) ([]models.Product, error) {
+	var products []models.Product
+	err := withTypoThreshold(r.DB, func(tx *gorm.DB) error {
+		query := tx.Preload("Category").Scopes(filterScope(filter, noFacet))
+
+		if order, ok := ProductSortOrders[filter.Sort]; ok {
+			query = query.Order(order)
+		} else if toTSQuery(filter.Search) != "" {
+			query = query.Clauses(searchRank(filter.Search))
+		}
+
+		return query.Order("products.id").Find(&products).Error
+	})
+	return products, err
+}
+
 func (r *ProductRepository) FindByCategory(categoryID uint) ([]models.Product, error) {
 	var products []models.Product
 	err := r.DB.Preload("Category").Where("category_id = ?", categoryID).Find(&products).Error
 	return products, err
 }
 
-func (r *ProductRepository) Update(product *models.Product) error {
-	return r.DB.Save(product).Error
+// Update saves the product and records events in the same transaction. A
+// non-nil price change is added to the price history too.
+func (r *ProductRepository) Update(product *models.Product, price *models.PriceChange, events ...models.OutboxEvent) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return withEvents(tx, events, func(tx *gorm.DB) error {
+			if err := tx.Save(product).Error; err != nil {
+				return err
+			}
+			if price == nil {
+				return nil
+			}
+			return tx.Create(price).Error
+		})
+	})
 }
 
 func (r *ProductRepository) Delete(id uint) error {
 	return r.DB.Delete(&models.Product{}, id).Error
 }
 
-func (r *ProductRepository) Search(query string) ([]models.Product, error) {
-	var products []models.Product
-	err := r.DB.Preload("Category").
-		Where("name LIKE ? OR description LIKE ?", "%"+query+"%", "%"+query+"%").
-		Find(&products).Error
-	return products, err
+// UpdateStock sets the stock level and records events in the same transaction
+func (r *ProductRepository) UpdateStock(id uint, quantity int, events ...models.OutboxEvent) error {
+	return withEvents(r.DB, events, func(tx *gorm.DB) error {
+		return tx.Model(&models.Product{}).Where("id = ?", id).Update("stock", quantity).Error
+	})
+}
+
+// ratingAggregateSQL recomputes the rating columns of the products selected
+// by the %s clause from their approved reviews
+const ratingAggregateSQL = `
+UPDATE products SET
+	rating_count = agg.count,
+	rating_average = agg.average,
+	rating_one_star = agg.one_star,
+	rating_two_star = agg.two_star,
+	rating_three_star = agg.three_star,
+	rating_four_star = agg.four_star,
+	rating_five_star = agg.five_star
+FROM (
+	SELECT p.id AS product_id,
+		COUNT(r.id) AS count,
+		COALESCE(ROUND(AVG(r.rating)::numeric, 2), 0) AS average,
+		COUNT(r.id) FILTER (WHERE r.rating = 1) AS one_star,
+		COUNT(r.id) FILTER (WHERE r.rating = 2) AS two_star,
+		COUNT(r.id) FILTER (WHERE r.rating = 3) AS three_star,
+		COUNT(r.id) FILTER (WHERE r.rating = 4) AS four_star,
+		COUNT(r.id) FILTER (WHERE r.rating = 5) AS five_star
+	FROM products p
+	LEFT JOIN reviews r ON r.product_id = p.id AND r.status = 'approved' AND r.deleted_at IS NULL
+	%s
+	GROUP BY p.id
+) agg
+WHERE products.id = agg.product_id`
+
+// RefreshRating recomputes the rating aggregates of one product
+func (r *ProductRepository) RefreshRating(id uint) error {
+	return r.DB.Exec(fmt.Sprintf(ratingAggregateSQL, "WHERE p.id = ?"), id).Error
 }
 
-func (r *ProductRepository) UpdateStock(id uint, quantity int) error {
-	return r.DB.Model(&models.Product{}).Where("id = ?", id).Update("stock", quantity).Error
+// RefreshAllRatings recomputes the rating aggregates of every product
+func (r *ProductRepository) RefreshAllRatings() error {
+	return r.DB.Exec(fmt.Sprintf(ratingAggregateSQL, "")).Error
 }
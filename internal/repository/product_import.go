@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+type ProductImportRepository struct {
+	DB *gorm.DB
+}
+
+func NewProductImportRepository(db *gorm.DB) *ProductImportRepository {
+	return &ProductImportRepository{DB: db}
+}
+
+// Transaction runs fn with a repository whose queries share one transaction
+func (r *ProductImportRepository) Transaction(fn func(tx *ProductImportRepository) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(&ProductImportRepository{DB: tx})
+	})
+}
+
+func (r *ProductImportRepository) Create(productImport *models.ProductImport) error {
+	return r.DB.Create(productImport).Error
+}
+
+func (r *ProductImportRepository) FindByID(id uint) (*models.ProductImport, error) {
+	var productImport models.ProductImport
+	err := r.DB.First(&productImport, id).Error
+	return &productImport, err
+}
+
+func (r *ProductImportRepository) FindRecent(limit int) ([]models.ProductImport, error) {
+	var imports []models.ProductImport
+	err := r.DB.Omit("errors").Order("created_at DESC").Limit(limit).Find(&imports).Error
+	return imports, err
+}
+
+func (r *ProductImportRepository) Update(productImport *models.ProductImport) error {
+	return r.DB.Save(productImport).Error
+}
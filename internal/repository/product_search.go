@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// typoSimilarity is the minimum pg_trgm word similarity between the query
+// and a product name for the product to match despite misspellings
+const typoSimilarity = "0.3"
+
+// PriceBucketEdges are the lower bounds of the price facet buckets in whole
+// units of the base currency; the last bucket is open-ended
+var PriceBucketEdges = []int64{0, 25, 50, 100, 250, 500}
+
+// RatingFacetSteps are the "N stars & up" thresholds of the rating facet
+var RatingFacetSteps = []int{4, 3, 2, 1}
+
+// ProductSearch is a full-text product query with filters and paging
+type ProductSearch struct {
+	Query    string
+	Filter   ProductFilter
+	Currency string // Base currency of the price facet
+	Offset   int
+	Limit    int
+}
+
+type CategoryFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type PriceFacet struct {
+	Min   money.Money  `json:"min"`
+	Max   *money.Money `json:"max"` // Nil for the open-ended top bucket
+	Count int64        `json:"count"`
+}
+
+type RatingFacet struct {
+	MinRating int   `json:"min_rating"`
+	Count     int64 `json:"count"`
+}
+
+type SearchFacets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Price      []PriceFacet    `json:"price"`
+	Rating     []RatingFacet   `json:"rating"`
+}
+
+type ProductSearchResult struct {
+	Products []models.Product `json:"items"`
+	Total    int64            `json:"total"`
+	Facets   SearchFacets     `json:"facets"`
+}
+
+// facet names a facet whose own filter is left out when counting it, so the
+// storefront can offer the alternatives to the current selection
+type facet int
+
+const (
+	noFacet facet = iota
+	categoryFacet
+	priceFacet
+	ratingFacet
+)
+
+// toTSQuery turns free text into a prefix-matching tsquery such as
+// "blue:* & shirt:*", or "" when the text has no searchable words
+func toTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// textMatch restricts the query to products matching the search text either
+// through the weighted search vector or, for typos, trigram similarity. The
+// <% operator can use the trigram index; it matches at the threshold set by
+// withTypoThreshold.
+func textMatch(db *gorm.DB, text string) *gorm.DB {
+	tsq := toTSQuery(text)
+	if tsq == "" {
+		return db
+	}
+	return db.Where("(products.search_vector @@ to_tsquery('simple', ?) OR ? <% products.name)", tsq, text)
+}
+
+// withTypoThreshold runs fn in a transaction in which the <% operator of
+// textMatch matches at typoSimilarity rather than the pg_trgm default
+func withTypoThreshold(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", typoSimilarity).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// searchRank orders matches by text relevance, name similarity breaking ties
+func searchRank(text string) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "ts_rank_cd(products.search_vector, to_tsquery('simple', ?)) + word_similarity(?, products.name) DESC",
+		Vars:               []interface{}{toTSQuery(text), text},
+		WithoutParentheses: true,
+	}}
+}
+
+// filterScope applies the filter, leaving out the filter of the given facet
+func filterScope(filter ProductFilter, skip facet) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.CategoryID != 0 && skip != categoryFacet {
+			db = db.Where("products.category_id = ?", filter.CategoryID)
+		}
+		if skip != priceFacet {
+			if filter.MinPrice.IsPositive() {
+				db = db.Where("products.price >= ?", filter.MinPrice.Amount)
+			}
+			if filter.MaxPrice.IsPositive() {
+				db = db.Where("products.price <= ?", filter.MaxPrice.Amount)
+			}
+		}
+		if filter.MinRating > 0 && skip != ratingFacet {
+			db = db.Where("products.rating_average >= ?", filter.MinRating)
+		}
+		return textMatch(db, filter.Search)
+	}
+}
+
+// Search runs a ranked full-text search over active products and counts the
+// facets of the matching set
+func (r *ProductRepository) Search(params ProductSearch) (result *ProductSearchResult, err error) {
+	err = withTypoThreshold(r.DB, func(tx *gorm.DB) error {
+		result, err = (&ProductRepository{DB: tx}).search(params)
+		return err
+	})
+	return result, err
+}
+
+func (r *ProductRepository) search(params ProductSearch) (*ProductSearchResult, error) {
+	filter := params.Filter
+	filter.Search = params.Query
+
+	base := func(skip facet) *gorm.DB {
+		return r.DB.Model(&models.Product{}).
+			Where("products.is_active = ?", true).
+			Scopes(filterScope(filter, skip))
+	}
+
+	result := &ProductSearchResult{}
+	if err := base(noFacet).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := base(noFacet).Preload("Category")
+	if order, ok := ProductSortOrders[filter.Sort]; ok {
+		query = query.Order(order)
+	} else if toTSQuery(params.Query) != "" {
+		query = query.Clauses(searchRank(params.Query))
+	}
+	if err := query.Order("products.id").Offset(params.Offset).Limit(params.Limit).Find(&result.Products).Error; err != nil {
+		return nil, err
+	}
+
+	// Category facet
+	err := base(categoryFacet).
+		Select("products.category_id AS id, categories.name AS name, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id").
+		Group("products.category_id, categories.name").
+		Order("count DESC, categories.name").
+		Scan(&result.Facets.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Price facet
+	edges := make([]money.Money, len(PriceBucketEdges))
+	for i, edge := range PriceBucketEdges {
+		edges[i] = money.FromMajor(float64(edge), params.Currency)
+	}
+	cases := make([]string, 0, len(edges))
+	for i := len(edges) - 1; i >= 0; i-- {
+		cases = append(cases, fmt.Sprintf("WHEN products.price >= %d THEN %d", edges[i].Amount, i))
+	}
+	var buckets []struct {
+		Bucket int
+		Count  int64
+	}
+	err = base(priceFacet).
+		Select("CASE " + strings.Join(cases, " ") + " ELSE 0 END AS bucket, COUNT(*) AS count").
+		Group("bucket").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int64, len(buckets))
+	for _, b := range buckets {
+		counts[b.Bucket] = b.Count
+	}
+	for i, min := range edges {
+		bucket := PriceFacet{Min: min, Count: counts[i]}
+		if i+1 < len(edges) {
+			max := edges[i+1]
+			bucket.Max = &max
+		}
+		result.Facets.Price = append(result.Facets.Price, bucket)
+	}
+
+	// Rating facet
+	selects := make([]string, len(RatingFacetSteps))
+	for i, step := range RatingFacetSteps {
+		selects[i] = fmt.Sprintf("COUNT(*) FILTER (WHERE products.rating_average >= %d) AS r%d", step, step)
+	}
+	ratingCounts := map[string]interface{}{}
+	if err := base(ratingFacet).Select(strings.Join(selects, ", ")).Take(&ratingCounts).Error; err != nil {
+		return nil, err
+	}
+	for _, step := range RatingFacetSteps {
+		result.Facets.Rating = append(result.Facets.Rating, RatingFacet{
+			MinRating: step,
+			Count:     toInt64(ratingCounts[fmt.Sprintf("r%d", step)]),
+		})
+	}
+
+	return result, nil
+}
+
+// searchIndexes are the indexes Search relies on
+var searchIndexes = []string{"idx_products_search_vector", "idx_products_name_trgm"}
+
+// Reindex rebuilds the search indexes with REINDEX CONCURRENTLY, which lets
+// writes go on while each index is rebuilt, and refreshes the planner
+// statistics. The search vector is a generated column and needs no update.
+func (r *ProductRepository) Reindex() error {
+	for _, index := range searchIndexes {
+		if err := r.DB.Exec("REINDEX INDEX CONCURRENTLY " + index).Error; err != nil {
+			return err
+		}
+	}
+	return r.DB.Exec("ANALYZE products").Error
+}
+
+// toInt64 converts a numeric column scanned into a map
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case float64:
+		return int64(math.Round(n))
+	}
+	return 0
+}
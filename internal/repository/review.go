@@ -1,10 +1,19 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/sajal/go-ecommerce/internal/models"
 	"gorm.io/gorm"
 )
 
+// ReviewSortOrders maps the public sort keys for review listings to SQL
+var ReviewSortOrders = map[string]string{
+	"newest":  "created_at DESC",
+	"rating":  "rating DESC, created_at DESC",
+	"helpful": "helpful_count DESC, created_at DESC",
+}
+
 type ReviewRepository struct {
 	DB *gorm.DB
 }
@@ -13,30 +22,77 @@ func NewReviewRepository(db *gorm.DB) *ReviewRepository {
 	return &ReviewRepository{DB: db}
 }
 
-func (r *ReviewRepository) Create(review *models.Review) error {
-	return r.DB.Create(review).Error
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *ReviewRepository) WithContext(ctx context.Context) *ReviewRepository {
+	return &ReviewRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// Create inserts the review and records events in the same transaction
+func (r *ReviewRepository) Create(review *models.Review, events ...models.OutboxEvent) error {
+	return withEvents(r.DB, events, func(tx *gorm.DB) error {
+		return tx.Create(review).Error
+	})
+}
+
+// publicAuthor loads only the name of a review's author, for listings
+// anyone can read
+func publicAuthor(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "name")
 }
 
 func (r *ReviewRepository) FindByID(id uint) (*models.Review, error) {
 	var review models.Review
-	err := r.DB.Preload("User").Preload("Product").First(&review, id).Error
+	err := r.DB.Preload("User").Preload("Product").Preload("Images").First(&review, id).Error
 	return &review, err
 }
 
 func (r *ReviewRepository) FindByProductID(productID uint) ([]models.Review, error) {
 	var reviews []models.Review
-	err := r.DB.Preload("User").Where("product_id = ?", productID).Find(&reviews).Error
+	err := r.DB.Preload("User", publicAuthor).Where("product_id = ?", productID).Find(&reviews).Error
 	return reviews, err
 }
 
+// FindByProductIDPaged returns a page of a product's reviews in the given
+// status, ordered by one of ReviewSortOrders, along with the total count
+func (r *ReviewRepository) FindByProductIDPaged(productID uint, status models.ReviewStatus, sort string, offset, limit int) ([]models.Review, int64, error) {
+	var reviews []models.Review
+	var total int64
+
+	query := r.DB.Model(&models.Review{}).Where("product_id = ? AND status = ?", productID, status)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order, ok := ReviewSortOrders[sort]
+	if !ok {
+		order = ReviewSortOrders["newest"]
+	}
+	err := query.Preload("User", publicAuthor).Preload("Images").Order(order).Offset(offset).Limit(limit).Find(&reviews).Error
+	return reviews, total, err
+}
+
+// FindByStatus returns a page of reviews in the given status, oldest first
+func (r *ReviewRepository) FindByStatus(status models.ReviewStatus, offset, limit int) ([]models.Review, int64, error) {
+	var reviews []models.Review
+	var total int64
+
+	query := r.DB.Model(&models.Review{}).Where("status = ?", status)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").Preload("Product").Preload("Images").Order("created_at ASC").Offset(offset).Limit(limit).Find(&reviews).Error
+	return reviews, total, err
+}
+
 func (r *ReviewRepository) FindByUserID(userID uint) ([]models.Review, error) {
 	var reviews []models.Review
-	err := r.DB.Preload("Product").Where("user_id = ?", userID).Find(&reviews).Error
+	err := r.DB.Preload("Product").Preload("Images").Where("user_id = ?", userID).Find(&reviews).Error
 	return reviews, err
 }
 
 func (r *ReviewRepository) Update(review *models.Review) error {
-	return r.DB.Save(review).Error
+	return r.DB.Omit("User", "Product", "Images").Save(review).Error
 }
 
 func (r *ReviewRepository) Delete(id uint) error {
@@ -48,3 +104,50 @@ func (r *ReviewRepository) FindByUserAndProduct(userID, productID uint) (*models
 	err := r.DB.Where("user_id = ? AND product_id = ?", userID, productID).First(&review).Error
 	return &review, err
 }
+
+func (r *ReviewRepository) AddImage(image *models.ReviewImage) error {
+	return r.DB.Create(image).Error
+}
+
+func (r *ReviewRepository) FindImage(reviewID uint, filePath string) (*models.ReviewImage, error) {
+	var image models.ReviewImage
+	err := r.DB.Where("review_id = ? AND file_path = ?", reviewID, filePath).First(&image).Error
+	return &image, err
+}
+
+func (r *ReviewRepository) CountImages(reviewID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.ReviewImage{}).Where("review_id = ?", reviewID).Count(&count).Error
+	return count, err
+}
+
+// AddVote records a helpful vote and bumps the review's helpful count.
+// It reports false when the user had already voted.
+func (r *ReviewRepository) AddVote(reviewID, userID uint) (bool, error) {
+	added := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(models.ReviewVote{ReviewID: reviewID, UserID: userID}).FirstOrCreate(&models.ReviewVote{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		added = true
+		return tx.Model(&models.Review{}).Where("id = ?", reviewID).
+			UpdateColumn("helpful_count", gorm.Expr("helpful_count + 1")).Error
+	})
+	return added, err
+}
+
+// RemoveVote withdraws a helpful vote and lowers the review's helpful count
+func (r *ReviewRepository) RemoveVote(reviewID, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("review_id = ? AND user_id = ?", reviewID, userID).Delete(&models.ReviewVote{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Review{}).Where("id = ?", reviewID).
+			UpdateColumn("helpful_count", gorm.Expr("helpful_count - 1")).Error
+	})
+}
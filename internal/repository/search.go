@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// QueryStat summarizes how often a query was searched
+type QueryStat struct {
+	Query        string    `json:"query"`
+	Count        int64     `json:"count"`
+	LastSearched time.Time `json:"last_searched"`
+}
+
+type SearchRepository struct {
+	DB *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) *SearchRepository {
+	return &SearchRepository{DB: db}
+}
+
+func (r *SearchRepository) LogQuery(query *models.SearchQuery) error {
+	return r.DB.Create(query).Error
+}
+
+// PopularQueries returns the most searched queries since the given time
+// that found at least one product and were searched at least minCount times
+func (r *SearchRepository) PopularQueries(since time.Time, minCount int64, limit int) ([]QueryStat, error) {
+	var stats []QueryStat
+	err := r.DB.Model(&models.SearchQuery{}).
+		Select("query, COUNT(*) AS count, MAX(created_at) AS last_searched").
+		Where("created_at >= ? AND result_count > 0", since).
+		Group("query").
+		Having("COUNT(*) >= ?", minCount).
+		Order("count DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// ZeroResultQueries returns the queries since the given time that found
+// nothing, most frequent first
+func (r *SearchRepository) ZeroResultQueries(since time.Time, limit int) ([]QueryStat, error) {
+	var stats []QueryStat
+	err := r.DB.Model(&models.SearchQuery{}).
+		Select("query, COUNT(*) AS count, MAX(created_at) AS last_searched").
+		Where("created_at >= ? AND result_count = 0", since).
+		Group("query").
+		Order("count DESC, last_searched DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
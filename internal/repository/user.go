@@ -1,10 +1,22 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
 	"github.com/sajal/go-ecommerce/internal/models"
 	"gorm.io/gorm"
 )
 
+// UserFilter narrows an admin user listing; empty fields match everything
+type UserFilter struct {
+	Search string // Part of the name or email
+	Role   string
+	Status models.UserStatus
+}
+
 type UserRepository struct {
 	db *gorm.DB
 }
@@ -13,8 +25,16 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	return &UserRepository{db: r.db.WithContext(ctx)}
+}
+
+// Create inserts the user and records events in the same transaction
+func (r *UserRepository) Create(user *models.User, events ...models.OutboxEvent) error {
+	return withEvents(r.db, events, func(tx *gorm.DB) error {
+		return tx.Create(user).Error
+	})
 }
 
 func (r *UserRepository) FindByID(id uint) (*models.User, error) {
@@ -36,3 +56,89 @@ func (r *UserRepository) Update(user *models.User) error {
 func (r *UserRepository) Delete(id uint) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
+
+// FindFiltered returns one page of users matching the filter, newest first
+func (r *UserRepository) FindFiltered(filter UserFilter, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := r.db.Model(&models.User{})
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(name) LIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+// UpdateFields sets the given columns of a user
+func (r *UserRepository) UpdateFields(id uint, fields map[string]interface{}) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// CreateResetToken stores a password reset token, revoking the user's
+// earlier unused ones, and marks the user as having to reset the password
+// when required is set
+func (r *UserRepository) CreateResetToken(token *models.PasswordResetToken, required bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("expires_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if required {
+			if err := tx.Model(&models.User{}).Where("id = ?", token.UserID).
+				Update("password_reset_required", true).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// FindResetToken looks up an unused, unexpired reset token by its hash
+func (r *UserRepository) FindResetToken(hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, time.Now()).First(&token).Error
+	return &token, err
+}
+
+// ResetPassword stores the user's new password and uses up the token in one
+// transaction. The password is hashed by the User BeforeSave hook. It
+// reports false when the token was used in the meantime.
+func (r *UserRepository) ResetPassword(token *models.PasswordResetToken, password string) (bool, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the token first so it cannot be used twice
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var user models.User
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+		user.Password = password
+		user.PasswordResetRequired = false
+		return tx.Save(&user).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+type WebhookRepository struct {
+	DB *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{DB: db}
+}
+
+func (r *WebhookRepository) Create(endpoint *models.WebhookEndpoint) error {
+	return r.DB.Create(endpoint).Error
+}
+
+func (r *WebhookRepository) FindByID(id uint) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := r.DB.First(&endpoint, id).Error
+	return &endpoint, err
+}
+
+func (r *WebhookRepository) FindAll() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.DB.Order("id").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *WebhookRepository) FindActive() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.DB.Where("is_active = ?", true).Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *WebhookRepository) Update(endpoint *models.WebhookEndpoint) error {
+	return r.DB.Save(endpoint).Error
+}
+
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.DB.Delete(&models.WebhookEndpoint{}, id).Error
+}
+
+// RecordFailure counts a failed delivery and returns the new number of
+// consecutive failures
+func (r *WebhookRepository) RecordFailure(id uint) (int, error) {
+	var endpoint models.WebhookEndpoint
+	err := r.DB.Model(&endpoint).
+		Where("id = ?", id).
+		Update("failure_count", gorm.Expr("failure_count + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+	err = r.DB.Select("failure_count").First(&endpoint, id).Error
+	return endpoint.FailureCount, err
+}
+
+func (r *WebhookRepository) ResetFailures(id uint) error {
+	return r.DB.Model(&models.WebhookEndpoint{}).Where("id = ? AND failure_count <> 0", id).Update("failure_count", 0).Error
+}
+
+// Disable deactivates an endpoint that keeps failing
+func (r *WebhookRepository) Disable(id uint, reason string) error {
+	return r.DB.Model(&models.WebhookEndpoint{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_active":       false,
+		"disabled_at":     gorm.Expr("NOW()"),
+		"disabled_reason": reason,
+	}).Error
+}
+
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.DB.Create(delivery).Error
+}
+
+func (r *WebhookRepository) FindDeliveryByID(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.DB.First(&delivery, id).Error
+	return &delivery, err
+}
+
+func (r *WebhookRepository) FindDeliveries(endpointID uint, offset, limit int) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query := r.DB.Model(&models.WebhookDelivery{}).Where("endpoint_id = ?", endpointID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
+}
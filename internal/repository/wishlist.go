@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"gorm.io/gorm"
+)
+
+type WishlistRepository struct {
+	DB *gorm.DB
+}
+
+func NewWishlistRepository(db *gorm.DB) *WishlistRepository {
+	return &WishlistRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose statements run in ctx
+func (r *WishlistRepository) WithContext(ctx context.Context) *WishlistRepository {
+	return &WishlistRepository{DB: r.DB.WithContext(ctx)}
+}
+
+func (r *WishlistRepository) Create(wishlist *models.Wishlist) error {
+	return r.DB.Create(wishlist).Error
+}
+
+func (r *WishlistRepository) FindByID(id uint) (*models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := r.DB.Preload("Items.Product").First(&wishlist, id).Error
+	return &wishlist, err
+}
+
+func (r *WishlistRepository) FindByUserID(userID uint) ([]models.Wishlist, error) {
+	var wishlists []models.Wishlist
+	err := r.DB.Preload("Items.Product").Where("user_id = ?", userID).Order("id").Find(&wishlists).Error
+	return wishlists, err
+}
+
+func (r *WishlistRepository) FindByUserAndName(userID uint, name string) (*models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := r.DB.Preload("Items.Product").Where("user_id = ? AND name = ?", userID, name).First(&wishlist).Error
+	return &wishlist, err
+}
+
+func (r *WishlistRepository) FindByShareToken(token string) (*models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := r.DB.Preload("Items.Product").Where("share_token = ? AND is_public = ?", token, true).First(&wishlist).Error
+	return &wishlist, err
+}
+
+func (r *WishlistRepository) Update(wishlist *models.Wishlist) error {
+	return r.DB.Model(wishlist).Select("name", "is_public", "share_token").Updates(wishlist).Error
+}
+
+func (r *WishlistRepository) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("wishlist_id = ?", id).Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Wishlist{}, id).Error
+	})
+}
+
+func (r *WishlistRepository) AddItem(item *models.WishlistItem) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *WishlistRepository) RemoveItem(id uint) error {
+	return r.DB.Delete(&models.WishlistItem{}, id).Error
+}
+
+func (r *WishlistRepository) FindItem(wishlistID uint, productID uint) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	err := r.DB.Where("wishlist_id = ? AND product_id = ?", wishlistID, productID).First(&item).Error
+	return &item, err
+}
+
+func (r *WishlistRepository) FindItemByID(itemID uint, wishlistID uint) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	err := r.DB.Where("id = ? AND wishlist_id = ?", itemID, wishlistID).First(&item).Error
+	return &item, err
+}
+
+// FindWatcher returns the user when one of their wishlists still holds the
+// product and the product is still sold, or nil otherwise
+func (r *WishlistRepository) FindWatcher(userID, productID uint) (*models.User, error) {
+	var user models.User
+	err := r.DB.
+		Joins("JOIN wishlists ON wishlists.user_id = users.id AND wishlists.deleted_at IS NULL").
+		Joins("JOIN wishlist_items ON wishlist_items.wishlist_id = wishlists.id AND wishlist_items.deleted_at IS NULL").
+		Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL AND products.is_active").
+		Where("users.id = ? AND wishlist_items.product_id = ?", userID, productID).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &user, err
+}
+
+// FindUsersWatchingProduct returns the distinct owners of wishlists holding the product
+func (r *WishlistRepository) FindUsersWatchingProduct(productID uint) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Distinct("users.*").
+		Joins("JOIN wishlists ON wishlists.user_id = users.id AND wishlists.deleted_at IS NULL").
+		Joins("JOIN wishlist_items ON wishlist_items.wishlist_id = wishlists.id AND wishlist_items.deleted_at IS NULL").
+		Where("wishlist_items.product_id = ?", productID).
+		Find(&users).Error
+	return users, err
+}
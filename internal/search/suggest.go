@@ -0,0 +1,216 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Suggestion kinds
+const (
+	KindProduct  = "product"
+	KindCategory = "category"
+	KindQuery    = "query"
+)
+
+const (
+	// MinQueryCount is how many times a query must have been searched
+	// before it is suggested, so one-off searches are never shown to others
+	MinQueryCount = 3
+	// maxQueries caps the past queries counted in memory between rebuilds
+	maxQueries = 10000
+)
+
+// Suggestion is one autocomplete entry
+type Suggestion struct {
+	Kind  string `json:"kind"`
+	ID    uint   `json:"id,omitempty"`
+	Text  string `json:"text"`
+	Count int64  `json:"count,omitempty"` // Times a past query was searched
+}
+
+// Suggestions groups autocomplete entries by kind
+type Suggestions struct {
+	Products   []Suggestion `json:"products"`
+	Categories []Suggestion `json:"categories"`
+	Queries    []Suggestion `json:"queries"`
+}
+
+type entry struct {
+	key        string
+	suggestion Suggestion
+}
+
+// SuggestIndex is an in-memory prefix index over product names, category
+// names and popular queries. Every word of a name is indexed, so "shi"
+// matches "Blue Shirt". It is safe for concurrent use.
+type SuggestIndex struct {
+	mu      sync.RWMutex
+	entries []entry // Sorted by key
+	queries map[string]int64
+}
+
+func NewSuggestIndex() *SuggestIndex {
+	return &SuggestIndex{queries: map[string]int64{}}
+}
+
+// Normalize lowercases text and collapses punctuation and whitespace, the
+// form both keys and lookups are compared in
+func Normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// keys returns the name and every suffix of it starting at a word boundary
+func keys(text string) []string {
+	words := strings.Fields(Normalize(text))
+	out := make([]string, 0, len(words))
+	for i := range words {
+		out = append(out, strings.Join(words[i:], " "))
+	}
+	return out
+}
+
+func entriesFor(s Suggestion) []entry {
+	var out []entry
+	for _, k := range keys(s.Text) {
+		out = append(out, entry{key: k, suggestion: s})
+	}
+	return out
+}
+
+// Replace swaps the whole index for the given products, categories and
+// query counts
+func (idx *SuggestIndex) Replace(products, categories []Suggestion, queries map[string]int64) {
+	var entries []entry
+	for _, s := range products {
+		entries = append(entries, entriesFor(s)...)
+	}
+	for _, s := range categories {
+		entries = append(entries, entriesFor(s)...)
+	}
+	normalized := make(map[string]int64, len(queries))
+	for q, n := range queries {
+		if q = Normalize(q); q != "" {
+			normalized[q] += n
+		}
+	}
+	for q, n := range normalized {
+		if n >= MinQueryCount {
+			entries = append(entries, entry{key: q, suggestion: Suggestion{Kind: KindQuery, Text: q, Count: n}})
+		}
+	}
+	sortEntries(entries)
+
+	idx.mu.Lock()
+	idx.entries = entries
+	idx.queries = normalized
+	idx.mu.Unlock()
+}
+
+// Put adds or replaces a product or category
+func (idx *SuggestIndex) Put(s Suggestion) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries = append(idx.without(s.Kind, s.ID), entriesFor(s)...)
+	sortEntries(idx.entries)
+}
+
+// Remove drops a product or category
+func (idx *SuggestIndex) Remove(kind string, id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries = idx.without(kind, id)
+}
+
+// RecordQuery counts a search that found results. The query becomes a
+// suggestion once it has been searched MinQueryCount times. New queries are
+// not counted once maxQueries are; they are picked up by the next Replace.
+func (idx *SuggestIndex) RecordQuery(query string) {
+	q := Normalize(query)
+	if q == "" {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	count, known := idx.queries[q]
+	if !known && len(idx.queries) >= maxQueries {
+		return
+	}
+	count++
+	idx.queries[q] = count
+	if count < MinQueryCount {
+		return
+	}
+
+	i := sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].key >= q })
+	for ; i < len(idx.entries) && idx.entries[i].key == q; i++ {
+		if idx.entries[i].suggestion.Kind == KindQuery {
+			idx.entries[i].suggestion.Count = count
+			return
+		}
+	}
+	idx.entries = append(idx.entries, entry{key: q, suggestion: Suggestion{Kind: KindQuery, Text: q, Count: count}})
+	sortEntries(idx.entries)
+}
+
+// without returns the entries not belonging to the given item; the caller
+// holds the write lock
+func (idx *SuggestIndex) without(kind string, id uint) []entry {
+	kept := idx.entries[:0:0]
+	for _, e := range idx.entries {
+		if e.suggestion.Kind != kind || e.suggestion.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// Suggest returns up to limit suggestions of each kind whose indexed text
+// starts with prefix. Popular queries come first among queries.
+func (idx *SuggestIndex) Suggest(prefix string, limit int) Suggestions {
+	result := Suggestions{Products: []Suggestion{}, Categories: []Suggestion{}, Queries: []Suggestion{}}
+	p := Normalize(prefix)
+	if p == "" {
+		return result
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := map[Suggestion]bool{}
+	i := sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].key >= p })
+	for ; i < len(idx.entries) && strings.HasPrefix(idx.entries[i].key, p); i++ {
+		s := idx.entries[i].suggestion
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		switch s.Kind {
+		case KindProduct:
+			if len(result.Products) < limit {
+				result.Products = append(result.Products, s)
+			}
+		case KindCategory:
+			if len(result.Categories) < limit {
+				result.Categories = append(result.Categories, s)
+			}
+		case KindQuery:
+			result.Queries = append(result.Queries, s)
+		}
+	}
+
+	sort.SliceStable(result.Queries, func(i, j int) bool { return result.Queries[i].Count > result.Queries[j].Count })
+	if len(result.Queries) > limit {
+		result.Queries = result.Queries[:limit]
+	}
+	return result
+}
+
+func sortEntries(entries []entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+}
@@ -1,22 +1,28 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/sajal/go-ecommerce/internal/models"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 )
 
 type AddressService struct {
-	repo *repository.AddressRepository
+	repo       *repository.AddressRepository
+	normalizer AddressNormalizer
 }
 
-func NewAddressService(repo *repository.AddressRepository) *AddressService {
-	return &AddressService{repo: repo}
+func NewAddressService(repo *repository.AddressRepository, normalizer AddressNormalizer) *AddressService {
+	return &AddressService{
+		repo:       repo,
+		normalizer: normalizer,
+	}
 }
 
-func (s *AddressService) CreateAddress(address *models.Address) error {
-	// Validate address data
+// validateAddress checks the fields every stored address must have
+func validateAddress(address *models.Address) error {
 	if address.Street == "" {
 		return errors.New("street is required")
 	}
@@ -38,102 +44,155 @@ func (s *AddressService) CreateAddress(address *models.Address) error {
 	if address.Type != "shipping" && address.Type != "billing" {
 		return errors.New("address type must be either shipping or billing")
 	}
+	return nil
+}
 
-	// If this is the first address, set it as default
-	addresses, err := s.repo.FindByUserID(address.UserID)
-	if err != nil {
+// ValidateAddress checks required fields and runs the configured normalizer
+func (s *AddressService) ValidateAddress(address *models.Address) error {
+	if err := validateAddress(address); err != nil {
+		return err
+	}
+	if s.normalizer != nil {
+		return s.normalizer.Normalize(address)
+	}
+	return nil
+}
+
+func (s *AddressService) CreateAddress(ctx context.Context, address *models.Address) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.CreateAddress")
+	defer func() { telemetry.EndSpan(span, err) }()
+	repo := s.repo.WithContext(ctx)
+
+	// Validate address data
+	if err := s.ValidateAddress(address); err != nil {
 		return err
 	}
-	if len(addresses) == 0 {
+	if address.UserID == nil {
+		return errors.New("user is required")
+	}
+
+	// If this is the first address of its type, set it as default
+	if _, err := repo.FindDefault(*address.UserID, address.Type); err != nil {
 		address.IsDefault = true
 	}
 
-	return s.repo.Create(address)
+	return repo.Create(address)
 }
 
-func (s *AddressService) GetAddress(id uint) (*models.Address, error) {
-	return s.repo.FindByID(id)
+// GetAddress returns an address owned by the user
+func (s *AddressService) GetAddress(ctx context.Context, userID uint, id uint) (_ *models.Address, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.GetAddress")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	address, err := s.repo.WithContext(ctx).FindByID(id)
+	if err != nil {
+		return nil, errors.New("address not found")
+	}
+
+	// Check ownership
+	if !address.BelongsTo(userID) {
+		return nil, errors.New("address not found")
+	}
+
+	return address, nil
 }
 
-func (s *AddressService) GetUserAddresses(userID uint) ([]models.Address, error) {
-	return s.repo.FindByUserID(userID)
+// GetDefaultAddress returns the user's default address of the given type
+func (s *AddressService) GetDefaultAddress(ctx context.Context, userID uint, addressType string) (_ *models.Address, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.GetDefaultAddress")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	address, err := s.repo.WithContext(ctx).FindDefault(userID, addressType)
+	if err != nil {
+		return nil, errors.New("no default " + addressType + " address")
+	}
+	return address, nil
 }
 
-func (s *AddressService) UpdateAddress(address *models.Address) error {
-	// Check if address exists
-	existingAddress, err := s.repo.FindByID(address.ID)
+func (s *AddressService) GetUserAddresses(ctx context.Context, userID uint) (_ []models.Address, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.GetUserAddresses")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByUserID(userID)
+}
+
+func (s *AddressService) UpdateAddress(ctx context.Context, userID uint, address *models.Address) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.UpdateAddress")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	// Check if address exists and belongs to user
+	existingAddress, err := s.GetAddress(ctx, userID, address.ID)
 	if err != nil {
-		return errors.New("address not found")
+		return err
 	}
 
 	// Validate address data
-	if address.Street == "" {
-		return errors.New("street is required")
-	}
-	if address.City == "" {
-		return errors.New("city is required")
-	}
-	if address.State == "" {
-		return errors.New("state is required")
-	}
-	if address.Country == "" {
-		return errors.New("country is required")
-	}
-	if address.ZipCode == "" {
-		return errors.New("zip code is required")
-	}
-	if address.Type == "" {
-		return errors.New("address type is required")
-	}
-	if address.Type != "shipping" && address.Type != "billing" {
-		return errors.New("address type must be either shipping or billing")
+	if err := s.ValidateAddress(address); err != nil {
+		return err
 	}
 
 	// Preserve some fields
 	address.UserID = existingAddress.UserID
 	address.CreatedAt = existingAddress.CreatedAt
-	address.IsDefault = existingAddress.IsDefault
+	address.IsDefault = existingAddress.IsDefault && address.Type == existingAddress.Type
 
-	return s.repo.Update(address)
+	if err := s.repo.WithContext(ctx).Update(address); err != nil {
+		return err
+	}
+
+	// A default that changed type leaves its old type without a default
+	if existingAddress.IsDefault && !address.IsDefault {
+		return s.promoteDefault(ctx, userID, existingAddress.Type, address.ID)
+	}
+	return nil
 }
 
-func (s *AddressService) DeleteAddress(id uint) error {
-	// Check if address exists
-	address, err := s.repo.FindByID(id)
+func (s *AddressService) DeleteAddress(ctx context.Context, userID uint, id uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.DeleteAddress")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	// Check if address exists and belongs to user
+	address, err := s.GetAddress(ctx, userID, id)
 	if err != nil {
-		return errors.New("address not found")
+		return err
 	}
 
-	// If this is the default address, make another address default
+	if err := s.repo.WithContext(ctx).Delete(id); err != nil {
+		return err
+	}
+
+	// If this was the default address, make another address default
 	if address.IsDefault {
-		addresses, err := s.repo.FindByUserID(address.UserID)
-		if err != nil {
-			return err
-		}
+		return s.promoteDefault(ctx, userID, address.Type, id)
+	}
+	return nil
+}
 
-		// Find another address to make default
-		for _, addr := range addresses {
-			if addr.ID != id {
-				if err := s.repo.SetDefault(address.UserID, addr.ID); err != nil {
-					return err
-				}
-				break
-			}
-		}
+// promoteDefault makes the first remaining address of the type the default
+func (s *AddressService) promoteDefault(ctx context.Context, userID uint, addressType string, excludeID uint) error {
+	repo := s.repo.WithContext(ctx)
+	addresses, err := repo.FindByUserID(userID)
+	if err != nil {
+		return err
 	}
 
-	return s.repo.Delete(id)
+	for _, addr := range addresses {
+		if addr.ID != excludeID && addr.Type == addressType {
+			return repo.SetDefault(userID, addr.ID, addressType)
+		}
+	}
+	return nil
 }
 
-func (s *AddressService) SetDefaultAddress(userID uint, addressID uint) error {
+func (s *AddressService) SetDefaultAddress(ctx context.Context, userID uint, addressID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "AddressService.SetDefaultAddress")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if address exists and belongs to user
-	address, err := s.repo.FindByID(addressID)
+	address, err := s.GetAddress(ctx, userID, addressID)
 	if err != nil {
-		return errors.New("address not found")
-	}
-	if address.UserID != userID {
-		return errors.New("unauthorized")
+		return err
 	}
 
-	return s.repo.SetDefault(userID, addressID)
+	return s.repo.WithContext(ctx).SetDefault(userID, addressID, address.Type)
 }
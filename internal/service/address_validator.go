@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+)
+
+// AddressNormalizer cleans up an address in place and rejects addresses
+// that cannot be delivered to
+type AddressNormalizer interface {
+	Normalize(address *models.Address) error
+}
+
+// postalCodeFormats holds the postal code format per ISO 3166-1 alpha-2
+// country code. Countries not listed only need a non-empty code.
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BD": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-\d{3}$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-\d{4}$`),
+	"NL": regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// countryNames maps common spellings to ISO country codes
+var countryNames = map[string]string{
+	"AUSTRALIA":                "AU",
+	"BANGLADESH":               "BD",
+	"BRAZIL":                   "BR",
+	"CANADA":                   "CA",
+	"GERMANY":                  "DE",
+	"SPAIN":                    "ES",
+	"FRANCE":                   "FR",
+	"UNITED KINGDOM":           "GB",
+	"UK":                       "GB",
+	"INDIA":                    "IN",
+	"ITALY":                    "IT",
+	"JAPAN":                    "JP",
+	"NETHERLANDS":              "NL",
+	"UNITED STATES":            "US",
+	"UNITED STATES OF AMERICA": "US",
+	"USA":                      "US",
+}
+
+// PostalCodeValidator trims address fields, turns country names into ISO
+// codes and checks postal codes against the format of their country
+type PostalCodeValidator struct{}
+
+func NewPostalCodeValidator() *PostalCodeValidator {
+	return &PostalCodeValidator{}
+}
+
+func (v *PostalCodeValidator) Normalize(address *models.Address) error {
+	address.Street = strings.TrimSpace(address.Street)
+	address.City = strings.TrimSpace(address.City)
+	address.State = strings.TrimSpace(address.State)
+
+	country := strings.ToUpper(strings.TrimSpace(address.Country))
+	if code, ok := countryNames[country]; ok {
+		country = code
+	}
+	address.Country = country
+
+	zip := strings.ToUpper(strings.Join(strings.Fields(address.ZipCode), " "))
+	switch country {
+	case "CA", "GB", "NL":
+		zip = spacePostalCode(zip, country)
+	}
+	address.ZipCode = zip
+
+	format, ok := postalCodeFormats[country]
+	if !ok {
+		if zip == "" {
+			return errors.New("zip code is required")
+		}
+		return nil
+	}
+	if !format.MatchString(zip) {
+		return fmt.Errorf("invalid zip code %q for country %s", zip, country)
+	}
+	return nil
+}
+
+// spacePostalCode inserts the separator space customers often leave out of
+// Canadian, British and Dutch postal codes
+func spacePostalCode(zip string, country string) string {
+	compact := strings.ReplaceAll(zip, " ", "")
+	inward := 3
+	if country == "NL" {
+		inward = 2
+	}
+	if len(compact) <= inward {
+		return zip
+	}
+	return compact[:len(compact)-inward] + " " + compact[len(compact)-inward:]
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+// MaxReportLimit caps the rows of the top products and categories reports
+const MaxReportLimit = 100
+
+// SalesSummary reports the sales, refunds and customers of a date range
+type SalesSummary struct {
+	From      time.Time                `json:"from"`
+	To        time.Time                `json:"to"`
+	Sales     repository.SalesTotals   `json:"sales"`
+	Refunds   repository.RefundStats   `json:"refunds"`
+	Customers repository.CustomerStats `json:"customers"`
+}
+
+// AnalyticsService computes sales reports for admins. Cancelled and refunded
+// orders never count as sales.
+type AnalyticsService struct {
+	repo *repository.AnalyticsRepository
+}
+
+func NewAnalyticsService(repo *repository.AnalyticsRepository) *AnalyticsService {
+	return &AnalyticsService{repo: repo}
+}
+
+func validateRange(rng repository.DateRange) error {
+	if !rng.From.Before(rng.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// Summary reports the totals of a date range
+func (s *AnalyticsService) Summary(rng repository.DateRange) (*SalesSummary, error) {
+	if err := validateRange(rng); err != nil {
+		return nil, err
+	}
+
+	sales, err := s.repo.Totals(rng)
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := s.repo.Refunds(rng)
+	if err != nil {
+		return nil, err
+	}
+	customers, err := s.repo.Customers(rng)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SalesSummary{
+		From:      rng.From,
+		To:        rng.To,
+		Sales:     *sales,
+		Refunds:   *refunds,
+		Customers: *customers,
+	}, nil
+}
+
+// SalesOverTime reports revenue, order count and average order value per
+// day, week or month
+func (s *AnalyticsService) SalesOverTime(rng repository.DateRange, interval string) ([]repository.SalesBucket, error) {
+	if err := validateRange(rng); err != nil {
+		return nil, err
+	}
+	switch interval {
+	case repository.IntervalDay, repository.IntervalWeek, repository.IntervalMonth:
+	default:
+		return nil, errors.New("interval must be day, week or month")
+	}
+	return s.repo.SalesByPeriod(rng, interval)
+}
+
+// TopProducts reports the best-selling products by revenue
+func (s *AnalyticsService) TopProducts(rng repository.DateRange, limit int) ([]repository.ProductSales, error) {
+	if err := validateRange(rng); err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > MaxReportLimit {
+		return nil, errors.New("invalid limit")
+	}
+	return s.repo.TopProducts(rng, limit)
+}
+
+// TopCategories reports the best-selling categories by revenue
+func (s *AnalyticsService) TopCategories(rng repository.DateRange, limit int) ([]repository.CategorySales, error) {
+	if err := validateRange(rng); err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > MaxReportLimit {
+		return nil, errors.New("invalid limit")
+	}
+	return s.repo.TopCategories(rng, limit)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/storage"
+)
+
+// auditIgnoredFields change on every write and would drown the real changes
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// auditRedactedFields are recorded as changed without their values
+var auditRedactedFields = map[string]bool{
+	"password": true,
+	"secret":   true,
+	"token":    true,
+}
+
+// AuditRedacted replaces the values of redacted fields
+const AuditRedacted = "[redacted]"
+
+// AuditDiff compares the JSON fields of two versions of a record and returns
+// the ones that differ. A nil before records a creation and a nil after a
+// deletion.
+func AuditDiff(before, after interface{}) map[string]models.AuditChange {
+	from, to := auditFields(before), auditFields(after)
+
+	changes := make(map[string]models.AuditChange)
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			to[key] = nil
+		}
+	}
+	for key, value := range to {
+		if auditIgnoredFields[key] || reflect.DeepEqual(from[key], value) {
+			continue
+		}
+		change := models.AuditChange{From: from[key], To: value}
+		if auditRedactedFields[key] {
+			change = models.AuditChange{From: AuditRedacted, To: AuditRedacted}
+		}
+		changes[key] = change
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// AuditService records administrative and security-sensitive actions and
+// archives entries past the retention period
+type AuditService struct {
+	repo  *repository.AuditRepository
+	store storage.Store
+
+	// Retention is how long entries are kept; zero keeps them forever
+	Retention time.Duration
+	// BatchSize is how many entries are archived per file
+	BatchSize int
+
+	wg sync.WaitGroup
+}
+
+func NewAuditService(repo *repository.AuditRepository, store storage.Store, retention time.Duration) *AuditService {
+	return &AuditService{
+		repo:      repo,
+		store:     store,
+		Retention: retention,
+		BatchSize: 5000,
+	}
+}
+
+// Record appends an entry to the audit log
+func (s *AuditService) Record(entry *models.AuditLog) error {
+	return s.repo.Create(entry)
+}
+
+// ListEntries returns one page of entries, newest first
+func (s *AuditService) ListEntries(filter repository.AuditFilter, page, pageSize int) ([]models.AuditLog, int64, error) {
+	return s.repo.FindFiltered(filter, (page-1)*pageSize, pageSize)
+}
+
+// ExportEntries writes the entries matching the filter as JSON lines, oldest
+// first
+func (s *AuditService) ExportEntries(filter repository.AuditFilter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	var afterID uint
+	for {
+		entries, err := s.repo.FindAfter(filter, afterID, s.BatchSize)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			if err := enc.Encode(&entries[i]); err != nil {
+				return err
+			}
+		}
+		if len(entries) < s.BatchSize {
+			return nil
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+}
+
+// Prune archives the entries older than the retention period to JSON lines
+// files under audit/ in the store, then deletes them
+func (s *AuditService) Prune() (int, error) {
+	if s.Retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-s.Retention)
+	total := 0
+	for {
+		n, err := s.repo.PruneBefore(cutoff, s.BatchSize, s.archive)
+		total += n
+		if err != nil || n < s.BatchSize {
+			return total, err
+		}
+	}
+}
+
+// archive writes a batch of entries to one file named after their first
+// day and ID range, so archiving the same batch twice overwrites the file
+func (s *AuditService) archive(entries []models.AuditLog) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	first, last := entries[0], entries[len(entries)-1]
+	name := fmt.Sprintf("audit/%s-%d-%d.jsonl", first.CreatedAt.UTC().Format("2006-01-02"), first.ID, last.ID)
+	_, err := s.store.Save(name, buf.Bytes())
+	return err
+}
+
+// Start prunes the log hourly until ctx is cancelled
+func (s *AuditService) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.run(ctx)
+}
+
+// Wait blocks until the pruning loop has stopped
+func (s *AuditService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *AuditService) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Prune(); err != nil {
+			log.Printf("Failed to prune the audit log: %v", err)
+		} else if n > 0 {
+			log.Printf("Archived and pruned %d audit log entries", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
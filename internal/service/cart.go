@@ -1,61 +1,291 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 )
 
+// CartKey identifies a cart either by its owner or, for guests, by its token
+type CartKey struct {
+	UserID uint
+	Token  string
+}
+
+// IsGuest reports whether the key refers to an anonymous cart
+func (k CartKey) IsGuest() bool {
+	return k.UserID == 0
+}
+
+// Reasons reported when a guest cart is merged into a user's cart
+const (
+	MergeReasonCombined     = "combined_with_existing_item"
+	MergeReasonStockLimited = "limited_by_stock"
+	MergeReasonUnavailable  = "product_unavailable"
+)
+
+// CartMergeAdjustment describes a guest cart line that could not be merged as-is
+type CartMergeAdjustment struct {
+	ProductID uint   `json:"product_id"`
+	Requested int    `json:"requested"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+}
+
 type CartService struct {
 	repo        *repository.CartRepository
 	productRepo *repository.ProductRepository
+	currency    string // Base currency cart totals are kept in
 }
 
-func NewCartService(repo *repository.CartRepository, productRepo *repository.ProductRepository) *CartService {
+func NewCartService(repo *repository.CartRepository, productRepo *repository.ProductRepository, currency string) *CartService {
 	return &CartService{
 		repo:        repo,
 		productRepo: productRepo,
+		currency:    currency,
+	}
+}
+
+// newToken returns a random opaque token for guest carts and share links
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ErrCartRequiresAcknowledgement is returned at checkout while cart lines
+// carry warnings the customer has not acknowledged yet
+var ErrCartRequiresAcknowledgement = errors.New("cart has changed, review and acknowledge the changes before checkout")
+
+// GetCart returns the repriced cart for the key. Users get a cart created on
+// demand; guest carts must already exist.
+func (s *CartService) GetCart(ctx context.Context, key CartKey) (_ *models.Cart, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.GetCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	cart, err := s.findCart(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reprice(ctx, cart); err != nil {
+		return nil, err
 	}
+	return cart, nil
 }
 
-func (s *CartService) GetCart(userID uint) (*models.Cart, error) {
-	cart, err := s.repo.FindByUserID(userID)
+func (s *CartService) findCart(ctx context.Context, key CartKey) (*models.Cart, error) {
+	if key.IsGuest() {
+		if key.Token == "" {
+			return nil, errors.New("cart not found")
+		}
+		cart, err := s.repo.WithContext(ctx).FindByToken(key.Token)
+		if err != nil {
+			return nil, errors.New("cart not found")
+		}
+		return cart, nil
+	}
+
+	cart, err := s.repo.WithContext(ctx).FindByUserID(key.UserID)
 	if err != nil {
 		// Create new cart if not exists
-		cart = &models.Cart{UserID: userID}
-		if err := s.repo.Create(cart); err != nil {
+		userID := key.UserID
+		cart = &models.Cart{UserID: &userID}
+		if err := s.repo.WithContext(ctx).Create(cart); err != nil {
 			return nil, err
 		}
 	}
 	return cart, nil
 }
 
-func (s *CartService) AddToCart(userID uint, productID uint, quantity int) error {
-	// Check if product exists
-	product, err := s.productRepo.FindByID(productID)
+// reprice brings every line up to the current catalog price, flags lines
+// whose product changed since it was added and recomputes the cart total.
+// Price changes are remembered in PreviousPrice until acknowledged; stock
+// and availability problems are derived from the catalog on every call.
+func (s *CartService) reprice(ctx context.Context, cart *models.Cart) error {
+	// Products keep the currency they were priced in, which is not the
+	// configured base once that changes, so the total takes the currency
+	// of the first line
+	var total money.Money
+	cart.RequiresAcknowledgement = false
+
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		item.Warnings = nil
+		changed := false
+
+		// Soft-deleted products are not preloaded
+		product := item.Product
+		switch {
+		case product.ID == 0:
+			item.Warnings = append(item.Warnings, models.CartItemWarning{
+				Code:    models.CartWarningRemoved,
+				Message: "This product is no longer sold",
+			})
+		case !product.IsActive:
+			item.Warnings = append(item.Warnings, models.CartItemWarning{
+				Code:    models.CartWarningUnavailable,
+				Message: "This product is currently unavailable",
+			})
+		default:
+			changed = item.SetPrice(product.Price)
+			if item.PreviousPrice != nil {
+				item.Warnings = append(item.Warnings, models.CartItemWarning{
+					Code:    models.CartWarningPriceChanged,
+					Message: fmt.Sprintf("Price changed from %s to %s", item.PreviousPrice, item.Price),
+				})
+			}
+			if item.Quantity > product.Stock {
+				item.Warnings = append(item.Warnings, models.CartItemWarning{
+					Code:    models.CartWarningExceedsStock,
+					Message: fmt.Sprintf("Only %d left in stock", product.Stock),
+				})
+			}
+
+			subtotal := item.Price.Mul(int64(item.Quantity))
+			if !item.Subtotal.Equal(subtotal) {
+				item.Subtotal = subtotal
+				changed = true
+			}
+			var err error
+			if total, err = total.AddChecked(item.Subtotal); err != nil {
+				return fmt.Errorf("cart has products priced in different currencies: %w", err)
+			}
+		}
+
+		if len(item.Warnings) > 0 {
+			cart.RequiresAcknowledgement = true
+		}
+		if changed {
+			if err := s.repo.WithContext(ctx).UpdateItem(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	if total.Currency == "" {
+		total = money.Zero(s.currency)
+	}
+	stored := cart.Total
+	cart.Total = total
+	if stored.Amount != total.Amount && cart.ID != 0 {
+		return s.repo.WithContext(ctx).UpdateTotal(cart.ID, total)
+	}
+	return nil
+}
+
+// AcknowledgeChanges accepts the warnings on the cart: new prices are kept,
+// unavailable lines are removed and quantities are reduced to what is in
+// stock. The resulting cart can be checked out.
+func (s *CartService) AcknowledgeChanges(ctx context.Context, key CartKey) (_ *models.Cart, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.AcknowledgeChanges")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	cart, err := s.GetCart(ctx, key)
 	if err != nil {
-		return errors.New("product not found")
+		return nil, err
 	}
 
-	// Check stock
-	if product.Stock < quantity {
-		return errors.New("insufficient stock")
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		if len(item.Warnings) == 0 {
+			continue
+		}
+
+		product := item.Product
+		if product.ID == 0 || !product.IsActive || product.Stock <= 0 {
+			if err := s.repo.WithContext(ctx).RemoveItem(item.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if item.Quantity > product.Stock {
+			item.Quantity = product.Stock
+			item.Subtotal = item.Price.Mul(int64(item.Quantity))
+		}
+		item.PreviousPrice = nil
+		if err := s.repo.WithContext(ctx).UpdateItem(item); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetCart(ctx, key)
+}
+
+// GetOrCreateCart behaves like GetCart but starts a new guest cart when the
+// key carries no token or an unknown one.
+func (s *CartService) GetOrCreateCart(ctx context.Context, key CartKey) (_ *models.Cart, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.GetOrCreateCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	cart, err := s.GetCart(ctx, key)
+	if err == nil || !key.IsGuest() {
+		return cart, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	cart = &models.Cart{Token: token}
+	if err := s.repo.WithContext(ctx).Create(cart); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// AddToCart adds a product to the cart and returns the cart it was added to,
+// which for a new guest carries the freshly issued token.
+func (s *CartService) AddToCart(ctx context.Context, key CartKey, productID uint, quantity int) (_ *models.Cart, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.AddToCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be greater than 0")
+	}
+
+	// Check if product exists
+	product, err := s.productRepo.WithContext(ctx).FindByID(productID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+	if !product.IsActive {
+		return nil, errors.New("product is not available")
 	}
 
 	// Get or create cart
-	cart, err := s.GetCart(userID)
+	cart, err := s.GetOrCreateCart(ctx, key)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check if item already exists in cart
-	existingItem, err := s.repo.FindCartItem(cart.ID, productID)
+	existingItem, err := s.repo.WithContext(ctx).FindCartItem(cart.ID, productID)
 	if err == nil {
+		// Check stock
+		if product.Stock < existingItem.Quantity+quantity {
+			return nil, errors.New("insufficient stock")
+		}
+
 		// Update quantity if item exists
 		existingItem.Quantity += quantity
-		existingItem.Subtotal = float64(existingItem.Quantity) * existingItem.Price
-		return s.repo.UpdateItem(existingItem)
+		existingItem.SetPrice(product.Price)
+		existingItem.Subtotal = existingItem.Price.Mul(int64(existingItem.Quantity))
+		return cart, s.repo.WithContext(ctx).UpdateItem(existingItem)
+	}
+
+	// Check stock
+	if product.Stock < quantity {
+		return nil, errors.New("insufficient stock")
 	}
 
 	// Add new item
@@ -64,27 +294,34 @@ func (s *CartService) AddToCart(userID uint, productID uint, quantity int) error
 		ProductID: productID,
 		Quantity:  quantity,
 		Price:     product.Price,
-		Subtotal:  float64(quantity) * product.Price,
+		Subtotal:  product.Price.Mul(int64(quantity)),
 	}
 
-	return s.repo.AddItem(item)
+	return cart, s.repo.WithContext(ctx).AddItem(item)
 }
 
-func (s *CartService) UpdateCartItem(userID uint, itemID uint, quantity int) error {
+func (s *CartService) UpdateCartItem(ctx context.Context, key CartKey, itemID uint, quantity int) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.UpdateCartItem")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if quantity <= 0 {
+		return errors.New("quantity must be greater than 0")
+	}
+
 	// Get cart
-	cart, err := s.GetCart(userID)
+	cart, err := s.GetCart(ctx, key)
 	if err != nil {
 		return err
 	}
 
 	// Get item
-	item, err := s.repo.FindCartItemByID(itemID, cart.ID)
+	item, err := s.repo.WithContext(ctx).FindCartItemByID(itemID, cart.ID)
 	if err != nil {
 		return errors.New("item not found")
 	}
 
 	// Check stock
-	product, err := s.productRepo.FindByID(item.ProductID)
+	product, err := s.productRepo.WithContext(ctx).FindByID(item.ProductID)
 	if err != nil {
 		return errors.New("product not found")
 	}
@@ -94,26 +331,149 @@ func (s *CartService) UpdateCartItem(userID uint, itemID uint, quantity int) err
 
 	// Update item
 	item.Quantity = quantity
-	item.Subtotal = float64(quantity) * item.Price
-	return s.repo.UpdateItem(item)
+	item.Subtotal = item.Price.Mul(int64(quantity))
+	return s.repo.WithContext(ctx).UpdateItem(item)
 }
 
-func (s *CartService) RemoveFromCart(userID uint, itemID uint) error {
+func (s *CartService) RemoveFromCart(ctx context.Context, key CartKey, itemID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.RemoveFromCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Get cart
-	cart, err := s.GetCart(userID)
+	cart, err := s.GetCart(ctx, key)
 	if err != nil {
 		return err
 	}
 
 	// Check if item exists in cart
-	_, err = s.repo.FindCartItemByID(itemID, cart.ID)
+	_, err = s.repo.WithContext(ctx).FindCartItemByID(itemID, cart.ID)
 	if err != nil {
 		return errors.New("item not found")
 	}
 
-	return s.repo.RemoveItem(itemID)
+	return s.repo.WithContext(ctx).RemoveItem(itemID)
 }
 
-func (s *CartService) ClearCart(userID uint) error {
-	return s.repo.ClearCart(userID)
+func (s *CartService) ClearCart(ctx context.Context, key CartKey) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.ClearCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	cart, err := s.GetCart(ctx, key)
+	if err != nil {
+		return err
+	}
+	return s.repo.WithContext(ctx).ClearItems(cart.ID)
+}
+
+// MergeGuestCart moves the items of the guest cart identified by token into
+// the user's cart and deletes the guest cart, all in one transaction.
+// Quantities of products already in the user's cart are added together and
+// capped at available stock; products that are inactive or deleted are
+// dropped. Every line that did not merge unchanged is reported back.
+func (s *CartService) MergeGuestCart(ctx context.Context, token string, userID uint) (_ []CartMergeAdjustment, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CartService.MergeGuestCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	adjustments := []CartMergeAdjustment{}
+	if token == "" {
+		return adjustments, nil
+	}
+
+	userCart, err := s.findCart(ctx, CartKey{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := s.repo.WithContext(ctx).MergeGuestCart(token, userCart.ID, func(guest, user []models.CartItem) []*models.CartItem {
+		var lines []*models.CartItem
+		lines, adjustments = mergeLines(guest, user)
+		return lines
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !merged {
+		// Nothing to merge
+		return []CartMergeAdjustment{}, nil
+	}
+	return adjustments, nil
+}
+
+// mergeLines combines guest cart lines with the lines of the user's cart
+// under the rules of MergeGuestCart. It returns the user cart lines that
+// changed or are new, and the adjustments to report.
+func mergeLines(guest, user []models.CartItem) ([]*models.CartItem, []CartMergeAdjustment) {
+	adjustments := []CartMergeAdjustment{}
+	var changed []*models.CartItem
+
+	lines := make(map[uint]*models.CartItem, len(user))
+	for i := range user {
+		lines[user[i].ProductID] = &user[i]
+	}
+	touched := make(map[*models.CartItem]bool)
+
+	for _, guestItem := range guest {
+		product := guestItem.Product
+		if product.ID == 0 || !product.IsActive {
+			adjustments = append(adjustments, CartMergeAdjustment{
+				ProductID: guestItem.ProductID,
+				Requested: guestItem.Quantity,
+				Quantity:  0,
+				Reason:    MergeReasonUnavailable,
+			})
+			continue
+		}
+
+		existingItem, hasExisting := lines[product.ID]
+
+		current := 0
+		if hasExisting {
+			current = existingItem.Quantity
+		}
+		requested := current + guestItem.Quantity
+		quantity := requested
+		reason := ""
+		if hasExisting {
+			reason = MergeReasonCombined
+		}
+		if quantity > product.Stock {
+			quantity = product.Stock
+			reason = MergeReasonStockLimited
+		}
+		if reason != "" {
+			adjustments = append(adjustments, CartMergeAdjustment{
+				ProductID: product.ID,
+				Requested: requested,
+				Quantity:  quantity,
+				Reason:    reason,
+			})
+		}
+
+		var item *models.CartItem
+		switch {
+		case hasExisting:
+			if quantity <= current {
+				continue
+			}
+			item = existingItem
+			item.Quantity = quantity
+			item.SetPrice(product.Price)
+			item.Subtotal = product.Price.Mul(int64(quantity))
+		case quantity > 0:
+			item = &models.CartItem{
+				ProductID: product.ID,
+				Quantity:  quantity,
+				Price:     product.Price,
+				Subtotal:  product.Price.Mul(int64(quantity)),
+			}
+			lines[product.ID] = item
+		default:
+			continue
+		}
+		if !touched[item] {
+			touched[item] = true
+			changed = append(changed, item)
+		}
+	}
+	return changed, adjustments
 }
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+func cartProduct(id uint, price int64, stock int) models.Product {
+	return models.Product{ID: id, Price: money.New(price, "USD"), Stock: stock, IsActive: true}
+}
+
+func cartLine(id uint, product models.Product, quantity int) models.CartItem {
+	return models.CartItem{
+		ID:        id,
+		ProductID: product.ID,
+		Product:   product,
+		Quantity:  quantity,
+		Price:     product.Price,
+		Subtotal:  product.Price.Mul(int64(quantity)),
+	}
+}
+
+func TestMergeLinesCombinesDuplicateProducts(t *testing.T) {
+	product := cartProduct(1, 500, 10)
+	guest := []models.CartItem{cartLine(0, product, 2)}
+	user := []models.CartItem{cartLine(7, product, 3)}
+
+	lines, adjustments := mergeLines(guest, user)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	if lines[0].ID != 7 || lines[0].Quantity != 5 {
+		t.Errorf("line = id %d quantity %d, want id 7 quantity 5", lines[0].ID, lines[0].Quantity)
+	}
+	if !lines[0].Subtotal.Equal(money.New(2500, "USD")) {
+		t.Errorf("subtotal = %s, want 25.00", lines[0].Subtotal)
+	}
+	want := CartMergeAdjustment{ProductID: 1, Requested: 5, Quantity: 5, Reason: MergeReasonCombined}
+	if len(adjustments) != 1 || adjustments[0] != want {
+		t.Errorf("adjustments = %+v, want [%+v]", adjustments, want)
+	}
+}
+
+func TestMergeLinesRecordsPriceChange(t *testing.T) {
+	product := cartProduct(1, 600, 10)
+	guest := []models.CartItem{cartLine(0, product, 1)}
+	line := cartLine(7, product, 1)
+	line.Price = money.New(500, "USD")
+	user := []models.CartItem{line}
+
+	lines, _ := mergeLines(guest, user)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	if !lines[0].Price.Equal(money.New(600, "USD")) {
+		t.Errorf("price = %s, want 6.00", lines[0].Price)
+	}
+	if lines[0].PreviousPrice == nil || !lines[0].PreviousPrice.Equal(money.New(500, "USD")) {
+		t.Errorf("previous price = %v, want 5.00", lines[0].PreviousPrice)
+	}
+}
+
+func TestMergeLinesLimitsToStock(t *testing.T) {
+	product := cartProduct(1, 500, 4)
+	guest := []models.CartItem{cartLine(0, product, 3)}
+	user := []models.CartItem{cartLine(7, product, 2)}
+
+	lines, adjustments := mergeLines(guest, user)
+	if len(lines) != 1 || lines[0].Quantity != 4 {
+		t.Fatalf("lines = %+v, want one line of 4", lines)
+	}
+	want := CartMergeAdjustment{ProductID: 1, Requested: 5, Quantity: 4, Reason: MergeReasonStockLimited}
+	if len(adjustments) != 1 || adjustments[0] != want {
+		t.Errorf("adjustments = %+v, want [%+v]", adjustments, want)
+	}
+}
+
+func TestMergeLinesLeavesLineAtStockUntouched(t *testing.T) {
+	product := cartProduct(1, 500, 2)
+	guest := []models.CartItem{cartLine(0, product, 1)}
+	user := []models.CartItem{cartLine(7, product, 2)}
+
+	lines, adjustments := mergeLines(guest, user)
+	if len(lines) != 0 {
+		t.Errorf("got %d lines to write, want none", len(lines))
+	}
+	if len(adjustments) != 1 || adjustments[0].Reason != MergeReasonStockLimited {
+		t.Errorf("adjustments = %+v, want one stock limit", adjustments)
+	}
+}
+
+func TestMergeLinesAddsNewProducts(t *testing.T) {
+	product := cartProduct(2, 300, 10)
+	guest := []models.CartItem{cartLine(0, product, 2), cartLine(0, product, 1)}
+
+	lines, adjustments := mergeLines(guest, nil)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	if lines[0].ID != 0 || lines[0].Quantity != 3 {
+		t.Errorf("line = id %d quantity %d, want new line of 3", lines[0].ID, lines[0].Quantity)
+	}
+	if len(adjustments) != 1 || adjustments[0].Reason != MergeReasonCombined {
+		t.Errorf("adjustments = %+v, want the second line combined", adjustments)
+	}
+}
+
+func TestMergeLinesDropsUnavailableProducts(t *testing.T) {
+	inactive := cartProduct(3, 300, 10)
+	inactive.IsActive = false
+	deleted := models.CartItem{ProductID: 4, Quantity: 1}
+	guest := []models.CartItem{cartLine(0, inactive, 2), deleted}
+
+	lines, adjustments := mergeLines(guest, nil)
+	if len(lines) != 0 {
+		t.Errorf("got %d lines, want none", len(lines))
+	}
+	want := []CartMergeAdjustment{
+		{ProductID: 3, Requested: 2, Quantity: 0, Reason: MergeReasonUnavailable},
+		{ProductID: 4, Requested: 1, Quantity: 0, Reason: MergeReasonUnavailable},
+	}
+	if len(adjustments) != len(want) {
+		t.Fatalf("adjustments = %+v, want %+v", adjustments, want)
+	}
+	for i := range want {
+		if adjustments[i] != want[i] {
+			t.Errorf("adjustment %d = %+v, want %+v", i, adjustments[i], want[i])
+		}
+	}
+}
+
+func TestCartItemSetPrice(t *testing.T) {
+	item := cartLine(1, cartProduct(1, 500, 10), 1)
+
+	if !item.SetPrice(money.New(600, "USD")) {
+		t.Error("SetPrice to a new price reported no change")
+	}
+	if item.PreviousPrice == nil || !item.PreviousPrice.Equal(money.New(500, "USD")) {
+		t.Fatalf("previous price = %v, want 5.00", item.PreviousPrice)
+	}
+
+	// A second change keeps the price the customer saw
+	item.SetPrice(money.New(700, "USD"))
+	if !item.PreviousPrice.Equal(money.New(500, "USD")) {
+		t.Errorf("previous price after second change = %s, want 5.00", item.PreviousPrice)
+	}
+
+	if !item.SetPrice(money.New(500, "USD")) {
+		t.Error("SetPrice back to the seen price reported no change")
+	}
+	if item.PreviousPrice != nil {
+		t.Errorf("previous price = %s after going back, want none", item.PreviousPrice)
+	}
+	if item.SetPrice(money.New(500, "USD")) {
+		t.Error("SetPrice to the same price reported a change")
+	}
+}
+
+// cartTest is a cart service over the test database with one product
+type cartTest struct {
+	service  *CartService
+	products *repository.ProductRepository
+	product  *models.Product
+}
+
+func newCartTest(t *testing.T) *cartTest {
+	db := testDB(t, "cart_items", "carts", "products", "categories")
+
+	category := &models.Category{Name: "Test"}
+	if err := db.Create(category).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := &models.Product{
+		Name:       "Test product",
+		Price:      money.New(500, "USD"),
+		Stock:      10,
+		CategoryID: category.ID,
+		SKU:        "TEST-1",
+		IsActive:   true,
+	}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	products := repository.NewProductRepository(db)
+	return &cartTest{
+		service:  NewCartService(repository.NewCartRepository(db), products, "USD"),
+		products: products,
+		product:  product,
+	}
+}
+
+func (c *cartTest) setPrice(t *testing.T, amount int64) {
+	t.Helper()
+	c.product.Price = money.New(amount, "USD")
+	if err := c.products.Update(c.product, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *cartTest) line(t *testing.T, cart *models.Cart) models.CartItem {
+	t.Helper()
+	if len(cart.Items) != 1 {
+		t.Fatalf("cart has %d lines, want 1", len(cart.Items))
+	}
+	return cart.Items[0]
+}
+
+func TestCartRepriceNeedsAcknowledgement(t *testing.T) {
+	c := newCartTest(t)
+	ctx := context.Background()
+
+	cart, err := c.service.AddToCart(ctx, CartKey{}, c.product.ID, 2)
+	if err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+	key := CartKey{Token: cart.Token}
+
+	c.setPrice(t, 600)
+	cart, err = c.service.GetCart(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := c.line(t, cart)
+	if !cart.RequiresAcknowledgement {
+		t.Error("repriced cart does not require acknowledgement")
+	}
+	if line.PreviousPrice == nil || !line.PreviousPrice.Equal(money.New(500, "USD")) {
+		t.Errorf("previous price = %v, want 5.00", line.PreviousPrice)
+	}
+	if !cart.Total.Equal(money.New(1200, "USD")) {
+		t.Errorf("total = %s, want 12.00", cart.Total)
+	}
+
+	cart, err = c.service.AcknowledgeChanges(ctx, key)
+	if err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	line = c.line(t, cart)
+	if cart.RequiresAcknowledgement || line.PreviousPrice != nil || len(line.Warnings) != 0 {
+		t.Errorf("acknowledged cart = %+v, want no warnings", cart)
+	}
+	if !line.Price.Equal(money.New(600, "USD")) {
+		t.Errorf("price = %s, want 6.00", line.Price)
+	}
+}
+
+func TestCartPriceBackClearsWarning(t *testing.T) {
+	c := newCartTest(t)
+	ctx := context.Background()
+
+	cart, err := c.service.AddToCart(ctx, CartKey{}, c.product.ID, 1)
+	if err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+	key := CartKey{Token: cart.Token}
+
+	c.setPrice(t, 400)
+	if _, err := c.service.GetCart(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	c.setPrice(t, 500)
+	cart, err = c.service.GetCart(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := c.line(t, cart); cart.RequiresAcknowledgement || line.PreviousPrice != nil {
+		t.Errorf("cart back at the seen price = %+v, want no warnings", cart)
+	}
+}
+
+func TestAddToCartKeepsPriceChangeForAcknowledgement(t *testing.T) {
+	c := newCartTest(t)
+	ctx := context.Background()
+
+	cart, err := c.service.AddToCart(ctx, CartKey{}, c.product.ID, 1)
+	if err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+	key := CartKey{Token: cart.Token}
+
+	c.setPrice(t, 600)
+	if _, err := c.service.AddToCart(ctx, key, c.product.ID, 1); err != nil {
+		t.Fatalf("add again: %v", err)
+	}
+	cart, err = c.service.GetCart(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := c.line(t, cart)
+	if line.Quantity != 2 {
+		t.Errorf("quantity = %d, want 2", line.Quantity)
+	}
+	if !cart.RequiresAcknowledgement || line.PreviousPrice == nil {
+		t.Errorf("cart = %+v, want the price change to need acknowledgement", cart)
+	}
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/sajal/go-ecommerce/internal/models"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 )
 
 type CategoryService struct {
@@ -15,32 +17,44 @@ func NewCategoryService(repo *repository.CategoryRepository) *CategoryService {
 	return &CategoryService{repo: repo}
 }
 
-func (s *CategoryService) CreateCategory(category *models.Category) error {
+func (s *CategoryService) CreateCategory(ctx context.Context, category *models.Category) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CategoryService.CreateCategory")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Validate category data
 	if category.Name == "" {
 		return errors.New("category name is required")
 	}
 
 	// Check if category with same name exists
-	_, err := s.repo.FindByName(category.Name)
+	_, err = s.repo.WithContext(ctx).FindByName(category.Name)
 	if err == nil {
 		return errors.New("category with this name already exists")
 	}
 
-	return s.repo.Create(category)
+	return s.repo.WithContext(ctx).Create(category)
 }
 
-func (s *CategoryService) GetCategory(id uint) (*models.Category, error) {
-	return s.repo.FindByID(id)
+func (s *CategoryService) GetCategory(ctx context.Context, id uint) (_ *models.Category, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CategoryService.GetCategory")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByID(id)
 }
 
-func (s *CategoryService) GetAllCategories() ([]models.Category, error) {
-	return s.repo.FindAll()
+func (s *CategoryService) GetAllCategories(ctx context.Context) (_ []models.Category, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CategoryService.GetAllCategories")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindAll()
 }
 
-func (s *CategoryService) UpdateCategory(category *models.Category) error {
+func (s *CategoryService) UpdateCategory(ctx context.Context, category *models.Category) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CategoryService.UpdateCategory")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if category exists
-	existingCategory, err := s.repo.FindByID(category.ID)
+	existingCategory, err := s.repo.WithContext(ctx).FindByID(category.ID)
 	if err != nil {
 		return errors.New("category not found")
 	}
@@ -52,7 +66,7 @@ func (s *CategoryService) UpdateCategory(category *models.Category) error {
 
 	// Check if another category with same name exists
 	if category.Name != existingCategory.Name {
-		duplicateCategory, err := s.repo.FindByName(category.Name)
+		duplicateCategory, err := s.repo.WithContext(ctx).FindByName(category.Name)
 		if err == nil && duplicateCategory.ID != category.ID {
 			return errors.New("category with this name already exists")
 		}
@@ -61,12 +75,15 @@ func (s *CategoryService) UpdateCategory(category *models.Category) error {
 	// Preserve some fields
 	category.CreatedAt = existingCategory.CreatedAt
 
-	return s.repo.Update(category)
+	return s.repo.WithContext(ctx).Update(category)
 }
 
-func (s *CategoryService) DeleteCategory(id uint) error {
+func (s *CategoryService) DeleteCategory(ctx context.Context, id uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CategoryService.DeleteCategory")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if category exists
-	category, err := s.repo.FindByID(id)
+	category, err := s.repo.WithContext(ctx).FindByID(id)
 	if err != nil {
 		return errors.New("category not found")
 	}
@@ -76,5 +93,5 @@ func (s *CategoryService) DeleteCategory(id uint) error {
 		return errors.New("cannot delete category with associated products")
 	}
 
-	return s.repo.Delete(id)
+	return s.repo.WithContext(ctx).Delete(id)
 }
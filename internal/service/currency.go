@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/currency"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// CurrencyService converts prices from the store's base currency into the
+// currencies customers can choose, applying per-product overrides and
+// rounding rules, and keeps the exchange rates up to date
+type CurrencyService struct {
+	repo     *repository.CurrencyRepository
+	source   currency.RateSource
+	base     string
+	codes    []string
+	rounding money.Rounding
+	refresh  time.Duration
+
+	mu    sync.RWMutex
+	rates map[string]float64
+	wg    sync.WaitGroup
+}
+
+// NewCurrencyService creates the service. The base currency is always
+// supported; a refresh interval of 0 only fetches rates on request.
+func NewCurrencyService(repo *repository.CurrencyRepository, source currency.RateSource, base string, supported []string, rounding money.Rounding, refresh time.Duration) *CurrencyService {
+	codes := []string{base}
+	for _, code := range supported {
+		if code != base {
+			codes = append(codes, code)
+		}
+	}
+	return &CurrencyService{
+		repo:     repo,
+		source:   source,
+		base:     base,
+		codes:    codes,
+		rounding: rounding,
+		refresh:  refresh,
+	}
+}
+
+// Base is the currency product prices are kept in
+func (s *CurrencyService) Base() string {
+	return s.base
+}
+
+// Supported lists the currencies customers can choose, base first
+func (s *CurrencyService) Supported() []string {
+	return s.codes
+}
+
+// Resolve normalizes a currency the customer asked for, defaulting to the
+// base currency
+func (s *CurrencyService) Resolve(code string) (string, error) {
+	code = currency.Normalize(code)
+	if code == "" {
+		return s.base, nil
+	}
+	for _, c := range s.codes {
+		if c == code {
+			return code, nil
+		}
+	}
+	return "", ErrUnsupportedCurrency
+}
+
+// Rate returns how many units of code one unit of the base currency buys
+func (s *CurrencyService) Rate(code string) (float64, error) {
+	if code == s.base {
+		return 1, nil
+	}
+
+	s.mu.RLock()
+	rates := s.rates
+	s.mu.RUnlock()
+	if rates == nil {
+		var err error
+		if rates, err = s.load(); err != nil {
+			return 0, err
+		}
+	}
+
+	rate, ok := rates[code]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", code)
+	}
+	return rate, nil
+}
+
+// Ready reports whether there is an exchange rate for every supported
+// currency, without which prices cannot be shown or charged in it
+func (s *CurrencyService) Ready() error {
+	for _, code := range s.codes[1:] {
+		if _, err := s.Rate(code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rates returns the stored exchange rates
+func (s *CurrencyService) Rates() ([]models.ExchangeRate, error) {
+	return s.repo.FindRates()
+}
+
+func (s *CurrencyService) load() (map[string]float64, error) {
+	stored, err := s.repo.FindRates()
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]float64, len(stored))
+	for _, r := range stored {
+		rates[r.Currency] = r.Rate
+	}
+
+	s.mu.Lock()
+	s.rates = rates
+	s.mu.Unlock()
+	return rates, nil
+}
+
+// Refresh fetches the rates of the supported currencies from the rate source
+// and stores them
+func (s *CurrencyService) Refresh(ctx context.Context) error {
+	fetched, err := s.source.Rates(ctx, s.base)
+	if err != nil {
+		return err
+	}
+
+	var rates []models.ExchangeRate
+	var missing []string
+	for _, code := range s.codes[1:] {
+		rate, ok := fetched[code]
+		if !ok || rate <= 0 {
+			missing = append(missing, code)
+			continue
+		}
+		rates = append(rates, models.ExchangeRate{Currency: code, Rate: rate, Source: s.source.Name()})
+	}
+	if err := s.repo.SaveRates(rates); err != nil {
+		return err
+	}
+	if _, err := s.load(); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s has no rate for %v", s.source.Name(), missing)
+	}
+	return nil
+}
+
+// Start refreshes the exchange rates now and then at the refresh interval
+// until ctx is cancelled. Nothing runs when only the base currency is
+// supported.
+func (s *CurrencyService) Start(ctx context.Context) {
+	if len(s.codes) == 1 {
+		return
+	}
+	s.wg.Add(1)
+	go s.run(ctx)
+}
+
+// Wait blocks until the refresh loop has stopped
+func (s *CurrencyService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *CurrencyService) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	var tick <-chan time.Time
+	if s.refresh > 0 {
+		ticker := time.NewTicker(s.refresh)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := s.Refresh(ctx); err != nil {
+			log.Printf("Failed to refresh exchange rates: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
+
+// pricing converts base prices into one currency
+type pricing struct {
+	code      string
+	rate      float64
+	rounding  money.Rounding
+	overrides map[uint]money.Money
+}
+
+// pricingFor loads the rate and the price overrides of the given products
+// in code
+func (s *CurrencyService) pricingFor(ctx context.Context, code string, productIDs []uint) (*pricing, error) {
+	rate, err := s.Rate(code)
+	if err != nil {
+		return nil, err
+	}
+	p := &pricing{code: code, rate: rate, rounding: s.rounding, overrides: make(map[uint]money.Money)}
+	if code == s.base {
+		return p, nil
+	}
+
+	prices, err := s.repo.WithContext(ctx).FindPricesIn(productIDs, code)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		p.overrides[price.ProductID] = price.Price
+	}
+	return p, nil
+}
+
+// convert converts and rounds a base amount
+func (p *pricing) convert(amount money.Money) money.Money {
+	if amount.Currency == p.code {
+		return amount
+	}
+	return p.rounding.Round(amount.Convert(p.rate, p.code))
+}
+
+// price returns what the product costs at the given base price. Overrides
+// apply to the product's regular price only, so sales and stale cart prices
+// are converted.
+func (p *pricing) price(product *models.Product, basePrice money.Money) money.Money {
+	if override, ok := p.overrides[product.ID]; ok && product.CompareAtPrice == nil && basePrice.Equal(product.Price) {
+		return override
+	}
+	return p.convert(basePrice)
+}
+
+func (p *pricing) localize(product *models.Product) {
+	price := p.price(product, product.Price)
+	product.DisplayPrice = &price
+	product.DisplayCompareAtPrice = nil
+	if product.CompareAtPrice != nil {
+		compareAt := p.convert(*product.CompareAtPrice)
+		if override, ok := p.overrides[product.ID]; ok {
+			compareAt = override
+		}
+		product.DisplayCompareAtPrice = &compareAt
+	}
+}
+
+// inCurrency sets code on an amount entered without a currency and refuses
+// amounts in any other currency
+func inCurrency(amount money.Money, code string) (money.Money, error) {
+	if amount.Currency != "" && amount.Currency != code {
+		return amount, fmt.Errorf("amount must be in %s", code)
+	}
+	return money.New(amount.Amount, code), nil
+}
+
+// LocalizeProducts sets the display prices of products in code
+func (s *CurrencyService) LocalizeProducts(ctx context.Context, products []models.Product, code string) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CurrencyService.LocalizeProducts")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	ids := make([]uint, len(products))
+	for i := range products {
+		ids[i] = products[i].ID
+	}
+	p, err := s.pricingFor(ctx, code, ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		p.localize(&products[i])
+	}
+	return nil
+}
+
+// LocalizeProduct sets the display prices of a product in code
+func (s *CurrencyService) LocalizeProduct(ctx context.Context, product *models.Product, code string) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CurrencyService.LocalizeProduct")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	p, err := s.pricingFor(ctx, code, []uint{product.ID})
+	if err != nil {
+		return err
+	}
+	p.localize(product)
+	return nil
+}
+
+// LocalizeCart sets the display prices of a cart and its products in code
+func (s *CurrencyService) LocalizeCart(ctx context.Context, cart *models.Cart, code string) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "CurrencyService.LocalizeCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	ids := make([]uint, len(cart.Items))
+	for i, item := range cart.Items {
+		ids[i] = item.ProductID
+	}
+	p, err := s.pricingFor(ctx, code, ids)
+	if err != nil {
+		return err
+	}
+
+	total := money.Zero(code)
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		p.localize(&item.Product)
+		price := p.price(&item.Product, item.Price)
+		subtotal := price.Mul(int64(item.Quantity))
+		item.DisplayPrice = &price
+		item.DisplaySubtotal = &subtotal
+		total = total.Add(subtotal)
+	}
+	cart.DisplayTotal = &total
+	return nil
+}
+
+// ProductPrices returns the price overrides of a product
+func (s *CurrencyService) ProductPrices(productID uint) ([]models.ProductPrice, error) {
+	return s.repo.FindProductPrices(productID)
+}
+
+// SetProductPrice replaces the converted price of a product in a currency
+// other than the base
+func (s *CurrencyService) SetProductPrice(productID uint, code string, price money.Money) (*models.ProductPrice, error) {
+	code, err := s.Resolve(code)
+	if err != nil {
+		return nil, err
+	}
+	if code == s.base {
+		return nil, errors.New("prices in the base currency are set on the product")
+	}
+	if price, err = inCurrency(price, code); err != nil {
+		return nil, err
+	}
+	if !price.IsPositive() {
+		return nil, errors.New("price must be greater than 0")
+	}
+
+	override := &models.ProductPrice{
+		ProductID: productID,
+		Currency:  code,
+		Price:     price,
+	}
+	if err := s.repo.SaveProductPrice(override); err != nil {
+		return nil, err
+	}
+	return override, nil
+}
+
+// DeleteProductPrice goes back to converting the product's price into code
+func (s *CurrencyService) DeleteProductPrice(productID uint, code string) error {
+	found, err := s.repo.DeleteProductPrice(productID, currency.Normalize(code))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("price override not found")
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+const (
+	// PasswordResetTTL is how long an emailed reset link works
+	PasswordResetTTL = 24 * time.Hour
+	// DefaultImpersonationTTL is the length of an impersonation session
+	// when none is requested
+	DefaultImpersonationTTL = 15 * time.Minute
+	// MaxImpersonationTTL caps the length of an impersonation session
+	MaxImpersonationTTL = time.Hour
+)
+
+// CustomerService lets staff look up and manage customer accounts
+type CustomerService struct {
+	repo              *repository.UserRepository
+	userService       *UserService
+	orderRepo         *repository.OrderRepository
+	addressRepo       *repository.AddressRepository
+	reviewRepo        *repository.ReviewRepository
+	impersonationRepo *repository.ImpersonationRepository
+	emailService      *EmailService
+	storeURL          string
+}
+
+func NewCustomerService(repo *repository.UserRepository, userService *UserService, orderRepo *repository.OrderRepository, addressRepo *repository.AddressRepository, reviewRepo *repository.ReviewRepository, impersonationRepo *repository.ImpersonationRepository, emailService *EmailService, storeURL string) *CustomerService {
+	return &CustomerService{
+		repo:              repo,
+		userService:       userService,
+		orderRepo:         orderRepo,
+		addressRepo:       addressRepo,
+		reviewRepo:        reviewRepo,
+		impersonationRepo: impersonationRepo,
+		emailService:      emailService,
+		storeURL:          storeURL,
+	}
+}
+
+// ListCustomers returns one page of accounts, newest first
+func (s *CustomerService) ListCustomers(filter repository.UserFilter, page, pageSize int) ([]models.User, int64, error) {
+	switch filter.Role {
+	case "", models.RoleUser, models.RoleSupport, models.RoleAdmin:
+	default:
+		return nil, 0, errors.New("invalid role")
+	}
+	switch filter.Status {
+	case "", models.UserStatusActive, models.UserStatusDisabled, models.UserStatusBanned:
+	default:
+		return nil, 0, errors.New("invalid status")
+	}
+	return s.repo.FindFiltered(filter, (page-1)*pageSize, pageSize)
+}
+
+func (s *CustomerService) GetCustomer(id uint) (*models.User, error) {
+	return s.userService.GetUser(id)
+}
+
+// CustomerOrders returns one page of a customer's orders, newest first
+func (s *CustomerService) CustomerOrders(id uint, page, pageSize int) ([]models.Order, int64, error) {
+	if _, err := s.userService.GetUser(id); err != nil {
+		return nil, 0, err
+	}
+	return s.orderRepo.FindFiltered(repository.OrderFilter{UserID: id}, (page-1)*pageSize, pageSize)
+}
+
+func (s *CustomerService) CustomerAddresses(id uint) ([]models.Address, error) {
+	if _, err := s.userService.GetUser(id); err != nil {
+		return nil, err
+	}
+	return s.addressRepo.FindByUserID(id)
+}
+
+func (s *CustomerService) CustomerReviews(id uint) ([]models.Review, error) {
+	if _, err := s.userService.GetUser(id); err != nil {
+		return nil, err
+	}
+	return s.reviewRepo.FindByUserID(id)
+}
+
+// otherAccount loads an account an admin wants to change, refusing the
+// admin's own so nobody locks themselves out
+func (s *CustomerService) otherAccount(actorID uint, id uint) (*models.User, error) {
+	if actorID == id {
+		return nil, errors.New("you cannot change your own account here")
+	}
+	return s.userService.GetUser(id)
+}
+
+// ChangeRole makes the account a customer, support agent or admin. The new
+// role applies to the account's next request.
+func (s *CustomerService) ChangeRole(actorID uint, id uint, role string) (*models.User, error) {
+	switch role {
+	case models.RoleUser, models.RoleSupport, models.RoleAdmin:
+	default:
+		return nil, errors.New("role must be user, support or admin")
+	}
+
+	user, err := s.otherAccount(actorID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateFields(id, map[string]interface{}{"role": role}); err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+// SetStatus activates, disables or bans the account. Disabled and banned
+// accounts can neither sign in nor use tokens issued earlier.
+func (s *CustomerService) SetStatus(actorID uint, id uint, status models.UserStatus, reason string) (*models.User, error) {
+	switch status {
+	case models.UserStatusActive:
+		reason = ""
+	case models.UserStatusDisabled, models.UserStatusBanned:
+		if reason == "" {
+			return nil, errors.New("reason is required")
+		}
+	default:
+		return nil, errors.New("status must be active, disabled or banned")
+	}
+
+	user, err := s.otherAccount(actorID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateFields(id, map[string]interface{}{"status": status, "status_reason": reason}); err != nil {
+		return nil, err
+	}
+	user.Status = status
+	user.StatusReason = reason
+	return user, nil
+}
+
+// ForcePasswordReset locks the account until the customer chooses a new
+// password through the link emailed to them
+func (s *CustomerService) ForcePasswordReset(actorID uint, id uint) error {
+	user, err := s.otherAccount(actorID, id)
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	resetToken := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	}
+	if err := s.repo.CreateResetToken(resetToken, true); err != nil {
+		return err
+	}
+
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s", s.storeURL, url.QueryEscape(token))
+	return s.emailService.SendPasswordReset(user, resetURL)
+}
+
+// DeleteCustomer removes the account. Its orders are kept.
+func (s *CustomerService) DeleteCustomer(actorID uint, id uint) error {
+	if _, err := s.otherAccount(actorID, id); err != nil {
+		return err
+	}
+	return s.userService.DeleteUser(id)
+}
+
+// StartImpersonation opens a session in which a staff member acts as the
+// customer. Only active customer accounts can be impersonated.
+func (s *CustomerService) StartImpersonation(staffID uint, id uint, reason string, ttl time.Duration) (*models.ImpersonationSession, error) {
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+	if ttl == 0 {
+		ttl = DefaultImpersonationTTL
+	}
+	if ttl < 0 || ttl > MaxImpersonationTTL {
+		return nil, fmt.Errorf("sessions last at most %d minutes", int(MaxImpersonationTTL.Minutes()))
+	}
+
+	user, err := s.userService.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != models.RoleUser {
+		return nil, errors.New("only customer accounts can be impersonated")
+	}
+	if err := checkAccess(user); err != nil {
+		return nil, fmt.Errorf("cannot impersonate: %v", err)
+	}
+
+	session := &models.ImpersonationSession{
+		ImpersonatorID: staffID,
+		UserID:         id,
+		Reason:         reason,
+		ExpiresAt:      time.Now().Add(ttl),
+	}
+	if err := s.impersonationRepo.Create(session); err != nil {
+		return nil, err
+	}
+	session.User = user
+	return session, nil
+}
+
+// ActiveImpersonation returns the session behind an impersonation token,
+// failing once it has expired or been ended
+func (s *CustomerService) ActiveImpersonation(id uint) (*models.ImpersonationSession, error) {
+	session, err := s.impersonationRepo.FindByID(id)
+	if err != nil || !session.Active(time.Now()) {
+		return nil, errors.New("impersonation session has ended")
+	}
+	return session, nil
+}
+
+// EndImpersonation closes a session before it expires
+func (s *CustomerService) EndImpersonation(staffID uint, id uint) (*models.ImpersonationSession, error) {
+	if _, err := s.impersonationRepo.FindByID(id); err != nil {
+		return nil, errors.New("impersonation session not found")
+	}
+	if err := s.impersonationRepo.End(id, staffID); err != nil {
+		return nil, err
+	}
+	return s.impersonationRepo.FindByID(id)
+}
+
+// ListImpersonations returns one page of impersonation sessions, newest
+// first, optionally only those of one customer
+func (s *CustomerService) ListImpersonations(userID uint, page, pageSize int) ([]models.ImpersonationSession, int64, error) {
+	return s.impersonationRepo.FindPaged(userID, (page-1)*pageSize, pageSize)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/events"
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/mail"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+const (
+	EmailsQueue  = "emails"
+	JobSendEmail = "email.send"
+)
+
+// EmailEventTypes are the domain events that trigger emails
+var EmailEventTypes = []string{
+	events.TypeOrderPlaced,
+	events.TypeOrderStatusChanged,
+	events.TypeUserRegistered,
+}
+
+// EmailData is what email templates are rendered with
+type EmailData struct {
+	StoreName string
+	StoreURL  string
+	Name      string // Recipient's name
+	Order     *models.Order
+	User      *models.User
+	ResetURL  string
+	Product   *models.Product // Product of a wishlist alert
+	OldPrice  money.Money     // Price before a drop
+}
+
+type EmailService struct {
+	templates *mail.Templates
+	mailer    mail.Mailer
+	queue     *jobs.Queue
+	orderRepo *repository.OrderRepository
+	storeName string
+	storeURL  string
+}
+
+func NewEmailService(templates *mail.Templates, mailer mail.Mailer, queue *jobs.Queue, orderRepo *repository.OrderRepository, storeName, storeURL string) *EmailService {
+	s := &EmailService{
+		templates: templates,
+		mailer:    mailer,
+		queue:     queue,
+		orderRepo: orderRepo,
+		storeName: storeName,
+		storeURL:  storeURL,
+	}
+	jobs.Register(queue, EmailsQueue, JobSendEmail, func(ctx context.Context, msg mail.Message) error {
+		return s.mailer.Send(ctx, &msg)
+	})
+	return s
+}
+
+// send renders a template and queues the email to the recipient
+func (s *EmailService) send(name, to, locale string, data *EmailData) error {
+	data.StoreName = s.storeName
+	data.StoreURL = s.storeURL
+
+	msg, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	_, err = s.queue.Enqueue(JobSendEmail, msg)
+	return err
+}
+
+// sendOrderEmail sends an order template to the customer who placed the order
+func (s *EmailService) sendOrderEmail(name string, order *models.Order) error {
+	to, recipient, locale := order.GuestEmail, order.GuestEmail, ""
+	if order.User != nil {
+		to, recipient, locale = order.User.Email, order.User.Name, order.User.Locale
+	}
+	return s.send(name, to, locale, &EmailData{Name: recipient, Order: order, User: order.User})
+}
+
+func (s *EmailService) SendOrderConfirmation(order *models.Order) error {
+	return s.sendOrderEmail(mail.TemplateOrderConfirmation, order)
+}
+
+func (s *EmailService) SendOrderShipped(order *models.Order) error {
+	return s.sendOrderEmail(mail.TemplateOrderShipped, order)
+}
+
+func (s *EmailService) SendOrderCancelled(order *models.Order) error {
+	return s.sendOrderEmail(mail.TemplateOrderCancelled, order)
+}
+
+func (s *EmailService) SendRefundIssued(order *models.Order) error {
+	return s.sendOrderEmail(mail.TemplateRefundIssued, order)
+}
+
+func (s *EmailService) SendWelcome(user *models.User) error {
+	return s.send(mail.TemplateWelcome, user.Email, user.Locale, &EmailData{Name: user.Name, User: user})
+}
+
+// SendPasswordReset sends the user a link to choose a new password
+func (s *EmailService) SendPasswordReset(user *models.User, resetURL string) error {
+	return s.send(mail.TemplatePasswordReset, user.Email, user.Locale, &EmailData{Name: user.Name, User: user, ResetURL: resetURL})
+}
+
+// SendPriceDrop tells the user that a product on their wishlist got cheaper
+func (s *EmailService) SendPriceDrop(user *models.User, product *models.Product, oldPrice money.Money) error {
+	return s.send(mail.TemplatePriceDrop, user.Email, user.Locale, &EmailData{Name: user.Name, User: user, Product: product, OldPrice: oldPrice})
+}
+
+// SendBackInStock tells the user that a product on their wishlist can be
+// bought again
+func (s *EmailService) SendBackInStock(user *models.User, product *models.Product) error {
+	return s.send(mail.TemplateBackInStock, user.Email, user.Locale, &EmailData{Name: user.Name, User: user, Product: product})
+}
+
+// HandleEvent is an events.Subscriber that sends the emails for order and
+// account lifecycle events
+func (s *EmailService) HandleEvent(ctx context.Context, event events.Event) error {
+	switch event.Type {
+	case events.TypeOrderPlaced:
+		var data events.OrderPlacedData
+		if err := event.Decode(&data); err != nil {
+			return err
+		}
+		order, err := s.orderRepo.FindByID(data.Order.ID)
+		if err != nil {
+			return fmt.Errorf("order %d not found", data.Order.ID)
+		}
+		return s.SendOrderConfirmation(order)
+
+	case events.TypeOrderStatusChanged:
+		var data events.OrderStatusChangedData
+		if err := event.Decode(&data); err != nil {
+			return err
+		}
+
+		var send func(*models.Order) error
+		switch data.NewStatus {
+		case models.OrderStatusShipped:
+			send = s.SendOrderShipped
+		case models.OrderStatusCancelled:
+			send = s.SendOrderCancelled
+		case models.OrderStatusRefunded:
+			send = s.SendRefundIssued
+		default:
+			return nil
+		}
+
+		order, err := s.orderRepo.FindByID(data.OrderID)
+		if err != nil {
+			return fmt.Errorf("order %d not found", data.OrderID)
+		}
+		return send(order)
+
+	case events.TypeUserRegistered:
+		var data events.UserRegisteredData
+		if err := event.Decode(&data); err != nil {
+			return err
+		}
+		return s.SendWelcome(data.User)
+	}
+	return nil
+}
+
+// TemplateNames lists the templates that can be previewed
+func (s *EmailService) TemplateNames() []string {
+	return mail.TemplateNames
+}
+
+// PreviewTemplate renders a template in the given locale with sample data
+func (s *EmailService) PreviewTemplate(name, locale string) (*mail.Message, error) {
+	if locale != "" && !mail.ValidLocale(locale) {
+		return nil, errors.New("locale must be a language code such as en or de-at")
+	}
+	userID := uint(1)
+	user := &models.User{ID: userID, Name: "Jane Doe", Email: "jane@example.com", Locale: locale}
+	order := &models.Order{
+		ID:        1001,
+		CreatedAt: time.Now(),
+		UserID:    &userID,
+		User:      user,
+		Status:    models.OrderStatusShipped,
+		Items: []models.OrderItem{
+			{ProductID: 1, Product: models.Product{Name: "Cotton T-Shirt"}, Quantity: 2, Price: money.New(1999, "USD"), Subtotal: money.New(3998, "USD")},
+			{ProductID: 2, Product: models.Product{Name: "Canvas Sneakers"}, Quantity: 1, Price: money.New(5900, "USD"), Subtotal: money.New(5900, "USD")},
+		},
+		ShippingCost:   money.New(495, "USD"),
+		TaxAmount:      money.New(833, "USD"),
+		Discount:       money.New(500, "USD"),
+		TotalAmount:    money.New(9898, "USD"),
+		Currency:       "USD",
+		ExchangeRate:   1,
+		TrackingNumber: "1Z999AA10123456784",
+		ShippingAddress: models.Address{
+			Street:  "1 Main Street",
+			City:    "Springfield",
+			State:   "IL",
+			ZipCode: "62701",
+			Country: "US",
+		},
+	}
+
+	data := &EmailData{
+		StoreName: s.storeName,
+		StoreURL:  s.storeURL,
+		Name:      user.Name,
+		Order:     order,
+		User:      user,
+		ResetURL:  s.storeURL + "/reset-password?token=example",
+		Product:   &models.Product{ID: 2, Name: "Canvas Sneakers", Price: money.New(4900, "USD"), Stock: 12},
+		OldPrice:  money.New(5900, "USD"),
+	}
+
+	msg, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return nil, err
+	}
+	msg.To = user.Email
+	return msg, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/documents"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/storage"
+)
+
+// invoiceableStatuses are the order states an invoice can be issued in
+var invoiceableStatuses = map[models.OrderStatus]bool{
+	models.OrderStatusProcessing: true,
+	models.OrderStatusShipped:    true,
+	models.OrderStatusDelivered:  true,
+	models.OrderStatusRefunded:   true,
+}
+
+type InvoiceService struct {
+	repo      *repository.InvoiceRepository
+	orderRepo *repository.OrderRepository
+	store     storage.Store
+	seller    documents.Seller
+}
+
+func NewInvoiceService(repo *repository.InvoiceRepository, orderRepo *repository.OrderRepository, store storage.Store, seller documents.Seller) *InvoiceService {
+	return &InvoiceService{
+		repo:      repo,
+		orderRepo: orderRepo,
+		store:     store,
+		seller:    seller,
+	}
+}
+
+// customerOrder loads an order, making sure it belongs to the user
+func (s *InvoiceService) customerOrder(orderID uint, userID uint) (*models.Order, error) {
+	order, err := s.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if !order.BelongsTo(userID) {
+		return nil, errors.New("unauthorized access")
+	}
+	return order, nil
+}
+
+// GetInvoice returns the invoice of one of the user's orders and its PDF,
+// issuing it on first request
+func (s *InvoiceService) GetInvoice(orderID uint, userID uint) (*models.Invoice, []byte, error) {
+	order, err := s.customerOrder(orderID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.invoice(order)
+}
+
+// GetInvoiceForAdmin is GetInvoice for any order
+func (s *InvoiceService) GetInvoiceForAdmin(orderID uint) (*models.Invoice, []byte, error) {
+	order, err := s.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, nil, errors.New("order not found")
+	}
+	return s.invoice(order)
+}
+
+// invoice returns the stored invoice of an order, issuing it if needed.
+// Issued invoices are never regenerated, so later order changes do not
+// alter them.
+func (s *InvoiceService) invoice(order *models.Order) (*models.Invoice, []byte, error) {
+	if invoice, err := s.repo.FindByOrderID(order.ID); err == nil {
+		data, err := s.store.Open(invoice.FilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invoice %s file is missing", invoice.Number)
+		}
+		return invoice, data, nil
+	}
+
+	if !invoiceableStatuses[order.Status] {
+		return nil, nil, errors.New("invoice is not available for this order yet")
+	}
+
+	now := time.Now()
+	invoice := &models.Invoice{
+		OrderID:  order.ID,
+		Year:     now.Year(),
+		IssuedAt: now,
+		Total:    order.TotalAmount,
+	}
+
+	var data []byte
+	err := s.repo.Issue(invoice, func(invoice *models.Invoice) error {
+		invoice.Number = fmt.Sprintf("INV-%d-%06d", invoice.Year, invoice.Sequence)
+
+		var err error
+		data, err = documents.Invoice(s.seller, invoice, order)
+		if err != nil {
+			return err
+		}
+
+		invoice.FilePath = fmt.Sprintf("invoices/%d/%s.pdf", invoice.Year, invoice.Number)
+		_, err = s.store.Save(invoice.FilePath, data)
+		return err
+	})
+	if err != nil {
+		// Another request may have issued the invoice meanwhile
+		if existing, findErr := s.repo.FindByOrderID(order.ID); findErr == nil {
+			data, err := s.store.Open(existing.FilePath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invoice %s file is missing", existing.Number)
+			}
+			return existing, data, nil
+		}
+		return nil, nil, err
+	}
+
+	return invoice, data, nil
+}
+
+// GetPackingSlip renders the packing slip of one of the user's orders
+func (s *InvoiceService) GetPackingSlip(orderID uint, userID uint) ([]byte, error) {
+	order, err := s.customerOrder(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return documents.PackingSlips(s.seller, []models.Order{*order})
+}
+
+// GetPackingSlips renders one packing slip page per order, in the order given
+func (s *InvoiceService) GetPackingSlips(orderIDs []uint) ([]byte, error) {
+	if len(orderIDs) == 0 {
+		return nil, errors.New("no orders given")
+	}
+
+	orders := make([]models.Order, 0, len(orderIDs))
+	for _, id := range orderIDs {
+		order, err := s.orderRepo.FindByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("order %d not found", id)
+		}
+		orders = append(orders, *order)
+	}
+	return documents.PackingSlips(s.seller, orders)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+type JobService struct {
+	repo *repository.JobRepository
+}
+
+func NewJobService(repo *repository.JobRepository) *JobService {
+	return &JobService{repo: repo}
+}
+
+// ListJobs returns one page of jobs, newest first
+func (s *JobService) ListJobs(filter repository.JobFilter, page, pageSize int) ([]models.Job, int64, error) {
+	switch filter.Status {
+	case "", models.JobStatusPending, models.JobStatusRunning, models.JobStatusCompleted, models.JobStatusDead:
+	default:
+		return nil, 0, errors.New("invalid job status")
+	}
+	return s.repo.FindFiltered(filter, (page-1)*pageSize, pageSize)
+}
+
+func (s *JobService) GetJob(id uint) (*models.Job, error) {
+	job, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("job not found")
+	}
+	return job, nil
+}
+
+// JobStats counts jobs per queue and status
+func (s *JobService) JobStats() ([]repository.JobCount, error) {
+	return s.repo.CountByStatus()
+}
+
+// RetryJob runs a dead job again, or a scheduled job right away
+func (s *JobService) RetryJob(id uint) (*models.Job, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, errors.New("job not found")
+	}
+
+	ok, err := s.repo.Retry(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("only dead or pending jobs can be retried")
+	}
+	return s.repo.FindByID(id)
+}
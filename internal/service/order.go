@@ -1,110 +1,455 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
+	"github.com/sajal/go-ecommerce/internal/events"
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// orderTransitions lists the statuses an order may move to from each status.
+// Cancelled and refunded orders are final.
+var orderTransitions = map[models.OrderStatus][]models.OrderStatus{
+	models.OrderStatusPending:    {models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusShipped:    {models.OrderStatusDelivered, models.OrderStatusRefunded},
+	models.OrderStatusDelivered:  {models.OrderStatusRefunded},
+}
+
+// canTransition reports whether an order may move from one status to another
+func canTransition(from, to models.OrderStatus) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkOrderResult reports the outcome of a bulk action for one order
+type BulkOrderResult struct {
+	OrderID uint   `json:"order_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// PreviousStatus is the status a successfully updated order had before
+	PreviousStatus models.OrderStatus `json:"previous_status,omitempty"`
+}
+
+// Reasons a checkout fails, as counted in the checkout failure metric
+const (
+	checkoutInvalid      = "invalid"
+	checkoutAddress      = "address"
+	checkoutCartNotFound = "cart"
+	checkoutCartChanged  = "cart_changed"
+	checkoutEmptyCart    = "empty_cart"
+	checkoutUnavailable  = "unavailable"
+	checkoutOutOfStock   = "out_of_stock"
+	checkoutCurrency     = "currency"
+	checkoutError        = "error"
+)
+
+// checkoutFailure tags a checkout error with the reason it is counted
+// under, leaving its message and what it wraps unchanged
+type checkoutFailure struct {
+	reason string
+	err    error
+}
+
+func (e *checkoutFailure) Error() string {
+	return e.err.Error()
+}
+
+func (e *checkoutFailure) Unwrap() error {
+	return e.err
+}
+
+func failCheckout(reason string, err error) error {
+	return &checkoutFailure{reason: reason, err: err}
+}
+
+// finishCheckout counts the outcome of a checkout and ends its span
+func finishCheckout(span trace.Span, checkout string, order *models.Order, err error) {
+	if err != nil {
+		reason := checkoutError
+		var failure *checkoutFailure
+		if errors.As(err, &failure) {
+			reason = failure.reason
+		}
+		telemetry.CheckoutFailed(checkout, reason)
+		span.SetAttributes(attribute.String("checkout.failure", reason))
+	} else {
+		telemetry.OrderPlaced(checkout, order.TotalAmount)
+		span.SetAttributes(attribute.Int("order.id", int(order.ID)), attribute.String("order.currency", order.Currency))
+	}
+	telemetry.EndSpan(span, err)
+}
+
 type OrderService struct {
-	repo     *repository.OrderRepository
-	cartRepo *repository.CartRepository
+	repo           *repository.OrderRepository
+	cartService    *CartService
+	addressService *AddressService
+	currencies     *CurrencyService
 }
 
-func NewOrderService(repo *repository.OrderRepository, cartRepo *repository.CartRepository) *OrderService {
+func NewOrderService(repo *repository.OrderRepository, cartService *CartService, addressService *AddressService, currencies *CurrencyService) *OrderService {
 	return &OrderService{
-		repo:     repo,
-		cartRepo: cartRepo,
+		repo:           repo,
+		cartService:    cartService,
+		addressService: addressService,
+		currencies:     currencies,
 	}
 }
 
-func (s *OrderService) CreateOrder(userID uint) (*models.Order, error) {
-	// Get user's cart
-	cart, err := s.cartRepo.FindByUserID(userID)
+// checkoutAddresses resolves the shipping and billing addresses for a user's
+// order. A zero ID selects the user's default of that type; without a
+// default billing address the order is billed to the shipping address.
+func (s *OrderService) checkoutAddresses(ctx context.Context, userID uint, shippingAddressID uint, billingAddressID uint) (_ *models.Address, _ *models.Address, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.checkoutAddresses")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	var shipping *models.Address
+	if shippingAddressID == 0 {
+		shipping, err = s.addressService.GetDefaultAddress(ctx, userID, "shipping")
+	} else {
+		shipping, err = s.addressService.GetAddress(ctx, userID, shippingAddressID)
+	}
 	if err != nil {
-		return nil, errors.New("cart not found")
+		return nil, nil, failCheckout(checkoutAddress, err)
+	}
+	if shipping.Type != "shipping" {
+		return nil, nil, failCheckout(checkoutAddress, errors.New("shipping address must be of type shipping"))
+	}
+
+	if billingAddressID == 0 {
+		billing, err := s.addressService.GetDefaultAddress(ctx, userID, "billing")
+		if err != nil {
+			return shipping, shipping, nil
+		}
+		return shipping, billing, nil
 	}
 
+	billing, err := s.addressService.GetAddress(ctx, userID, billingAddressID)
+	if err != nil {
+		return nil, nil, failCheckout(checkoutAddress, err)
+	}
+	if billing.Type != "billing" {
+		return nil, nil, failCheckout(checkoutAddress, errors.New("billing address must be of type billing"))
+	}
+	return shipping, billing, nil
+}
+
+// checkoutCart returns the repriced cart for key, refusing carts whose
+// changes the customer has not acknowledged
+func (s *OrderService) checkoutCart(ctx context.Context, key CartKey) (_ *models.Cart, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.checkoutCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	cart, err := s.cartService.GetCart(ctx, key)
+	if err != nil {
+		return nil, failCheckout(checkoutCartNotFound, err)
+	}
+	if cart.RequiresAcknowledgement {
+		return nil, failCheckout(checkoutCartChanged, ErrCartRequiresAcknowledgement)
+	}
+	return cart, nil
+}
+
+// orderFromCart converts cart lines into the lines of an order in code,
+// rejecting products that are no longer sellable in the requested quantity
+func (s *OrderService) orderFromCart(ctx context.Context, cart *models.Cart, code string) (_ *models.Order, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.orderFromCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	if len(cart.Items) == 0 {
-		return nil, errors.New("cart is empty")
+		return nil, failCheckout(checkoutEmptyCart, errors.New("cart is empty"))
 	}
 
-	// Create order items from cart items
-	var orderItems []models.OrderItem
-	var total float64
+	ids := make([]uint, len(cart.Items))
+	for i, item := range cart.Items {
+		ids[i] = item.ProductID
+	}
+	pricing, err := s.currencies.pricingFor(ctx, code, ids)
+	if err != nil {
+		return nil, failCheckout(checkoutCurrency, err)
+	}
 
+	zero := money.Zero(code)
+	order := &models.Order{
+		Currency:     code,
+		ExchangeRate: pricing.rate,
+		TotalAmount:  zero,
+		ShippingCost: zero,
+		TaxAmount:    zero,
+		Discount:     zero,
+	}
 	for _, item := range cart.Items {
-		orderItems = append(orderItems, models.OrderItem{
+		if item.Product.ID == 0 || !item.Product.IsActive {
+			return nil, failCheckout(checkoutUnavailable, fmt.Errorf("product %d is no longer available", item.ProductID))
+		}
+		if item.Quantity > item.Product.Stock {
+			return nil, failCheckout(checkoutOutOfStock, fmt.Errorf("insufficient stock for %s", item.Product.Name))
+		}
+
+		price := pricing.price(&item.Product, item.Price)
+		subtotal := price.Mul(int64(item.Quantity))
+		order.Items = append(order.Items, models.OrderItem{
 			ProductID: item.ProductID,
 			Quantity:  item.Quantity,
-			Price:     item.Price,
-			Subtotal:  item.Subtotal,
+			Price:     price,
+			Subtotal:  subtotal,
 		})
-		total += item.Subtotal
+		order.TotalAmount = order.TotalAmount.Add(subtotal)
 	}
 
-	// Create order
-	order := &models.Order{
-		UserID:      userID,
-		Items:       orderItems,
-		TotalAmount: total,
-		Status:      models.OrderStatusPending,
+	return order, nil
+}
+
+// CreateOrder places an order from the user's cart in currency code, shipped
+// to and billed at addresses owned by the user. The order keeps its own
+// copies of the addresses.
+func (s *OrderService) CreateOrder(ctx context.Context, userID uint, shippingAddressID uint, billingAddressID uint, notes string, code string) (order *models.Order, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.CreateOrder")
+	defer func() { finishCheckout(span, "user", order, err) }()
+
+	// Resolve addresses
+	shipping, billing, err := s.checkoutAddresses(ctx, userID, shippingAddressID, billingAddressID)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.Create(order); err != nil {
+	// Get user's cart
+	cart, err := s.checkoutCart(ctx, CartKey{UserID: userID})
+	if err != nil {
 		return nil, err
 	}
 
-	// Clear the cart
-	if err := s.cartRepo.ClearCart(userID); err != nil {
+	// Create order items from cart items
+	order, err = s.orderFromCart(ctx, cart, code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create order with copies of the addresses, inserted with it, so later
+	// edits to the address book leave the order as placed
+	order.UserID = &userID
+	order.Status = models.OrderStatusPending
+	order.ShippingAddress = *shipping.Copy()
+	if billing.ID != shipping.ID {
+		order.BillingAddress = billing.Copy()
+	}
+	order.Notes = notes
+
+	// Create the order and clear the cart together
+	if err := s.repo.WithContext(ctx).CreateFromCart(order, cart, events.OrderPlaced(order)); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// CreateGuestOrder places an order for an anonymous visitor from the guest
+// cart identified by token. The addresses are stored without an owner and
+// the guest cart is removed along with placing the order. A nil billing address
+// bills the order to the shipping address.
+func (s *OrderService) CreateGuestOrder(ctx context.Context, token string, email string, shipping *models.Address, billing *models.Address, notes string, code string) (order *models.Order, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.CreateGuestOrder")
+	defer func() { finishCheckout(span, "guest", order, err) }()
+
+	if email == "" {
+		return nil, failCheckout(checkoutInvalid, errors.New("email is required"))
+	}
+
+	// Get guest cart
+	if token == "" {
+		return nil, failCheckout(checkoutCartNotFound, errors.New("cart not found"))
+	}
+	cart, err := s.checkoutCart(ctx, CartKey{Token: token})
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate addresses
+	shipping.UserID = nil
+	shipping.Type = "shipping"
+	if err := s.addressService.ValidateAddress(shipping); err != nil {
+		return nil, failCheckout(checkoutAddress, err)
+	}
+	if billing != nil {
+		billing.UserID = nil
+		billing.Type = "billing"
+		if err := s.addressService.ValidateAddress(billing); err != nil {
+			return nil, failCheckout(checkoutAddress, err)
+		}
+	}
+
+	// Create order items from cart items
+	order, err = s.orderFromCart(ctx, cart, code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create order, the addresses are inserted with it
+	order.GuestEmail = email
+	order.Status = models.OrderStatusPending
+	order.ShippingAddress = *shipping
+	order.BillingAddress = billing
+	order.Notes = notes
+
+	// Create the order and remove the guest cart together
+	if err := s.repo.WithContext(ctx).CreateFromCart(order, cart, events.OrderPlaced(order)); err != nil {
 		return nil, err
 	}
 
 	return order, nil
 }
 
-func (s *OrderService) GetOrder(id uint, userID uint) (*models.Order, error) {
-	order, err := s.repo.FindByID(id)
+func (s *OrderService) GetOrder(ctx context.Context, id uint, userID uint) (_ *models.Order, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.GetOrder")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	order, err := s.repo.WithContext(ctx).FindByID(id)
 	if err != nil {
 		return nil, errors.New("order not found")
 	}
 
 	// Check if order belongs to user
-	if order.UserID != userID {
+	if !order.BelongsTo(userID) {
 		return nil, errors.New("unauthorized access")
 	}
 
 	return order, nil
 }
 
-func (s *OrderService) GetUserOrders(userID uint) ([]models.Order, error) {
-	return s.repo.FindByUserID(userID)
+func (s *OrderService) GetUserOrders(ctx context.Context, userID uint) (_ []models.Order, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.GetUserOrders")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByUserID(userID)
 }
 
-func (s *OrderService) UpdateOrderStatus(id uint, status models.OrderStatus) error {
+// UpdateOrderStatus moves an order to status. A tracking number, if given,
+// is stored with it; an empty one keeps the current tracking number.
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, id uint, status models.OrderStatus, trackingNumber string) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.UpdateOrderStatus")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Validate status
 	switch status {
 	case models.OrderStatusPending,
 		models.OrderStatusProcessing,
 		models.OrderStatusShipped,
 		models.OrderStatusDelivered,
-		models.OrderStatusCancelled:
+		models.OrderStatusCancelled,
+		models.OrderStatusRefunded:
+	default:
+		return errors.New("invalid order status")
+	}
+
+	order, err := s.repo.WithContext(ctx).FindByID(id)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	if trackingNumber == "" {
+		trackingNumber = order.TrackingNumber
+	}
+	if order.Status == status && order.TrackingNumber == trackingNumber {
+		return nil
+	}
+	if order.Status != status && !canTransition(order.Status, status) {
+		return fmt.Errorf("cannot change order status from %s to %s", order.Status, status)
+	}
+
+	if order.Status == status {
+		// Only the tracking number changed
+		return s.repo.WithContext(ctx).UpdateShipment(id, status, trackingNumber)
+	}
+	return s.repo.WithContext(ctx).UpdateShipment(id, status, trackingNumber, events.OrderStatusChanged(order, status, trackingNumber))
+}
+
+// BulkUpdateStatus moves each order to status under the same rules as
+// UpdateOrderStatus. Orders are updated one by one, so some may fail while
+// the others succeed.
+func (s *OrderService) BulkUpdateStatus(ctx context.Context, ids []uint, status models.OrderStatus) []BulkOrderResult {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.BulkUpdateStatus")
+	defer span.End()
+
+	previous := make(map[uint]models.OrderStatus, len(ids))
+	if orders, err := s.repo.WithContext(ctx).FindByIDs(ids); err == nil {
+		for _, order := range orders {
+			previous[order.ID] = order.Status
+		}
+	}
+
+	results := make([]BulkOrderResult, 0, len(ids))
+	for _, id := range ids {
+		result := BulkOrderResult{OrderID: id, Success: true, PreviousStatus: previous[id]}
+		if err := s.UpdateOrderStatus(ctx, id, status, ""); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			result.PreviousStatus = ""
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// validateOrderFilter rejects unknown statuses and payment states
+func validateOrderFilter(filter repository.OrderFilter) error {
+	switch filter.Status {
+	case "", models.OrderStatusPending, models.OrderStatusProcessing, models.OrderStatusShipped,
+		models.OrderStatusDelivered, models.OrderStatusCancelled, models.OrderStatusRefunded:
 	default:
 		return errors.New("invalid order status")
 	}
+	switch filter.Payment {
+	case "", repository.PaymentPaid, repository.PaymentUnpaid:
+	default:
+		return errors.New("payment must be paid or unpaid")
+	}
+	return nil
+}
+
+// ListOrders returns one page of all customers' orders, newest first
+func (s *OrderService) ListOrders(ctx context.Context, filter repository.OrderFilter, page, pageSize int) (_ []models.Order, _ int64, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.ListOrders")
+	defer func() { telemetry.EndSpan(span, err) }()
 
-	return s.repo.UpdateStatus(id, status)
+	if err := validateOrderFilter(filter); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.WithContext(ctx).FindFiltered(filter, (page-1)*pageSize, pageSize)
 }
 
-func (s *OrderService) CancelOrder(id uint, userID uint) error {
-	order, err := s.repo.FindByID(id)
+// GetOrderForAdmin returns any order with its customer, addresses and lines
+func (s *OrderService) GetOrderForAdmin(ctx context.Context, id uint) (_ *models.Order, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.GetOrderForAdmin")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	order, err := s.repo.WithContext(ctx).FindByID(id)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	return order, nil
+}
+
+func (s *OrderService) CancelOrder(ctx context.Context, id uint, userID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "OrderService.CancelOrder")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	order, err := s.repo.WithContext(ctx).FindByID(id)
 	if err != nil {
 		return errors.New("order not found")
 	}
 
 	// Check if order belongs to user
-	if order.UserID != userID {
+	if !order.BelongsTo(userID) {
 		return errors.New("unauthorized access")
 	}
 
@@ -113,5 +458,5 @@ func (s *OrderService) CancelOrder(id uint, userID uint) error {
 		return errors.New("order cannot be cancelled")
 	}
 
-	return s.repo.UpdateStatus(id, models.OrderStatusCancelled)
+	return s.repo.WithContext(ctx).UpdateStatus(id, models.OrderStatusCancelled, events.OrderStatusChanged(order, models.OrderStatusCancelled, order.TrackingNumber))
 }
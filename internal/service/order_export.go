@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+// orderColumns is the column order of order CSV exports
+var orderColumns = []string{
+	"id", "created_at", "status", "payment", "customer_email", "items",
+	"currency", "shipping_cost", "tax_amount", "discount", "total_amount",
+	"tracking_number", "ship_to_city", "ship_to_country",
+}
+
+// paymentState reports whether payment was taken for the order
+func paymentState(order *models.Order) string {
+	if order.PaymentID != "" {
+		return repository.PaymentPaid
+	}
+	return repository.PaymentUnpaid
+}
+
+// writeOrdersCSV writes one row per order
+func writeOrdersCSV(w io.Writer, orders []models.Order) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(orderColumns); err != nil {
+		return err
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		items := 0
+		for _, item := range order.Items {
+			items += item.Quantity
+		}
+		cw.Write([]string{
+			strconv.FormatUint(uint64(order.ID), 10),
+			order.CreatedAt.UTC().Format(time.RFC3339),
+			string(order.Status),
+			paymentState(order),
+			order.CustomerEmail(),
+			strconv.Itoa(items),
+			order.Currency,
+			order.ShippingCost.Decimal(),
+			order.TaxAmount.Decimal(),
+			order.Discount.Decimal(),
+			order.TotalAmount.Decimal(),
+			order.TrackingNumber,
+			order.ShippingAddress.City,
+			order.ShippingAddress.Country,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportOrders writes the given orders as CSV, in the order given
+func (s *OrderService) ExportOrders(ids []uint, w io.Writer) error {
+	if len(ids) == 0 {
+		return errors.New("no orders given")
+	}
+
+	orders, err := s.repo.FindByIDs(ids)
+	if err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		return errors.New("orders not found")
+	}
+	return writeOrdersCSV(w, orders)
+}
+
+// ExportFilteredOrders writes every order matching the filter as CSV,
+// newest first
+func (s *OrderService) ExportFilteredOrders(filter repository.OrderFilter, w io.Writer) (int, error) {
+	if err := validateOrderFilter(filter); err != nil {
+		return 0, err
+	}
+
+	// A negative limit lifts the page size
+	orders, _, err := s.repo.FindFiltered(filter, 0, -1)
+	if err != nil {
+		return 0, err
+	}
+	return len(orders), writeOrdersCSV(w, orders)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+const (
+	PricesQueue           = "prices"
+	JobStartPriceSchedule = "price.start"
+	JobEndSale            = "price.end_sale"
+)
+
+// errSaleNotStarted makes the job queue retry the end of a sale whose start
+// has not run yet
+var errSaleNotStarted = errors.New("sale has not started yet")
+
+// PriceScheduleJob is the payload of the JobStartPriceSchedule and
+// JobEndSale jobs
+type PriceScheduleJob struct {
+	ScheduleID uint `json:"schedule_id"`
+}
+
+// PriceService keeps the price history of products and applies scheduled
+// price changes and sales on the background job queue. Carts pick up the new
+// prices the next time they are repriced.
+type PriceService struct {
+	repo     *repository.PriceRepository
+	products *ProductService
+	queue    *jobs.Queue
+}
+
+func NewPriceService(repo *repository.PriceRepository, products *ProductService, queue *jobs.Queue) *PriceService {
+	s := &PriceService{
+		repo:     repo,
+		products: products,
+		queue:    queue,
+	}
+	jobs.Register(queue, PricesQueue, JobStartPriceSchedule, s.start)
+	jobs.Register(queue, PricesQueue, JobEndSale, s.endSale)
+	return s
+}
+
+// PriceHistory returns one page of a product's price changes, newest first
+func (s *PriceService) PriceHistory(productID uint, page, pageSize int) ([]models.PriceChange, int64, error) {
+	if _, err := s.products.GetProduct(context.Background(), productID); err != nil {
+		return nil, 0, errors.New("product not found")
+	}
+	return s.repo.FindHistory(productID, (page-1)*pageSize, pageSize)
+}
+
+// ListSchedules returns a product's price schedules, latest start first
+func (s *PriceService) ListSchedules(productID uint) ([]models.PriceSchedule, error) {
+	if _, err := s.products.GetProduct(context.Background(), productID); err != nil {
+		return nil, errors.New("product not found")
+	}
+	return s.repo.FindSchedules(productID)
+}
+
+// CreateSchedule schedules a price change or sale and queues the jobs that
+// start and end it. A zero start applies it right away. Sales of one product
+// cannot overlap.
+func (s *PriceService) CreateSchedule(schedule *models.PriceSchedule) error {
+	product, err := s.products.GetProduct(context.Background(), schedule.ProductID)
+	if err != nil {
+		return errors.New("product not found")
+	}
+	if schedule.Price, err = inCurrency(schedule.Price, product.Currency); err != nil {
+		return err
+	}
+	if !schedule.Price.IsPositive() {
+		return errors.New("price must be greater than 0")
+	}
+	if schedule.StartsAt.IsZero() {
+		schedule.StartsAt = time.Now()
+	}
+
+	switch schedule.Kind {
+	case models.PriceScheduleChange:
+		if schedule.EndsAt != nil {
+			return errors.New("price changes have no end, schedule a sale instead")
+		}
+	case models.PriceScheduleSale:
+		if schedule.EndsAt == nil || !schedule.EndsAt.After(schedule.StartsAt) {
+			return errors.New("sales need an end after their start")
+		}
+		if !schedule.EndsAt.After(time.Now()) {
+			return errors.New("sale would already be over")
+		}
+		if schedule.Price.Cmp(product.RegularPrice()) >= 0 {
+			return errors.New("sale price must be lower than the regular price")
+		}
+		overlapping, err := s.repo.CountOverlappingSales(product.ID, schedule.StartsAt, *schedule.EndsAt)
+		if err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return errors.New("product already has a sale in that period")
+		}
+	default:
+		return errors.New("kind must be change or sale")
+	}
+
+	schedule.StartedAt = nil
+	schedule.EndedAt = nil
+	schedule.CancelledAt = nil
+	// The schedule and its jobs are saved together, so neither exists
+	// without the other
+	return s.repo.Transaction(func(tx *repository.PriceRepository) error {
+		if err := tx.CreateSchedule(schedule); err != nil {
+			return err
+		}
+
+		job := PriceScheduleJob{ScheduleID: schedule.ID}
+		if _, err := s.queue.EnqueueTx(tx.DB, JobStartPriceSchedule, job, jobs.At(schedule.StartsAt)); err != nil {
+			return err
+		}
+		if schedule.Kind == models.PriceScheduleSale {
+			if _, err := s.queue.EnqueueTx(tx.DB, JobEndSale, job, jobs.At(*schedule.EndsAt)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSchedule returns one of a product's price schedules
+func (s *PriceService) GetSchedule(productID uint, id uint) (*models.PriceSchedule, error) {
+	schedule, err := s.repo.FindSchedule(id)
+	if err != nil || schedule.ProductID != productID {
+		return nil, errors.New("price schedule not found")
+	}
+	return schedule, nil
+}
+
+// CancelSchedule cancels a schedule that has not started and ends a running
+// sale early
+func (s *PriceService) CancelSchedule(productID uint, id uint) (*models.PriceSchedule, error) {
+	schedule, err := s.GetSchedule(productID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case schedule.Pending():
+		cancelled, err := s.repo.CancelSchedule(id, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if !cancelled {
+			return nil, errors.New("price schedule has started in the meantime")
+		}
+	case schedule.Running():
+		if err := s.endSale(context.Background(), PriceScheduleJob{ScheduleID: id}); err != nil {
+			return nil, err
+		}
+	case schedule.CancelledAt != nil:
+		return nil, errors.New("price schedule is already cancelled")
+	default:
+		return nil, errors.New("price schedule has already been applied")
+	}
+
+	return s.repo.FindSchedule(id)
+}
+
+// start is the JobStartPriceSchedule handler
+func (s *PriceService) start(ctx context.Context, job PriceScheduleJob) error {
+	return s.apply(job.ScheduleID, s.repo.StartSchedule)
+}
+
+// endSale is the JobEndSale handler. It also ends sales cancelled early.
+func (s *PriceService) endSale(ctx context.Context, job PriceScheduleJob) error {
+	schedule, err := s.repo.FindSchedule(job.ScheduleID)
+	if err != nil {
+		return err
+	}
+	if schedule.Pending() {
+		return errSaleNotStarted
+	}
+	return s.apply(job.ScheduleID, s.repo.EndSale)
+}
+
+// apply runs a schedule transition and tells the product observers, such as
+// wishlist price alerts, about the new price
+func (s *PriceService) apply(id uint, transition func(id uint, now time.Time) (bool, error)) error {
+	schedule, err := s.repo.FindSchedule(id)
+	if err != nil {
+		return err
+	}
+	before, err := s.products.GetProduct(context.Background(), schedule.ProductID)
+	if err != nil {
+		before = nil
+	}
+
+	applied, err := transition(id, time.Now())
+	if err != nil || !applied || before == nil {
+		return err
+	}
+
+	after, err := s.products.GetProduct(context.Background(), schedule.ProductID)
+	if err != nil {
+		return nil
+	}
+	s.products.notifyUpdated(before, after)
+	return nil
+}
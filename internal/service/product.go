@@ -1,88 +1,252 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"github.com/sajal/go-ecommerce/internal/events"
 	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 )
 
+// ProductObserver is told about product changes after they are saved
+type ProductObserver interface {
+	ProductCreated(product *models.Product)
+	ProductUpdated(before, after *models.Product)
+	ProductDeleted(product *models.Product)
+}
+
 type ProductService struct {
-	repo *repository.ProductRepository
+	repo      *repository.ProductRepository
+	currency  string // Base currency every product is priced in
+	observers []ProductObserver
 }
 
-func NewProductService(repo *repository.ProductRepository) *ProductService {
-	return &ProductService{repo: repo}
+func NewProductService(repo *repository.ProductRepository, currency string) *ProductService {
+	return &ProductService{repo: repo, currency: currency}
 }
 
-func (s *ProductService) CreateProduct(product *models.Product) error {
-	// Validate product data
+// AddObserver registers o to be told about product changes
+func (s *ProductService) AddObserver(o ProductObserver) {
+	s.observers = append(s.observers, o)
+}
+
+func (s *ProductService) notifyCreated(product *models.Product) {
+	for _, o := range s.observers {
+		o.ProductCreated(product)
+	}
+}
+
+func (s *ProductService) notifyUpdated(before, after *models.Product) {
+	for _, o := range s.observers {
+		o.ProductUpdated(before, after)
+	}
+}
+
+func (s *ProductService) notifyDeleted(product *models.Product) {
+	for _, o := range s.observers {
+		o.ProductDeleted(product)
+	}
+}
+
+// validateProduct checks the fields every stored product must have
+func validateProduct(product *models.Product) error {
 	if product.Name == "" {
 		return errors.New("product name is required")
 	}
-	if product.Price <= 0 {
+	if !product.Price.IsPositive() {
 		return errors.New("product price must be greater than 0")
 	}
 	if product.Stock < 0 {
 		return errors.New("product stock cannot be negative")
 	}
+	return nil
+}
 
-	return s.repo.Create(product)
+func (s *ProductService) CreateProduct(ctx context.Context, product *models.Product) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.CreateProduct")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	// Validate product data
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+
+	// Products are priced in the base currency and go on sale through
+	// price schedules only
+	price, err := inCurrency(product.Price, s.currency)
+	if err != nil {
+		return err
+	}
+	product.Price = price
+	product.Currency = s.currency
+	product.CompareAtPrice = nil
+
+	// Rating aggregates are derived from reviews
+	product.RatingAverage = 0
+	product.RatingCount = 0
+	product.RatingHistogram = models.RatingHistogram{}
+
+	if err := s.repo.WithContext(ctx).Create(product); err != nil {
+		return err
+	}
+
+	s.notifyCreated(product)
+	return nil
 }
 
-func (s *ProductService) GetProduct(id uint) (*models.Product, error) {
-	return s.repo.FindByID(id)
+func (s *ProductService) GetProduct(ctx context.Context, id uint) (_ *models.Product, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.GetProduct")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByID(id)
+}
+
+func (s *ProductService) GetAllProducts(ctx context.Context) (_ []models.Product, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.GetAllProducts")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindAll()
+}
+
+// ListProducts returns the products matching the filter
+func (s *ProductService) ListProducts(ctx context.Context, filter repository.ProductFilter) (_ []models.Product, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.ListProducts")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if filter.Sort != "" {
+		if _, ok := repository.ProductSortOrders[filter.Sort]; !ok {
+			return nil, errors.New("invalid sort")
+		}
+	}
+	if filter.MinRating < 0 || filter.MinRating > 5 {
+		return nil, errors.New("min_rating must be between 0 and 5")
+	}
+	return s.repo.WithContext(ctx).FindFiltered(filter)
 }
 
-func (s *ProductService) GetAllProducts() ([]models.Product, error) {
-	return s.repo.FindAll()
+// RecalculateRatings rebuilds the rating aggregates of every product
+func (s *ProductService) RecalculateRatings() error {
+	return s.repo.RefreshAllRatings()
 }
 
-func (s *ProductService) GetProductsByCategory(categoryID uint) ([]models.Product, error) {
-	return s.repo.FindByCategory(categoryID)
+func (s *ProductService) GetProductsByCategory(ctx context.Context, categoryID uint) (_ []models.Product, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.GetProductsByCategory")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByCategory(categoryID)
 }
 
-func (s *ProductService) UpdateProduct(product *models.Product) error {
+func (s *ProductService) UpdateProduct(ctx context.Context, product *models.Product) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.UpdateProduct")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if product exists
-	existingProduct, err := s.repo.FindByID(product.ID)
+	existingProduct, err := s.repo.WithContext(ctx).FindByID(product.ID)
 	if err != nil {
 		return errors.New("product not found")
 	}
 
 	// Validate product data
-	if product.Name == "" {
-		return errors.New("product name is required")
+	if err := validateProduct(product); err != nil {
+		return err
 	}
-	if product.Price <= 0 {
-		return errors.New("product price must be greater than 0")
-	}
-	if product.Stock < 0 {
-		return errors.New("product stock cannot be negative")
+	if product.Price, err = checkPriceChange(existingProduct, product.Price); err != nil {
+		return err
 	}
 
 	// Preserve some fields
 	product.CreatedAt = existingProduct.CreatedAt
+	product.Currency = existingProduct.Currency
+	product.CompareAtPrice = existingProduct.CompareAtPrice
+	product.RatingAverage = existingProduct.RatingAverage
+	product.RatingCount = existingProduct.RatingCount
+	product.RatingHistogram = existingProduct.RatingHistogram
 
-	return s.repo.Update(product)
+	var changes []models.OutboxEvent
+	if product.Stock != existingProduct.Stock {
+		changes = append(changes, events.ProductStockChanged(existingProduct, product.Stock))
+	}
+
+	var price *models.PriceChange
+	if !product.Price.Equal(existingProduct.Price) {
+		price = models.NewPriceChange(product, models.PriceChangeUpdated, nil, time.Now())
+	}
+
+	if err := s.repo.WithContext(ctx).Update(product, price, changes...); err != nil {
+		return err
+	}
+
+	s.notifyUpdated(existingProduct, product)
+	return nil
 }
 
-func (s *ProductService) DeleteProduct(id uint) error {
+// checkPriceChange returns price in the currency of the existing product,
+// refusing a price in another currency or a change while a sale runs
+func checkPriceChange(existing *models.Product, price money.Money) (money.Money, error) {
+	price, err := inCurrency(price, existing.Currency)
+	if err != nil {
+		return price, err
+	}
+
+	// A running sale sets the price until it ends
+	if existing.CompareAtPrice != nil && !price.Equal(existing.Price) {
+		return price, errors.New("product is on sale, end the sale or schedule a price change instead")
+	}
+	return price, nil
+}
+
+func (s *ProductService) DeleteProduct(ctx context.Context, id uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.DeleteProduct")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if product exists
-	_, err := s.repo.FindByID(id)
+	product, err := s.repo.WithContext(ctx).FindByID(id)
 	if err != nil {
 		return errors.New("product not found")
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.WithContext(ctx).Delete(id); err != nil {
+		return err
+	}
+
+	s.notifyDeleted(product)
+	return nil
 }
 
-func (s *ProductService) SearchProducts(query string) ([]models.Product, error) {
-	return s.repo.Search(query)
+// SearchProducts runs a ranked full-text search over active products and
+// returns one page of results with facet counts
+func (s *ProductService) SearchProducts(ctx context.Context, query string, filter repository.ProductFilter, page, pageSize int) (_ *repository.ProductSearchResult, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.SearchProducts")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if filter.Sort != "" {
+		if _, ok := repository.ProductSortOrders[filter.Sort]; !ok {
+			return nil, errors.New("invalid sort")
+		}
+	}
+	if filter.MinRating < 0 || filter.MinRating > 5 {
+		return nil, errors.New("min_rating must be between 0 and 5")
+	}
+
+	return s.repo.WithContext(ctx).Search(repository.ProductSearch{
+		Query:    query,
+		Filter:   filter,
+		Currency: s.currency,
+		Offset:   (page - 1) * pageSize,
+		Limit:    pageSize,
+	})
 }
 
-func (s *ProductService) UpdateStock(id uint, quantity int) error {
+func (s *ProductService) UpdateStock(ctx context.Context, id uint, quantity int) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProductService.UpdateStock")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if product exists
-	_, err := s.repo.FindByID(id)
+	product, err := s.repo.WithContext(ctx).FindByID(id)
 	if err != nil {
 		return errors.New("product not found")
 	}
@@ -92,5 +256,17 @@ func (s *ProductService) UpdateStock(id uint, quantity int) error {
 		return errors.New("stock quantity cannot be negative")
 	}
 
-	return s.repo.UpdateStock(id, quantity)
+	var changes []models.OutboxEvent
+	if quantity != product.Stock {
+		changes = append(changes, events.ProductStockChanged(product, quantity))
+	}
+
+	if err := s.repo.WithContext(ctx).UpdateStock(id, quantity, changes...); err != nil {
+		return err
+	}
+
+	updated := *product
+	updated.Stock = quantity
+	s.notifyUpdated(product, &updated)
+	return nil
 }
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+)
+
+// Bulk product file formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// productColumns is the column order of product CSV files
+var productColumns = []string{"sku", "name", "description", "price", "stock", "category", "is_active"}
+
+// requiredProductColumns must be present in an imported CSV header
+var requiredProductColumns = []string{"sku", "name", "price", "stock", "category"}
+
+// ProductRecord is one product in an import or export file. Categories are
+// referenced by name so files can move between stores.
+type ProductRecord struct {
+	SKU         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       json.Number `json:"price"` // Decimal in the base currency, parsed exactly on import
+	Stock       int         `json:"stock"`
+	Category    string      `json:"category"`
+	IsActive    *bool       `json:"is_active,omitempty"` // Defaults to true
+}
+
+// parsedRecord is a decoded row, or the reason it could not be decoded
+type parsedRecord struct {
+	record ProductRecord
+	err    error
+}
+
+// productRecord converts a product into its file representation
+func productRecord(p *models.Product) ProductRecord {
+	active := p.IsActive
+	return ProductRecord{
+		SKU:         p.SKU,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       json.Number(p.Price.Decimal()),
+		Stock:       p.Stock,
+		Category:    p.Category.Name,
+		IsActive:    &active,
+	}
+}
+
+// decodeProducts reads all records of a CSV or JSON product file. Rows that
+// cannot be parsed are returned with their error so the import can report
+// them alongside rows that fail validation.
+func decodeProducts(format string, r io.Reader) ([]parsedRecord, error) {
+	switch format {
+	case FormatCSV:
+		return decodeProductsCSV(r)
+	case FormatJSON:
+		var records []ProductRecord
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		parsed := make([]parsedRecord, len(records))
+		for i, rec := range records {
+			parsed[i] = parsedRecord{record: rec}
+		}
+		return parsed, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func decodeProductsCSV(r io.Reader) ([]parsedRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("missing CSV header")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredProductColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing CSV column %q", name)
+		}
+	}
+
+	var parsed []parsedRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			parsed = append(parsed, parsedRecord{err: err})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		rec := ProductRecord{
+			SKU:         field("sku"),
+			Name:        field("name"),
+			Description: field("description"),
+			Category:    field("category"),
+			Price:       json.Number(field("price")),
+		}
+		var rowErr error
+		if rec.Stock, err = strconv.Atoi(field("stock")); err != nil {
+			rowErr = fmt.Errorf("invalid stock %q", field("stock"))
+		} else if v := field("is_active"); v != "" {
+			active, err := strconv.ParseBool(v)
+			if err != nil {
+				rowErr = fmt.Errorf("invalid is_active %q", v)
+			}
+			rec.IsActive = &active
+		}
+		parsed = append(parsed, parsedRecord{record: rec, err: rowErr})
+	}
+	return parsed, nil
+}
+
+// encodeProducts writes products in the given format, readable by decodeProducts
+func encodeProducts(format string, w io.Writer, products []models.Product) error {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(productColumns); err != nil {
+			return err
+		}
+		for i := range products {
+			rec := productRecord(&products[i])
+			if err := writer.Write([]string{
+				rec.SKU,
+				rec.Name,
+				rec.Description,
+				rec.Price.String(),
+				strconv.Itoa(rec.Stock),
+				rec.Category,
+				strconv.FormatBool(*rec.IsActive),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatJSON:
+		records := make([]ProductRecord, len(products))
+		for i := range products {
+			records[i] = productRecord(&products[i])
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
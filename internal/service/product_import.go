@@ -0,0 +1,313 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/storage"
+)
+
+// RecentImportsLimit caps how many imports are listed for admins
+const RecentImportsLimit = 50
+
+const (
+	// ImportsQueue runs imports one at a time so they cannot race on SKUs
+	ImportsQueue     = "imports"
+	JobProductImport = "product_import"
+)
+
+// ProductImportJob is the payload of a JobProductImport job
+type ProductImportJob struct {
+	ImportID uint `json:"import_id"`
+}
+
+type ProductImportService struct {
+	repo         *repository.ProductImportRepository
+	products     *ProductService
+	productRepo  *repository.ProductRepository
+	categoryRepo *repository.CategoryRepository
+	store        storage.Store
+	queue        *jobs.Queue
+}
+
+func NewProductImportService(repo *repository.ProductImportRepository, products *ProductService, productRepo *repository.ProductRepository, categoryRepo *repository.CategoryRepository, store storage.Store, queue *jobs.Queue) *ProductImportService {
+	s := &ProductImportService{
+		repo:         repo,
+		products:     products,
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		store:        store,
+		queue:        queue,
+	}
+
+	queue.SetConcurrency(ImportsQueue, 1)
+	jobs.Register(queue, ImportsQueue, JobProductImport, func(ctx context.Context, job ProductImportJob) error {
+		return s.RunImport(ctx, job.ImportID)
+	})
+	return s
+}
+
+// FormatFromFileName infers the file format from its extension
+func FormatFromFileName(name string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+}
+
+func validFormat(format string) bool {
+	return format == FormatCSV || format == FormatJSON
+}
+
+// StartImport stores the uploaded file and processes it in the background.
+// The returned import reports progress once processing finishes.
+func (s *ProductImportService) StartImport(userID uint, fileName, format string, dryRun bool, data []byte) (*models.ProductImport, error) {
+	if !validFormat(format) {
+		return nil, errors.New("format must be csv or json")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	productImport := &models.ProductImport{
+		UserID:   userID,
+		Format:   format,
+		FileName: fileName,
+		DryRun:   dryRun,
+		Status:   models.ImportStatusPending,
+	}
+	// The import and its job are saved together, so a failure leaves no
+	// import that never runs
+	err := s.repo.Transaction(func(tx *repository.ProductImportRepository) error {
+		if err := tx.Create(productImport); err != nil {
+			return err
+		}
+
+		productImport.FilePath = fmt.Sprintf("imports/%d.%s", productImport.ID, format)
+		if _, err := s.store.Save(productImport.FilePath, data); err != nil {
+			return err
+		}
+		if err := tx.Update(productImport); err != nil {
+			return err
+		}
+
+		_, err := s.queue.EnqueueTx(tx.DB, JobProductImport, ProductImportJob{ImportID: productImport.ID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return productImport, nil
+}
+
+// RunImport processes a stored import file, upserting products by SKU. In
+// dry-run mode rows are only validated and the counts say what would change.
+// Problems with the file are recorded on the import rather than returned, so
+// only storage failures make the job retry.
+func (s *ProductImportService) RunImport(ctx context.Context, id uint) error {
+	productImport, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("import not found")
+	}
+	if productImport.FinishedAt != nil {
+		return nil
+	}
+
+	// A retried run starts over; upserting by SKU makes that safe
+	now := time.Now()
+	*productImport = models.ProductImport{
+		ID:        productImport.ID,
+		CreatedAt: productImport.CreatedAt,
+		UserID:    productImport.UserID,
+		Format:    productImport.Format,
+		FileName:  productImport.FileName,
+		FilePath:  productImport.FilePath,
+		DryRun:    productImport.DryRun,
+		Status:    models.ImportStatusRunning,
+		StartedAt: &now,
+	}
+	if err := s.repo.Update(productImport); err != nil {
+		return err
+	}
+
+	runErr := s.importFile(ctx, productImport)
+
+	finished := time.Now()
+	productImport.FinishedAt = &finished
+	productImport.Status = models.ImportStatusCompleted
+	if runErr != nil {
+		productImport.Status = models.ImportStatusFailed
+		productImport.Message = runErr.Error()
+	}
+	return s.repo.Update(productImport)
+}
+
+func (s *ProductImportService) importFile(ctx context.Context, productImport *models.ProductImport) error {
+	data, err := s.store.Open(productImport.FilePath)
+	if err != nil {
+		return errors.New("import file not found")
+	}
+
+	rows, err := decodeProducts(productImport.Format, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	productImport.TotalRows = len(rows)
+	productImport.Errors = []models.ImportRowError{}
+
+	seenSKUs := make(map[string]int)
+	// Category IDs by name, with 0 for categories a dry run would create
+	categories := make(map[string]uint)
+
+	for i, row := range rows {
+		rowNum := i + 1
+		fail := func(err error) {
+			productImport.Failed++
+			productImport.Errors = append(productImport.Errors, models.ImportRowError{
+				Row:   rowNum,
+				SKU:   row.record.SKU,
+				Error: err.Error(),
+			})
+		}
+
+		if row.err != nil {
+			fail(row.err)
+			continue
+		}
+		rec := row.record
+		if rec.SKU == "" {
+			fail(errors.New("sku is required"))
+			continue
+		}
+		if first, ok := seenSKUs[rec.SKU]; ok {
+			fail(fmt.Errorf("duplicate sku, first seen on row %d", first))
+			continue
+		}
+		seenSKUs[rec.SKU] = rowNum
+
+		created, err := s.importRecord(ctx, productImport, rec, categories)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		if created {
+			productImport.Created++
+		} else {
+			productImport.Updated++
+		}
+	}
+	return nil
+}
+
+// importRecord upserts one record and reports whether the product is new
+func (s *ProductImportService) importRecord(ctx context.Context, productImport *models.ProductImport, rec ProductRecord, categories map[string]uint) (bool, error) {
+	if rec.Category == "" {
+		return false, errors.New("category is required")
+	}
+
+	product, err := s.productRepo.FindBySKU(rec.SKU)
+	isNew := err != nil
+	if isNew {
+		product = &models.Product{SKU: rec.SKU, IsActive: true}
+	} else if product.DeletedAt.Valid {
+		return false, errors.New("sku belongs to a deleted product")
+	}
+	existing := *product
+
+	product.Name = rec.Name
+	product.Description = rec.Description
+	if product.Price, err = money.Parse(rec.Price.String(), s.products.currency); err != nil {
+		return false, fmt.Errorf("invalid price %q", rec.Price)
+	}
+	product.Stock = rec.Stock
+	if rec.IsActive != nil {
+		product.IsActive = *rec.IsActive
+	}
+
+	// Check the product rules before creating any category for it, and the
+	// ones UpdateProduct applies so a dry run reports them too
+	if err := validateProduct(product); err != nil {
+		return false, err
+	}
+	if !isNew {
+		if product.Price, err = checkPriceChange(&existing, product.Price); err != nil {
+			return false, err
+		}
+	}
+
+	categoryID, err := s.resolveCategory(productImport, rec.Category, categories)
+	if err != nil {
+		return false, err
+	}
+	product.CategoryID = categoryID
+
+	if productImport.DryRun {
+		return isNew, nil
+	}
+
+	if !isNew {
+		return false, s.products.UpdateProduct(ctx, product)
+	}
+	return true, s.products.CreateProduct(ctx, product)
+}
+
+// resolveCategory looks up a category by name, creating it when missing
+func (s *ProductImportService) resolveCategory(productImport *models.ProductImport, name string, categories map[string]uint) (uint, error) {
+	if id, ok := categories[name]; ok {
+		return id, nil
+	}
+
+	if category, err := s.categoryRepo.FindByName(name); err == nil {
+		categories[name] = category.ID
+		return category.ID, nil
+	}
+
+	productImport.CategoriesCreated++
+	if productImport.DryRun {
+		categories[name] = 0
+		return 0, nil
+	}
+
+	category := &models.Category{Name: name}
+	if err := s.categoryRepo.Create(category); err != nil {
+		productImport.CategoriesCreated--
+		return 0, fmt.Errorf("failed to create category %q", name)
+	}
+	categories[name] = category.ID
+	return category.ID, nil
+}
+
+func (s *ProductImportService) GetImport(id uint) (*models.ProductImport, error) {
+	productImport, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("import not found")
+	}
+	return productImport, nil
+}
+
+// ListImports returns the most recent imports without their row errors
+func (s *ProductImportService) ListImports() ([]models.ProductImport, error) {
+	return s.repo.FindRecent(RecentImportsLimit)
+}
+
+// ExportProducts writes the products matching the filter in a format that
+// StartImport accepts
+func (s *ProductImportService) ExportProducts(ctx context.Context, format string, filter repository.ProductFilter, w io.Writer) error {
+	if !validFormat(format) {
+		return errors.New("format must be csv or json")
+	}
+	products, err := s.products.ListProducts(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return encodeProducts(format, w, products)
+}
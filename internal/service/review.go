@@ -1,42 +1,71 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/sajal/go-ecommerce/internal/events"
 	"github.com/sajal/go-ecommerce/internal/models"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/storage"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 )
 
+// MaxReviewImages caps the number of images attached to one review
+const MaxReviewImages = 5
+
+// ReviewImagesURL is the path review images are served from, followed by
+// /<review ID>/images/<name>
+const ReviewImagesURL = "/api/v1/reviews"
+
+// reviewImageTypes maps accepted image content types to file extensions
+var reviewImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
 type ReviewService struct {
 	repo        *repository.ReviewRepository
 	productRepo *repository.ProductRepository
 	orderRepo   *repository.OrderRepository
+	store       storage.Store
 }
 
-func NewReviewService(repo *repository.ReviewRepository, productRepo *repository.ProductRepository, orderRepo *repository.OrderRepository) *ReviewService {
+func NewReviewService(repo *repository.ReviewRepository, productRepo *repository.ProductRepository, orderRepo *repository.OrderRepository, store storage.Store) *ReviewService {
 	return &ReviewService{
 		repo:        repo,
 		productRepo: productRepo,
 		orderRepo:   orderRepo,
+		store:       store,
 	}
 }
 
-func (s *ReviewService) CreateReview(userID uint, productID uint, rating int, comment string) error {
+// CreateReview adds a review from a verified buyer. New reviews wait in the
+// moderation queue until an admin approves them.
+func (s *ReviewService) CreateReview(ctx context.Context, userID uint, productID uint, rating int, title string, comment string) (_ *models.Review, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.CreateReview")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Check if product exists
-	_, err := s.productRepo.FindByID(productID)
+	_, err = s.productRepo.WithContext(ctx).FindByID(productID)
 	if err != nil {
-		return errors.New("product not found")
+		return nil, errors.New("product not found")
 	}
 
 	// Check if user has purchased the product
-	orders, err := s.orderRepo.FindByUserID(userID)
+	orders, err := s.orderRepo.WithContext(ctx).FindByUserID(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hasPurchased := false
 	for _, order := range orders {
-		if order.Status == "delivered" {
+		if order.Status == models.OrderStatusDelivered {
 			for _, item := range order.Items {
 				if item.ProductID == productID {
 					hasPurchased = true
@@ -50,70 +79,141 @@ func (s *ReviewService) CreateReview(userID uint, productID uint, rating int, co
 	}
 
 	if !hasPurchased {
-		return errors.New("you must purchase the product before reviewing")
+		return nil, errors.New("you must purchase the product before reviewing")
 	}
 
 	// Check if user has already reviewed the product
-	_, err = s.repo.FindByUserAndProduct(userID, productID)
+	_, err = s.repo.WithContext(ctx).FindByUserAndProduct(userID, productID)
 	if err == nil {
-		return errors.New("you have already reviewed this product")
+		return nil, errors.New("you have already reviewed this product")
 	}
 
 	// Validate rating
 	if rating < 1 || rating > 5 {
-		return errors.New("rating must be between 1 and 5")
+		return nil, errors.New("rating must be between 1 and 5")
+	}
+	if len(title) > 100 {
+		return nil, errors.New("title must be at most 100 characters")
 	}
 
 	// Create review
 	review := &models.Review{
-		UserID:    userID,
-		ProductID: productID,
-		Rating:    rating,
-		Comment:   comment,
+		UserID:     userID,
+		ProductID:  productID,
+		Rating:     rating,
+		Title:      title,
+		Comment:    comment,
+		IsVerified: true,
+		Status:     models.ReviewStatusPending,
+	}
+
+	if err := s.repo.WithContext(ctx).Create(review, events.ReviewPosted(review)); err != nil {
+		return nil, err
 	}
+	if err := s.productRepo.WithContext(ctx).RefreshRating(productID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
 
-	return s.repo.Create(review)
+func (s *ReviewService) GetReview(ctx context.Context, id uint) (_ *models.Review, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.GetReview")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByID(id)
 }
 
-func (s *ReviewService) GetReview(id uint) (*models.Review, error) {
-	return s.repo.FindByID(id)
+func (s *ReviewService) GetProductReviews(ctx context.Context, productID uint) (_ []models.Review, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.GetProductReviews")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByProductID(productID)
 }
 
-func (s *ReviewService) GetProductReviews(productID uint) ([]models.Review, error) {
-	return s.repo.FindByProductID(productID)
+// ListProductReviews returns a page of a product's approved reviews
+func (s *ReviewService) ListProductReviews(ctx context.Context, productID uint, sort string, page, pageSize int) (_ []models.Review, _ int64, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.ListProductReviews")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if sort == "" {
+		sort = "newest"
+	}
+	if _, ok := repository.ReviewSortOrders[sort]; !ok {
+		return nil, 0, fmt.Errorf("invalid sort %q", sort)
+	}
+	return s.repo.WithContext(ctx).FindByProductIDPaged(productID, models.ReviewStatusApproved, sort, (page-1)*pageSize, pageSize)
 }
 
-func (s *ReviewService) GetUserReviews(userID uint) ([]models.Review, error) {
-	return s.repo.FindByUserID(userID)
+// ListModerationQueue returns a page of reviews in the given status
+func (s *ReviewService) ListModerationQueue(ctx context.Context, status models.ReviewStatus, page, pageSize int) (_ []models.Review, _ int64, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.ListModerationQueue")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	switch status {
+	case "":
+		status = models.ReviewStatusPending
+	case models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected:
+	default:
+		return nil, 0, errors.New("invalid review status")
+	}
+	return s.repo.WithContext(ctx).FindByStatus(status, (page-1)*pageSize, pageSize)
 }
 
-func (s *ReviewService) UpdateReview(userID uint, reviewID uint, rating int, comment string) error {
+func (s *ReviewService) GetUserReviews(ctx context.Context, userID uint) (_ []models.Review, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.GetUserReviews")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByUserID(userID)
+}
+
+// UpdateReview edits a review and sends it back to the moderation queue
+func (s *ReviewService) UpdateReview(ctx context.Context, userID uint, reviewID uint, rating int, title string, comment string) (_ *models.Review, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.UpdateReview")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Get review
-	review, err := s.repo.FindByID(reviewID)
+	review, err := s.repo.WithContext(ctx).FindByID(reviewID)
 	if err != nil {
-		return errors.New("review not found")
+		return nil, errors.New("review not found")
 	}
 
 	// Check ownership
 	if review.UserID != userID {
-		return errors.New("unauthorized")
+		return nil, errors.New("unauthorized")
 	}
 
 	// Validate rating
 	if rating < 1 || rating > 5 {
-		return errors.New("rating must be between 1 and 5")
+		return nil, errors.New("rating must be between 1 and 5")
+	}
+	if len(title) > 100 {
+		return nil, errors.New("title must be at most 100 characters")
 	}
 
 	// Update review
 	review.Rating = rating
+	review.Title = title
 	review.Comment = comment
+	review.Status = models.ReviewStatusPending
+	review.ModerationNote = ""
+	review.ModeratedBy = nil
+	review.ModeratedAt = nil
 
-	return s.repo.Update(review)
+	if err := s.repo.WithContext(ctx).Update(review); err != nil {
+		return nil, err
+	}
+	if err := s.productRepo.WithContext(ctx).RefreshRating(review.ProductID); err != nil {
+		return nil, err
+	}
+	return review, nil
 }
 
-func (s *ReviewService) DeleteReview(userID uint, reviewID uint) error {
+func (s *ReviewService) DeleteReview(ctx context.Context, userID uint, reviewID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.DeleteReview")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Get review
-	review, err := s.repo.FindByID(reviewID)
+	review, err := s.repo.WithContext(ctx).FindByID(reviewID)
 	if err != nil {
 		return errors.New("review not found")
 	}
@@ -123,5 +223,153 @@ func (s *ReviewService) DeleteReview(userID uint, reviewID uint) error {
 		return errors.New("unauthorized")
 	}
 
-	return s.repo.Delete(reviewID)
+	if err := s.repo.WithContext(ctx).Delete(reviewID); err != nil {
+		return err
+	}
+	return s.productRepo.WithContext(ctx).RefreshRating(review.ProductID)
+}
+
+// ModerateReview approves or rejects a review on behalf of an admin
+func (s *ReviewService) ModerateReview(ctx context.Context, moderatorID uint, reviewID uint, approve bool, note string) (_ *models.Review, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.ModerateReview")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	review, err := s.repo.WithContext(ctx).FindByID(reviewID)
+	if err != nil {
+		return nil, errors.New("review not found")
+	}
+
+	now := time.Now()
+	review.Status = models.ReviewStatusRejected
+	if approve {
+		review.Status = models.ReviewStatusApproved
+	}
+	review.ModerationNote = note
+	review.ModeratedBy = &moderatorID
+	review.ModeratedAt = &now
+
+	if err := s.repo.WithContext(ctx).Update(review); err != nil {
+		return nil, err
+	}
+	if err := s.productRepo.WithContext(ctx).RefreshRating(review.ProductID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// VoteHelpful marks an approved review as helpful for the user. Voting twice
+// has no further effect.
+func (s *ReviewService) VoteHelpful(ctx context.Context, userID uint, reviewID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.VoteHelpful")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	review, err := s.repo.WithContext(ctx).FindByID(reviewID)
+	if err != nil || review.Status != models.ReviewStatusApproved {
+		return errors.New("review not found")
+	}
+	if review.UserID == userID {
+		return errors.New("you cannot vote on your own review")
+	}
+
+	_, err = s.repo.WithContext(ctx).AddVote(reviewID, userID)
+	return err
+}
+
+// RemoveHelpfulVote withdraws the user's helpful vote, if any
+func (s *ReviewService) RemoveHelpfulVote(ctx context.Context, userID uint, reviewID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.RemoveHelpfulVote")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if _, err := s.repo.WithContext(ctx).FindByID(reviewID); err != nil {
+		return errors.New("review not found")
+	}
+	return s.repo.WithContext(ctx).RemoveVote(reviewID, userID)
+}
+
+// AddImage attaches an uploaded image to the user's review. The image is kept
+// in the private store and the review goes back to the moderation queue, so
+// nothing is shown publicly before an admin has seen it.
+func (s *ReviewService) AddImage(ctx context.Context, userID uint, reviewID uint, data []byte) (_ *models.ReviewImage, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.AddImage")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	review, err := s.repo.WithContext(ctx).FindByID(reviewID)
+	if err != nil {
+		return nil, errors.New("review not found")
+	}
+	if review.UserID != userID {
+		return nil, errors.New("unauthorized")
+	}
+
+	count, err := s.repo.WithContext(ctx).CountImages(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxReviewImages {
+		return nil, fmt.Errorf("a review can have at most %d images", MaxReviewImages)
+	}
+
+	ext, ok := reviewImageTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, errors.New("unsupported image type")
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	name := token[:16] + ext
+	filePath := fmt.Sprintf("reviews/%d/%s", reviewID, name)
+	if _, err := s.store.Save(filePath, data); err != nil {
+		return nil, err
+	}
+
+	image := &models.ReviewImage{
+		ReviewID: reviewID,
+		URL:      fmt.Sprintf("%s/%d/images/%s", ReviewImagesURL, reviewID, name),
+		FilePath: filePath,
+	}
+	if err := s.repo.WithContext(ctx).AddImage(image); err != nil {
+		return nil, err
+	}
+
+	if review.Status != models.ReviewStatusPending {
+		review.Status = models.ReviewStatusPending
+		review.ModerationNote = ""
+		review.ModeratedBy = nil
+		review.ModeratedAt = nil
+		if err := s.repo.WithContext(ctx).Update(review); err != nil {
+			return nil, err
+		}
+		if err := s.productRepo.WithContext(ctx).RefreshRating(review.ProductID); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+// GetImage returns an image of a review with its content type. Images of
+// reviews that are not approved are only shown to their author and to
+// staff.
+func (s *ReviewService) GetImage(ctx context.Context, reviewID uint, name string, viewerID uint, staff bool) (_ []byte, _ string, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ReviewService.GetImage")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	review, err := s.repo.WithContext(ctx).FindByID(reviewID)
+	if err != nil {
+		return nil, "", errors.New("image not found")
+	}
+	if review.Status != models.ReviewStatusApproved && review.UserID != viewerID && !staff {
+		return nil, "", errors.New("image not found")
+	}
+
+	image, err := s.repo.WithContext(ctx).FindImage(reviewID, fmt.Sprintf("reviews/%d/%s", reviewID, name))
+	if err != nil {
+		return nil, "", errors.New("image not found")
+	}
+	data, err := s.store.Open(image.FilePath)
+	if err != nil {
+		return nil, "", errors.New("image not found")
+	}
+	return data, http.DetectContentType(data), nil
 }
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/search"
+)
+
+const (
+	// popularQueryWindow is how far back past queries count as suggestions
+	popularQueryWindow = 30 * 24 * time.Hour
+	// popularQueryLimit caps the past queries loaded into the index
+	popularQueryLimit = 1000
+	// SuggestLimit is the number of suggestions returned per kind
+	SuggestLimit = 8
+	// suggestRefreshInterval is how often the suggestion index is rebuilt
+	// from the database, picking up catalog changes made through other
+	// servers or without ProductService, such as new categories, and the
+	// queries searched on other servers
+	suggestRefreshInterval = 5 * time.Minute
+)
+
+// SearchService answers autocomplete requests from an in-memory prefix
+// index and logs storefront searches
+type SearchService struct {
+	repo         *repository.SearchRepository
+	productRepo  *repository.ProductRepository
+	categoryRepo *repository.CategoryRepository
+	index        *search.SuggestIndex
+
+	wg sync.WaitGroup
+}
+
+func NewSearchService(repo *repository.SearchRepository, productRepo *repository.ProductRepository, categoryRepo *repository.CategoryRepository) *SearchService {
+	return &SearchService{
+		repo:         repo,
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		index:        search.NewSuggestIndex(),
+	}
+}
+
+// RebuildIndex loads active products, categories and recent popular queries
+// into the suggestion index
+func (s *SearchService) RebuildIndex() error {
+	products, err := s.productRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	popular, err := s.repo.PopularQueries(time.Now().Add(-popularQueryWindow), search.MinQueryCount, popularQueryLimit)
+	if err != nil {
+		return err
+	}
+
+	var productSuggestions []search.Suggestion
+	for _, p := range products {
+		if p.IsActive {
+			productSuggestions = append(productSuggestions, productSuggestion(&p))
+		}
+	}
+	var categorySuggestions []search.Suggestion
+	for _, c := range categories {
+		categorySuggestions = append(categorySuggestions, search.Suggestion{Kind: search.KindCategory, ID: c.ID, Text: c.Name})
+	}
+	queries := make(map[string]int64, len(popular))
+	for _, q := range popular {
+		queries[q.Query] = q.Count
+	}
+
+	s.index.Replace(productSuggestions, categorySuggestions, queries)
+	return nil
+}
+
+// Start builds the suggestion index now and then rebuilds it at the refresh
+// interval until ctx is cancelled
+func (s *SearchService) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.run(ctx)
+}
+
+// Wait blocks until the refresh loop has stopped
+func (s *SearchService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *SearchService) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(suggestRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RebuildIndex(); err != nil {
+			log.Printf("Failed to build search suggestion index: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reindex rebuilds the full-text search data of the catalog in the
+// database. Suggestion indexes live in each server and are rebuilt by
+// Start.
+func (s *SearchService) Reindex() error {
+	return s.productRepo.Reindex()
+}
+
+func productSuggestion(p *models.Product) search.Suggestion {
+	return search.Suggestion{Kind: search.KindProduct, ID: p.ID, Text: p.Name}
+}
+
+// Suggest returns products, categories and past queries starting with prefix
+func (s *SearchService) Suggest(prefix string) search.Suggestions {
+	return s.index.Suggest(prefix, SuggestLimit)
+}
+
+// LogQuery records a storefront search. Searches that found products become
+// query suggestions. Failures are logged and never fail the search.
+func (s *SearchService) LogQuery(query string, resultCount int64) {
+	q := search.Normalize(query)
+	if q == "" {
+		return
+	}
+	if r := []rune(q); len(r) > 200 {
+		q = string(r[:200])
+	}
+
+	if err := s.repo.LogQuery(&models.SearchQuery{Query: q, ResultCount: resultCount}); err != nil {
+		log.Printf("search: failed to log query: %v", err)
+	}
+	if resultCount > 0 {
+		s.index.RecordQuery(q)
+	}
+}
+
+// ZeroResultQueries reports the searches of the last days that found nothing
+func (s *SearchService) ZeroResultQueries(days int, limit int) ([]repository.QueryStat, error) {
+	return s.repo.ZeroResultQueries(time.Now().AddDate(0, 0, -days), limit)
+}
+
+// ProductCreated adds an active product to the index
+func (s *SearchService) ProductCreated(product *models.Product) {
+	if product.IsActive {
+		s.index.Put(productSuggestion(product))
+	}
+}
+
+// ProductUpdated reindexes a product, dropping it once it is deactivated
+func (s *SearchService) ProductUpdated(before, after *models.Product) {
+	if after.IsActive {
+		s.index.Put(productSuggestion(after))
+	} else {
+		s.index.Remove(search.KindProduct, after.ID)
+	}
+}
+
+// ProductDeleted removes a product from the index
+func (s *SearchService) ProductDeleted(product *models.Product) {
+	s.index.Remove(search.KindProduct, product.ID)
+}
@@ -1,12 +1,27 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 
+	"github.com/sajal/go-ecommerce/internal/events"
 	"github.com/sajal/go-ecommerce/internal/models"
 	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
 )
 
+// Reasons an account may not sign in
+var (
+	ErrAccountDisabled       = errors.New("account is disabled")
+	ErrAccountBanned         = errors.New("account is banned")
+	ErrPasswordResetRequired = errors.New("password reset required, check your email for the reset link")
+)
+
+// MinPasswordLength is the shortest password accepted
+const MinPasswordLength = 6
+
 type UserService struct {
 	repo *repository.UserRepository
 }
@@ -24,10 +39,36 @@ func (s *UserService) Register(user *models.User) error {
 
 	// Set default role if not specified
 	if user.Role == "" {
-		user.Role = "user"
+		user.Role = models.RoleUser
+	}
+	user.Status = models.UserStatusActive
+
+	return s.repo.Create(user, events.UserRegistered(user))
+}
+
+// CreateAdmin registers an admin account and reports that it did. With
+// promote set, an existing account with the email is made an admin instead;
+// its name and password are kept.
+func (s *UserService) CreateAdmin(email, name, password string, promote bool) (*models.User, bool, error) {
+	if existing, err := s.repo.FindByEmail(email); err == nil && existing != nil {
+		if !promote {
+			return nil, false, errors.New("email already registered")
+		}
+		if err := s.repo.UpdateFields(existing.ID, map[string]interface{}{"role": models.RoleAdmin}); err != nil {
+			return nil, false, err
+		}
+		existing.Role = models.RoleAdmin
+		return existing, false, nil
 	}
 
-	return s.repo.Create(user)
+	if len(password) < MinPasswordLength {
+		return nil, false, errors.New("password must be at least 6 characters")
+	}
+	user := &models.User{Email: email, Name: name, Password: password, Role: models.RoleAdmin}
+	if err := s.Register(user); err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
 }
 
 func (s *UserService) GetUser(id uint) (*models.User, error) {
@@ -78,3 +119,61 @@ func (s *UserService) DeleteUser(id uint) error {
 
 	return s.repo.Delete(id)
 }
+
+// checkAccess reports why the user may not use the account, if anything
+func checkAccess(user *models.User) error {
+	switch user.Status {
+	case models.UserStatusDisabled:
+		return ErrAccountDisabled
+	case models.UserStatusBanned:
+		return ErrAccountBanned
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// Authorize loads the user behind a request or sign-in and refuses disabled
+// and banned accounts and those that must reset their password first
+func (s *UserService) Authorize(ctx context.Context, id uint) (_ *models.User, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "UserService.Authorize")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	user, err := s.repo.WithContext(ctx).FindByID(id)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if err := checkAccess(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// hashResetToken is the form reset tokens are stored and looked up in
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ResetPassword sets a new password with an emailed reset token, which then
+// stops working, and returns the account's ID. It also lifts a forced
+// password reset.
+func (s *UserService) ResetPassword(token string, password string) (uint, error) {
+	if len(password) < MinPasswordLength {
+		return 0, errors.New("password must be at least 6 characters")
+	}
+
+	resetToken, err := s.repo.FindResetToken(hashResetToken(token))
+	if err != nil {
+		return 0, errors.New("reset link is invalid or has expired")
+	}
+	ok, err := s.repo.ResetPassword(resetToken, password)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errors.New("reset link is invalid or has expired")
+	}
+	return resetToken.UserID, nil
+}
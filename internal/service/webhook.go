@@ -0,0 +1,326 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/events"
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+)
+
+// Webhook request headers
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-ID" // Event ID; the same across retries, for deduplication
+)
+
+const (
+	WebhooksQueue      = "webhooks"
+	JobDeliverWebhook  = "webhook.deliver"
+	webhookMaxAttempts = 8
+	// WebhookDisableAfter is how many consecutive failed deliveries disable an endpoint
+	WebhookDisableAfter = 20
+	webhookTimeout      = 10 * time.Second
+	maxResponseLogBytes = 1024
+)
+
+// WebhookBody is the JSON body posted to webhook endpoints
+type WebhookBody struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookJob is the payload of a JobDeliverWebhook job. It carries the body
+// so deliveries do not depend on the event still being in the outbox.
+type WebhookJob struct {
+	EndpointID uint            `json:"endpoint_id"`
+	EventID    uint            `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	queue  *jobs.Queue
+	client *http.Client
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, queue *jobs.Queue) *WebhookService {
+	s := &WebhookService{
+		repo:   repo,
+		queue:  queue,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+	jobs.Register(queue, WebhooksQueue, JobDeliverWebhook, s.deliver)
+	return s
+}
+
+// SignWebhook computes the signature header value for a body sent at timestamp
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func validateWebhookEndpoint(endpoint *models.WebhookEndpoint) error {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(endpoint.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	for _, t := range endpoint.EventTypes {
+		known := false
+		for _, k := range events.Types {
+			if t == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return nil
+}
+
+// CreateEndpoint subscribes a URL to events, generating a secret when none is given
+func (s *WebhookService) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	if err := validateWebhookEndpoint(endpoint); err != nil {
+		return err
+	}
+	if endpoint.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return err
+		}
+		endpoint.Secret = secret
+	}
+	endpoint.IsActive = true
+	endpoint.FailureCount = 0
+	return s.repo.Create(endpoint)
+}
+
+func (s *WebhookService) GetEndpoint(id uint) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("webhook not found")
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) ListEndpoints() ([]models.WebhookEndpoint, error) {
+	return s.repo.FindAll()
+}
+
+// UpdateEndpoint changes the URL, events and secret of an endpoint. An empty
+// secret keeps the current one and a nil isActive keeps the current state.
+// Reactivating an endpoint clears its failure history.
+func (s *WebhookService) UpdateEndpoint(id uint, rawURL string, eventTypes []string, secret string, isActive *bool) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("webhook not found")
+	}
+
+	endpoint.URL = rawURL
+	endpoint.EventTypes = eventTypes
+	if secret != "" {
+		endpoint.Secret = secret
+	}
+	if err := validateWebhookEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	if isActive != nil {
+		if *isActive && !endpoint.IsActive {
+			endpoint.FailureCount = 0
+			endpoint.DisabledAt = nil
+			endpoint.DisabledReason = ""
+		}
+		endpoint.IsActive = *isActive
+	}
+
+	if err := s.repo.Update(endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) DeleteEndpoint(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return errors.New("webhook not found")
+	}
+	return s.repo.Delete(id)
+}
+
+// ListDeliveries returns a page of an endpoint's delivery log, newest first
+func (s *WebhookService) ListDeliveries(endpointID uint, page, pageSize int) ([]models.WebhookDelivery, int64, error) {
+	if _, err := s.repo.FindByID(endpointID); err != nil {
+		return nil, 0, errors.New("webhook not found")
+	}
+	return s.repo.FindDeliveries(endpointID, (page-1)*pageSize, pageSize)
+}
+
+// ResendDelivery queues the body of a logged delivery to its endpoint again
+func (s *WebhookService) ResendDelivery(deliveryID uint) (*models.Job, error) {
+	delivery, err := s.repo.FindDeliveryByID(deliveryID)
+	if err != nil {
+		return nil, errors.New("delivery not found")
+	}
+	endpoint, err := s.repo.FindByID(delivery.EndpointID)
+	if err != nil {
+		return nil, errors.New("webhook not found")
+	}
+	if !endpoint.IsActive {
+		return nil, errors.New("webhook is disabled")
+	}
+
+	return s.queue.Enqueue(JobDeliverWebhook, WebhookJob{
+		EndpointID: delivery.EndpointID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Body:       delivery.Body,
+	}, jobs.MaxAttempts(webhookMaxAttempts))
+}
+
+// HandleEvent is an events.Subscriber that queues a delivery to every
+// active endpoint subscribed to the event. Delivery is at least once;
+// receivers should deduplicate on the X-Webhook-ID header.
+func (s *WebhookService) HandleEvent(ctx context.Context, event events.Event) error {
+	endpoints, err := s.repo.FindActive()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(WebhookBody{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.OccurredAt,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		_, err := s.queue.Enqueue(JobDeliverWebhook, WebhookJob{
+			EndpointID: endpoint.ID,
+			EventID:    event.ID,
+			EventType:  event.Type,
+			Body:       body,
+		}, jobs.MaxAttempts(webhookMaxAttempts))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver posts one signed webhook and logs the attempt. Failures are
+// returned so the job queue retries them with backoff.
+func (s *WebhookService) deliver(ctx context.Context, job WebhookJob) error {
+	endpoint, err := s.repo.FindByID(job.EndpointID)
+	if err != nil || !endpoint.IsActive {
+		// Deleted or disabled since the delivery was queued
+		return nil
+	}
+
+	delivery := &models.WebhookDelivery{
+		EndpointID: endpoint.ID,
+		EventID:    job.EventID,
+		EventType:  job.EventType,
+		Body:       job.Body,
+		Attempt:    jobs.Attempt(ctx),
+	}
+
+	start := time.Now()
+	statusCode, responseBody, sendErr := s.send(ctx, endpoint, job)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.StatusCode = statusCode
+	delivery.ResponseBody = responseBody
+	delivery.Success = sendErr == nil
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+
+	if err := s.repo.CreateDelivery(delivery); err != nil {
+		log.Printf("Failed to log webhook delivery to endpoint %d: %v", endpoint.ID, err)
+	}
+
+	if sendErr == nil {
+		return s.repo.ResetFailures(endpoint.ID)
+	}
+
+	failures, err := s.repo.RecordFailure(endpoint.ID)
+	if err != nil {
+		return err
+	}
+	if failures >= WebhookDisableAfter {
+		reason := fmt.Sprintf("disabled after %d consecutive failed deliveries", failures)
+		if err := s.repo.Disable(endpoint.ID, reason); err != nil {
+			return err
+		}
+		log.Printf("Webhook endpoint %d %s", endpoint.ID, reason)
+		return nil
+	}
+	return sendErr
+}
+
+func (s *WebhookService) send(ctx context.Context, endpoint *models.WebhookEndpoint, job WebhookJob) (int, string, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(job.Body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-ecommerce-webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, job.EventType)
+	req.Header.Set(WebhookIDHeader, strconv.FormatUint(uint64(job.EventID), 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, timestamp, job.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseLogBytes))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
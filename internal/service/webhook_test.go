@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sajal/go-ecommerce/internal/events"
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/migrations"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the Postgres database in TEST_DATABASE_URL, migrates
+// it and empties the given tables. Tests needing it are skipped when the
+// variable is not set.
+func testDB(t *testing.T, tables ...string) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	migrator.Settings = map[string]string{"app.base_currency": "USD"}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// receivedWebhook is a request the test receiver got
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver is a local HTTP endpoint answering with the scripted
+// statuses in turn, then 200
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	received chan receivedWebhook
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	r := &webhookReceiver{statuses: statuses, received: make(chan receivedWebhook, 16)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+		r.received <- receivedWebhook{header: req.Header.Clone(), body: body}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) next(t *testing.T) receivedWebhook {
+	t.Helper()
+	select {
+	case got := <-r.received:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook received")
+		return receivedWebhook{}
+	}
+}
+
+type webhookTest struct {
+	db       *gorm.DB
+	repo     *repository.WebhookRepository
+	queue    *jobs.Queue
+	service  *WebhookService
+	endpoint *models.WebhookEndpoint
+	receiver *webhookReceiver
+}
+
+func newWebhookTest(t *testing.T, statuses ...int) *webhookTest {
+	db := testDB(t, "webhook_endpoints", "webhook_deliveries", "jobs")
+	w := &webhookTest{
+		db:       db,
+		repo:     repository.NewWebhookRepository(db),
+		receiver: newWebhookReceiver(t, statuses...),
+	}
+	w.queue = jobs.NewQueue(repository.NewJobRepository(db))
+	w.queue.PollInterval = 10 * time.Millisecond
+	w.service = NewWebhookService(w.repo, w.queue)
+
+	w.endpoint = &models.WebhookEndpoint{
+		URL:        w.receiver.URL,
+		Secret:     "whsec_test",
+		EventTypes: []string{events.TypeOrderPlaced},
+	}
+	if err := w.service.CreateEndpoint(w.endpoint); err != nil {
+		t.Fatalf("create endpoint: %v", err)
+	}
+	return w
+}
+
+// start runs the queue's workers until the test ends
+func (w *webhookTest) start(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.queue.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		w.queue.Wait()
+	})
+}
+
+func (w *webhookTest) event() events.Event {
+	return events.Event{
+		ID:         42,
+		Type:       events.TypeOrderPlaced,
+		OccurredAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Payload:    json.RawMessage(`{"order_id":7}`),
+	}
+}
+
+func (w *webhookTest) job(t *testing.T) WebhookJob {
+	return webhookJob(t, w.endpoint.ID)
+}
+
+// webhookJob is the delivery of the test event to the given endpoint
+func webhookJob(t *testing.T, endpointID uint) WebhookJob {
+	body, err := json.Marshal(WebhookBody{ID: 42, Type: events.TypeOrderPlaced, Data: json.RawMessage(`{"order_id":7}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return WebhookJob{EndpointID: endpointID, EventID: 42, EventType: events.TypeOrderPlaced, Body: body}
+}
+
+func (w *webhookTest) deliveries(t *testing.T) []models.WebhookDelivery {
+	t.Helper()
+	var deliveries []models.WebhookDelivery
+	if err := w.db.Order("id").Find(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+	return deliveries
+}
+
+func (w *webhookTest) reload(t *testing.T) *models.WebhookEndpoint {
+	t.Helper()
+	endpoint, err := w.repo.FindByID(w.endpoint.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return endpoint
+}
+
+// waitForJob polls the job until done accepts it
+func (w *webhookTest) waitForJob(t *testing.T, id uint, done func(*models.Job) bool) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job models.Job
+		if err := w.db.First(&job, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if done(&job) {
+			return &job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d stuck in status %s after %d attempts", id, job.Status, job.Attempts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	s := &WebhookService{client: &http.Client{Timeout: webhookTimeout}}
+	endpoint := &models.WebhookEndpoint{ID: 1, URL: receiver.URL, Secret: "whsec_test"}
+	job := webhookJob(t, endpoint.ID)
+
+	status, response, err := s.send(context.Background(), endpoint, job)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if status != http.StatusOK || response != "OK" {
+		t.Errorf("send = %d %q, want 200 OK", status, response)
+	}
+	got := receiver.next(t)
+
+	if string(got.body) != string(job.Body) {
+		t.Errorf("body = %s, want %s", got.body, job.Body)
+	}
+	if ct := got.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type = %q, want application/json", ct)
+	}
+	timestamp := got.header.Get(WebhookTimestampHeader)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header %q: %v", timestamp, err)
+	}
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(timestamp + "." + string(got.body)))
+	want := "v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got.header.Get(WebhookSignatureHeader) != want {
+		t.Errorf("signature = %q, want %q", got.header.Get(WebhookSignatureHeader), want)
+	}
+	if sig := SignWebhook("whsec_test", ts, got.body); sig != want {
+		t.Errorf("SignWebhook = %q, want %q", sig, want)
+	}
+	if got.header.Get(WebhookEventHeader) != events.TypeOrderPlaced {
+		t.Errorf("event header = %q", got.header.Get(WebhookEventHeader))
+	}
+	if got.header.Get(WebhookIDHeader) != "42" {
+		t.Errorf("id header = %q, want 42", got.header.Get(WebhookIDHeader))
+	}
+}
+
+func TestWebhookSendReportsFailedStatus(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	s := &WebhookService{client: &http.Client{Timeout: webhookTimeout}}
+	endpoint := &models.WebhookEndpoint{ID: 1, URL: receiver.URL, Secret: "whsec_test"}
+
+	status, response, err := s.send(context.Background(), endpoint, webhookJob(t, endpoint.ID))
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("send error = %v, want the status", err)
+	}
+	if status != http.StatusServiceUnavailable || response != "Service Unavailable" {
+		t.Errorf("send = %d %q, want the receiver's answer", status, response)
+	}
+	receiver.next(t)
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	w := newWebhookTest(t, http.StatusServiceUnavailable)
+	w.start(t)
+
+	if err := w.service.HandleEvent(context.Background(), w.event()); err != nil {
+		t.Fatalf("handle event: %v", err)
+	}
+	first := w.receiver.next(t)
+
+	var queued models.Job
+	if err := w.db.Where("type = ?", JobDeliverWebhook).First(&queued).Error; err != nil {
+		t.Fatal(err)
+	}
+	job := w.waitForJob(t, queued.ID, func(j *models.Job) bool {
+		return j.Status == models.JobStatusPending && j.Attempts == 1
+	})
+	if wait := time.Until(job.RunAt); wait < 5*time.Second {
+		t.Errorf("retry due in %s, want a backoff", wait)
+	}
+	if !strings.Contains(job.LastError, "503") {
+		t.Errorf("last error = %q, want the status", job.LastError)
+	}
+	if endpoint := w.reload(t); endpoint.FailureCount != 1 {
+		t.Errorf("failure count = %d, want 1", endpoint.FailureCount)
+	}
+
+	// Skip the backoff
+	if err := w.db.Model(job).Update("run_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	second := w.receiver.next(t)
+	w.waitForJob(t, job.ID, func(j *models.Job) bool { return j.Status == models.JobStatusCompleted })
+
+	if first.header.Get(WebhookIDHeader) != second.header.Get(WebhookIDHeader) {
+		t.Errorf("retry has event ID %q, first try %q", second.header.Get(WebhookIDHeader), first.header.Get(WebhookIDHeader))
+	}
+	if endpoint := w.reload(t); endpoint.FailureCount != 0 {
+		t.Errorf("failure count after success = %d, want 0", endpoint.FailureCount)
+	}
+
+	// One log row per attempt
+	deliveries := w.deliveries(t)
+	if len(deliveries) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(deliveries))
+	}
+	if d := deliveries[0]; d.Attempt != 1 || d.Success || d.StatusCode != http.StatusServiceUnavailable || d.Error == "" {
+		t.Errorf("first delivery = %+v", d)
+	}
+	if d := deliveries[1]; d.Attempt != 2 || !d.Success || d.StatusCode != http.StatusOK {
+		t.Errorf("second delivery = %+v", d)
+	}
+}
+
+func TestWebhookDisablesFailingEndpoint(t *testing.T) {
+	w := newWebhookTest(t, http.StatusInternalServerError, http.StatusInternalServerError)
+
+	if err := w.service.deliver(context.Background(), w.job(t)); err == nil {
+		t.Fatal("failed delivery returned no error to retry on")
+	}
+	w.receiver.next(t)
+	if endpoint := w.reload(t); !endpoint.IsActive {
+		t.Fatal("endpoint disabled after one failure")
+	}
+
+	// The failure reaching the threshold disables the endpoint and ends the retries
+	if err := w.db.Model(w.endpoint).Update("failure_count", WebhookDisableAfter-1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := w.service.deliver(context.Background(), w.job(t)); err != nil {
+		t.Errorf("deliver to disabled endpoint returned %v, want no retry", err)
+	}
+	w.receiver.next(t)
+
+	endpoint := w.reload(t)
+	if endpoint.IsActive || endpoint.DisabledAt == nil || endpoint.DisabledReason == "" {
+		t.Fatalf("endpoint = %+v, want disabled with a reason", endpoint)
+	}
+
+	// Disabled endpoints get no deliveries
+	if err := w.service.HandleEvent(context.Background(), w.event()); err != nil {
+		t.Fatal(err)
+	}
+	var queued int64
+	w.db.Model(&models.Job{}).Count(&queued)
+	if queued != 0 {
+		t.Errorf("queued %d deliveries to a disabled endpoint", queued)
+	}
+	if _, err := w.service.ResendDelivery(w.deliveries(t)[0].ID); err == nil {
+		t.Error("resent a delivery to a disabled endpoint")
+	}
+}
+
+func TestWebhookResendDelivery(t *testing.T) {
+	w := newWebhookTest(t)
+	w.start(t)
+
+	if err := w.service.HandleEvent(context.Background(), w.event()); err != nil {
+		t.Fatal(err)
+	}
+	original := w.receiver.next(t)
+
+	var delivery models.WebhookDelivery
+	deadline := time.Now().Add(5 * time.Second)
+	for w.db.First(&delivery).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was not logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	job, err := w.service.ResendDelivery(delivery.ID)
+	if err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	resent := w.receiver.next(t)
+	w.waitForJob(t, job.ID, func(j *models.Job) bool { return j.Status == models.JobStatusCompleted })
+
+	var a, b WebhookBody
+	if err := json.Unmarshal(original.body, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(resent.body, &b); err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != b.ID || a.Type != b.Type || !a.CreatedAt.Equal(b.CreatedAt) {
+		t.Errorf("resent %+v, originally sent %+v", b, a)
+	}
+	if resent.header.Get(WebhookIDHeader) != original.header.Get(WebhookIDHeader) {
+		t.Errorf("resent with event ID %q, originally %q", resent.header.Get(WebhookIDHeader), original.header.Get(WebhookIDHeader))
+	}
+	if deliveries := w.deliveries(t); len(deliveries) != 2 {
+		t.Errorf("got %d deliveries, want the original and the resend", len(deliveries))
+	}
+}
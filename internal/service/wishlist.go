@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/sajal/go-ecommerce/internal/jobs"
+	"github.com/sajal/go-ecommerce/internal/models"
+	"github.com/sajal/go-ecommerce/internal/money"
+	"github.com/sajal/go-ecommerce/internal/repository"
+	"github.com/sajal/go-ecommerce/internal/telemetry"
+)
+
+const (
+	WishlistsQueue   = "wishlists"
+	JobWishlistAlert = "wishlist.alert"
+)
+
+// Kinds of wishlist alerts
+const (
+	AlertPriceDrop   = "price_drop"
+	AlertBackInStock = "back_in_stock"
+)
+
+// WishlistAlertJob is the payload of the JobWishlistAlert job, one alert to
+// one user
+type WishlistAlertJob struct {
+	UserID    uint        `json:"user_id"`
+	ProductID uint        `json:"product_id"`
+	Kind      string      `json:"kind"`
+	OldPrice  money.Money `json:"old_price"` // Price before the drop
+}
+
+// WishlistNotifier delivers alerts about products on a user's wishlists
+type WishlistNotifier interface {
+	NotifyPriceDrop(user *models.User, product *models.Product, oldPrice money.Money) error
+	NotifyBackInStock(user *models.User, product *models.Product) error
+}
+
+// LogNotifier writes wishlist alerts to the standard logger
+type LogNotifier struct{}
+
+func (LogNotifier) NotifyPriceDrop(user *models.User, product *models.Product, oldPrice money.Money) error {
+	log.Printf("wishlist: price drop for %s: %q %s -> %s", user.Email, product.Name, oldPrice, product.Price)
+	return nil
+}
+
+func (LogNotifier) NotifyBackInStock(user *models.User, product *models.Product) error {
+	log.Printf("wishlist: back in stock for %s: %q", user.Email, product.Name)
+	return nil
+}
+
+// EmailNotifier emails wishlist alerts through the email service
+type EmailNotifier struct {
+	emails *EmailService
+}
+
+func NewEmailNotifier(emails *EmailService) *EmailNotifier {
+	return &EmailNotifier{emails: emails}
+}
+
+func (n *EmailNotifier) NotifyPriceDrop(user *models.User, product *models.Product, oldPrice money.Money) error {
+	return n.emails.SendPriceDrop(user, product, oldPrice)
+}
+
+func (n *EmailNotifier) NotifyBackInStock(user *models.User, product *models.Product) error {
+	return n.emails.SendBackInStock(user, product)
+}
+
+type WishlistService struct {
+	repo        *repository.WishlistRepository
+	productRepo *repository.ProductRepository
+	cartService *CartService
+	notifier    WishlistNotifier
+	queue       *jobs.Queue
+}
+
+func NewWishlistService(repo *repository.WishlistRepository, productRepo *repository.ProductRepository, cartService *CartService, notifier WishlistNotifier, queue *jobs.Queue) *WishlistService {
+	s := &WishlistService{
+		repo:        repo,
+		productRepo: productRepo,
+		cartService: cartService,
+		notifier:    notifier,
+		queue:       queue,
+	}
+	jobs.Register(queue, WishlistsQueue, JobWishlistAlert, s.alert)
+	return s
+}
+
+func (s *WishlistService) CreateWishlist(ctx context.Context, userID uint, name string, isPublic bool) (_ *models.Wishlist, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.CreateWishlist")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if name == "" {
+		return nil, errors.New("wishlist name is required")
+	}
+
+	// Check if a list with the same name exists
+	if _, err := s.repo.WithContext(ctx).FindByUserAndName(userID, name); err == nil {
+		return nil, errors.New("wishlist with this name already exists")
+	}
+
+	wishlist := &models.Wishlist{UserID: userID, Name: name}
+	if err := s.setVisibility(wishlist, isPublic); err != nil {
+		return nil, err
+	}
+	if err := s.repo.WithContext(ctx).Create(wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// setVisibility issues a share token when a list becomes public and revokes
+// it when the list is made private again
+func (s *WishlistService) setVisibility(wishlist *models.Wishlist, isPublic bool) error {
+	wishlist.IsPublic = isPublic
+	if !isPublic {
+		wishlist.ShareToken = ""
+		return nil
+	}
+	if wishlist.ShareToken == "" {
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		wishlist.ShareToken = token
+	}
+	return nil
+}
+
+func (s *WishlistService) GetUserWishlists(ctx context.Context, userID uint) (_ []models.Wishlist, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.GetUserWishlists")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	return s.repo.WithContext(ctx).FindByUserID(userID)
+}
+
+// GetWishlist returns a list owned by the user
+func (s *WishlistService) GetWishlist(ctx context.Context, userID uint, id uint) (_ *models.Wishlist, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.GetWishlist")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	wishlist, err := s.repo.WithContext(ctx).FindByID(id)
+	if err != nil {
+		return nil, errors.New("wishlist not found")
+	}
+
+	// Check ownership
+	if wishlist.UserID != userID {
+		return nil, errors.New("wishlist not found")
+	}
+
+	return wishlist, nil
+}
+
+// GetSharedWishlist returns a public list by its share token
+func (s *WishlistService) GetSharedWishlist(ctx context.Context, token string) (_ *models.Wishlist, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.GetSharedWishlist")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	wishlist, err := s.repo.WithContext(ctx).FindByShareToken(token)
+	if err != nil {
+		return nil, errors.New("wishlist not found")
+	}
+	return wishlist, nil
+}
+
+func (s *WishlistService) UpdateWishlist(ctx context.Context, userID uint, id uint, name string, isPublic bool) (_ *models.Wishlist, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.UpdateWishlist")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	wishlist, err := s.GetWishlist(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return nil, errors.New("wishlist name is required")
+	}
+
+	// Check if another list with the same name exists
+	if name != wishlist.Name {
+		if duplicate, err := s.repo.WithContext(ctx).FindByUserAndName(userID, name); err == nil && duplicate.ID != id {
+			return nil, errors.New("wishlist with this name already exists")
+		}
+	}
+
+	wishlist.Name = name
+	if err := s.setVisibility(wishlist, isPublic); err != nil {
+		return nil, err
+	}
+	if err := s.repo.WithContext(ctx).Update(wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s *WishlistService) DeleteWishlist(ctx context.Context, userID uint, id uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.DeleteWishlist")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if _, err := s.GetWishlist(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.WithContext(ctx).Delete(id)
+}
+
+func (s *WishlistService) AddItem(ctx context.Context, userID uint, wishlistID uint, productID uint) (_ *models.WishlistItem, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.AddItem")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	wishlist, err := s.GetWishlist(ctx, userID, wishlistID)
+	if err != nil {
+		return nil, err
+	}
+	return s.addItem(ctx, wishlist, productID)
+}
+
+func (s *WishlistService) addItem(ctx context.Context, wishlist *models.Wishlist, productID uint) (*models.WishlistItem, error) {
+	// Check if product exists
+	product, err := s.productRepo.WithContext(ctx).FindByID(productID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	// Adding a product twice keeps the existing entry
+	if existing, err := s.repo.WithContext(ctx).FindItem(wishlist.ID, productID); err == nil {
+		return existing, nil
+	}
+
+	item := &models.WishlistItem{
+		WishlistID:   wishlist.ID,
+		ProductID:    productID,
+		PriceAtAdded: product.Price,
+	}
+	if err := s.repo.WithContext(ctx).AddItem(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *WishlistService) RemoveItem(ctx context.Context, userID uint, wishlistID uint, itemID uint) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.RemoveItem")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if _, err := s.GetWishlist(ctx, userID, wishlistID); err != nil {
+		return err
+	}
+
+	// Check if item exists in list
+	if _, err := s.repo.WithContext(ctx).FindItemByID(itemID, wishlistID); err != nil {
+		return errors.New("item not found")
+	}
+
+	return s.repo.WithContext(ctx).RemoveItem(itemID)
+}
+
+// MoveToCart adds a wishlist item to the user's cart and takes it off the list
+func (s *WishlistService) MoveToCart(ctx context.Context, userID uint, wishlistID uint, itemID uint, quantity int) (_ *models.Cart, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.MoveToCart")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if _, err := s.GetWishlist(ctx, userID, wishlistID); err != nil {
+		return nil, err
+	}
+
+	item, err := s.repo.WithContext(ctx).FindItemByID(itemID, wishlistID)
+	if err != nil {
+		return nil, errors.New("item not found")
+	}
+
+	key := CartKey{UserID: userID}
+	if _, err := s.cartService.AddToCart(ctx, key, item.ProductID, quantity); err != nil {
+		return nil, err
+	}
+	if err := s.repo.WithContext(ctx).RemoveItem(itemID); err != nil {
+		return nil, err
+	}
+
+	return s.cartService.GetCart(ctx, key)
+}
+
+// SaveForLater moves a cart line to one of the user's lists. A wishlistID of
+// zero uses the "Saved for later" list, creating it on first use.
+func (s *WishlistService) SaveForLater(ctx context.Context, userID uint, cartItemID uint, wishlistID uint) (_ *models.Wishlist, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "WishlistService.SaveForLater")
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	var wishlist *models.Wishlist
+	if wishlistID != 0 {
+		wishlist, err = s.GetWishlist(ctx, userID, wishlistID)
+	} else {
+		wishlist, err = s.repo.WithContext(ctx).FindByUserAndName(userID, models.SavedForLaterListName)
+		if err != nil {
+			wishlist, err = s.CreateWishlist(ctx, userID, models.SavedForLaterListName, false)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the cart line
+	cart, err := s.cartService.GetCart(ctx, CartKey{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	var cartItem *models.CartItem
+	for i := range cart.Items {
+		if cart.Items[i].ID == cartItemID {
+			cartItem = &cart.Items[i]
+			break
+		}
+	}
+	if cartItem == nil {
+		return nil, errors.New("item not found")
+	}
+
+	if _, err := s.addItem(ctx, wishlist, cartItem.ProductID); err != nil {
+		return nil, err
+	}
+	if err := s.cartService.RemoveFromCart(ctx, CartKey{UserID: userID}, cartItemID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.WithContext(ctx).FindByID(wishlist.ID)
+}
+
+// ProductCreated is a no-op, new products are on nobody's wishlist
+func (s *WishlistService) ProductCreated(product *models.Product) {}
+
+// ProductDeleted is a no-op, carts and wishlists flag removed products
+func (s *WishlistService) ProductDeleted(product *models.Product) {}
+
+// ProductUpdated queues an alert to each user watching a product when its
+// price drops or it comes back in stock. Failures to queue are logged and do
+// not affect the product update.
+func (s *WishlistService) ProductUpdated(before, after *models.Product) {
+	priceDropped := after.Price.Cmp(before.Price) < 0
+	backInStock := before.Stock <= 0 && after.Stock > 0
+	if !after.IsActive || (!priceDropped && !backInStock) {
+		return
+	}
+
+	users, err := s.repo.FindUsersWatchingProduct(after.ID)
+	if err != nil {
+		log.Printf("wishlist: failed to find watchers of product %d: %v", after.ID, err)
+		return
+	}
+
+	var alerts []WishlistAlertJob
+	for _, user := range users {
+		if priceDropped {
+			alerts = append(alerts, WishlistAlertJob{UserID: user.ID, ProductID: after.ID, Kind: AlertPriceDrop, OldPrice: before.Price})
+		}
+		if backInStock {
+			alerts = append(alerts, WishlistAlertJob{UserID: user.ID, ProductID: after.ID, Kind: AlertBackInStock})
+		}
+	}
+	for _, alert := range alerts {
+		if _, err := s.queue.Enqueue(JobWishlistAlert, alert); err != nil {
+			log.Printf("wishlist: failed to queue %s alert to user %d: %v", alert.Kind, alert.UserID, err)
+		}
+	}
+}
+
+// alert is the JobWishlistAlert handler. Alerts that no longer hold by the
+// time they run, because the product left the wishlist or the catalog or its
+// price or stock changed again, are dropped.
+func (s *WishlistService) alert(ctx context.Context, job WishlistAlertJob) error {
+	user, err := s.repo.WithContext(ctx).FindWatcher(job.UserID, job.ProductID)
+	if err != nil || user == nil {
+		return err
+	}
+	product, err := s.productRepo.WithContext(ctx).FindByID(job.ProductID)
+	if err != nil {
+		return err
+	}
+
+	switch job.Kind {
+	case AlertPriceDrop:
+		if product.Price.Cmp(job.OldPrice) >= 0 {
+			return nil
+		}
+		return s.notifier.NotifyPriceDrop(user, product, job.OldPrice)
+	case AlertBackInStock:
+		if product.Stock <= 0 {
+			return nil
+		}
+		return s.notifier.NotifyBackInStock(user, product)
+	}
+	return fmt.Errorf("unknown wishlist alert %q", job.Kind)
+}
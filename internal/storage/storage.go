@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Store keeps uploaded and generated files
+type Store interface {
+	// Save writes data under name and returns the URL it is served from
+	Save(name string, data []byte) (string, error)
+	// Open reads back a file written by Save
+	Open(name string) ([]byte, error)
+	// Delete removes a file written by Save
+	Delete(name string) error
+}
+
+// LocalStore keeps files in a directory on disk that is served at BaseURL
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewLocalStore(dir string, baseURL string) *LocalStore {
+	return &LocalStore{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (s *LocalStore) path(name string) string {
+	// Clean against a rooted path so names cannot escape Dir
+	return filepath.Join(s.Dir, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+func (s *LocalStore) Save(name string, data []byte) (string, error) {
+	p := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		return "", err
+	}
+	return s.BaseURL + path.Clean("/"+name), nil
+}
+
+func (s *LocalStore) Open(name string) ([]byte, error) {
+	return os.ReadFile(s.path(name))
+}
+
+func (s *LocalStore) Delete(name string) error {
+	err := os.Remove(s.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
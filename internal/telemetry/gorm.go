@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Keys of the per-statement state kept between the callbacks
+const (
+	startKey  = "telemetry:start"
+	parentKey = "telemetry:parent"
+	spanKey   = "telemetry:span"
+)
+
+// GormPlugin times every statement into the db_query_* metrics and, when
+// the statement's context carries a span, traces it as a child span
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "telemetry"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("telemetry:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("telemetry:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("telemetry:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("telemetry:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("telemetry:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("telemetry:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("telemetry:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("telemetry:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("telemetry:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("telemetry:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("telemetry:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("telemetry:after_raw", after("raw")),
+	)
+}
+
+func before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+
+		// Statements outside a traced request would only add orphan traces
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		spanCtx, span := Tracer().Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		db.InstanceSet(parentKey, ctx)
+		db.InstanceSet(spanKey, span)
+		db.Statement.Context = spanCtx
+	}
+}
+
+func after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		table := db.Statement.Table
+		failed := db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound)
+		if start, ok := db.InstanceGet(startKey); ok {
+			dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start.(time.Time)).Seconds())
+		}
+		if failed {
+			dbQueryErrors.WithLabelValues(operation, table).Inc()
+		}
+
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		span.SetAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+			semconv.DBQueryText(db.Statement.SQL.String()),
+		)
+		var err error
+		if failed {
+			err = db.Error
+		}
+		EndSpan(span, err)
+		if parent, ok := db.InstanceGet(parentKey); ok {
+			db.Statement.Context = parent.(context.Context)
+		}
+	}
+}
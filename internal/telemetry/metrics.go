@@ -0,0 +1,95 @@
+// Package telemetry holds the Prometheus metrics of the store and sets up
+// OpenTelemetry tracing.
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sajal/go-ecommerce/internal/money"
+)
+
+// namespace prefixes every metric name
+const namespace = "ecommerce"
+
+// Registry holds every metric /metrics exposes
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve HTTP requests by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time to run database statements by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Database statements that failed, by operation and table. Lookups that find nothing are not errors.",
+	}, []string{"operation", "table"})
+
+	ordersPlaced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_placed_total",
+		Help:      "Orders placed by checkout kind (user or guest) and currency.",
+	}, []string{"checkout", "currency"})
+
+	orderRevenue = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "order_revenue_total",
+		Help:      "Totals of placed orders in major units of their currency.",
+	}, []string{"currency"})
+
+	checkoutFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkout_failures_total",
+		Help:      "Checkouts that did not place an order, by checkout kind and reason.",
+	}, []string{"checkout", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		dbQueryDuration,
+		dbQueryErrors,
+		ordersPlaced,
+		orderRevenue,
+		checkoutFailures,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveHTTPRequest records one served request. Route is the route
+// template, such as /api/v1/products/:id, so paths with IDs share a series.
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// OrderPlaced counts an order and its total
+func OrderPlaced(checkout string, total money.Money) {
+	ordersPlaced.WithLabelValues(checkout, total.Currency).Inc()
+	orderRevenue.WithLabelValues(total.Currency).Add(total.Major())
+}
+
+// CheckoutFailed counts a checkout that did not place an order
+func CheckoutFailed(checkout, reason string) {
+	checkoutFailures.WithLabelValues(checkout, reason).Inc()
+}
@@ -2,15 +2,10 @@ package main
 
 import (
 	"log"
+	"os"
 
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	_ "github.com/sajal/go-ecommerce/docs"
-	"github.com/sajal/go-ecommerce/internal/api"
-	"github.com/sajal/go-ecommerce/internal/config"
-	"github.com/sajal/go-ecommerce/internal/middleware"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/sajal/go-ecommerce/internal/cli"
 )
 
 // @title           E-commerce API
@@ -33,36 +28,7 @@ import (
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Error loading .env file: %v", err)
-	}
-
-	// Load configuration
-	cfg := config.LoadConfig()
-
-	// Initialize database
-	db := config.InitDB(cfg)
-
-	// Initialize router
-	router := gin.Default()
-
-	// Apply global middlewares
-	router.Use(middleware.LoggerMiddleware())
-	router.Use(middleware.CORSMiddleware())
-
-	// Initialize API handler
-	handler := api.NewHandler(db)
-
-	// Setup routes
-	handler.SetupRoutes(router)
-
-	// Swagger documentation
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	// Start server
-	serverAddr := ":" + cfg.Port
-	if err := router.Run(serverAddr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := cli.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
 }